@@ -380,8 +380,8 @@ func TestServerHelperMethods(t *testing.T) {
 		}
 		proxyServer, _ := NewServer(proxyCfg)
 		proxyEndpoints := proxyServer.GetAllEndpoints()
-		if len(proxyEndpoints) != 7 {
-			t.Errorf("Expected 7 endpoints in proxy mode, got %d", len(proxyEndpoints))
+		if len(proxyEndpoints) != 9 {
+			t.Errorf("Expected 9 endpoints in proxy mode, got %d", len(proxyEndpoints))
 		}
 	})
 
@@ -22,6 +22,21 @@ type CachedToken struct {
 	ExpiresAt time.Time
 }
 
+// tokenCacheTTL is the maximum time a validation result is cached
+const tokenCacheTTL = 5 * time.Minute
+
+// cacheExpiry returns when a validation result for user should expire:
+// after tokenCacheTTL, but never later than the token's own "exp" claim
+func cacheExpiry(user *User) time.Time {
+	expiresAt := time.Now().Add(tokenCacheTTL)
+	if exp, ok := user.Claims["exp"].(float64); ok {
+		if tokenExpiry := time.Unix(int64(exp), 0); tokenExpiry.Before(expiresAt) {
+			return tokenExpiry
+		}
+	}
+	return expiresAt
+}
+
 // getCachedToken retrieves a cached token validation result
 func (tc *TokenCache) getCachedToken(tokenHash string) (*CachedToken, bool) {
 	tc.mu.RLock()
@@ -0,0 +1,144 @@
+package oauth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// AuthError describes why a request to a protected resource was rejected.
+// It carries the RFC 6750 error code and HTTP status used for the
+// WWW-Authenticate challenge. Use errors.As to inspect errors returned by
+// ValidateTokenCached and the tool middleware.
+type AuthError struct {
+	Status      int    // HTTP status (401 if zero)
+	Code        string // e.g. "invalid_token", "invalid_dpop_proof"
+	Description string // Human-readable description sent to the client
+	// Params holds additional challenge parameters (e.g. "scope")
+	Params map[string]string
+	// Err is the underlying cause, logged but never sent to the client
+	Err error
+}
+
+// Error implements the error interface
+func (e *AuthError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Description, e.Err)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Description)
+}
+
+// Unwrap returns the underlying cause
+func (e *AuthError) Unwrap() error {
+	return e.Err
+}
+
+// StructuredContent returns the error as an RFC 6750 style error object,
+// including the challenge parameters (e.g. "acr_values", "max_age"). SDK
+// adapters use it as the structured content of MCP tool errors.
+func (e *AuthError) StructuredContent() map[string]interface{} {
+	content := map[string]interface{}{
+		"error":             e.Code,
+		"error_description": e.Description,
+	}
+	for key, value := range e.Params {
+		content[key] = value
+	}
+	return content
+}
+
+// status returns the HTTP status for the challenge
+func (e *AuthError) status() int {
+	if e.Status == 0 {
+		return http.StatusUnauthorized
+	}
+	return e.Status
+}
+
+// invalidTokenError creates an invalid_token AuthError
+func invalidTokenError(description string, err error) *AuthError {
+	return &AuthError{Code: "invalid_token", Description: description, Err: err}
+}
+
+// asAuthError converts any validation error to an AuthError. Errors that are
+// not AuthErrors become a generic invalid_token error so that internal details
+// are never sent to the client.
+func asAuthError(err error) *AuthError {
+	var authErr *AuthError
+	if errors.As(err, &authErr) {
+		return authErr
+	}
+	return invalidTokenError("Authentication failed", err)
+}
+
+// writeAuthChallenge writes a 401/403 response with WWW-Authenticate challenges
+// (RFC 6750, RFC 9449) and a JSON error body
+func (s *Server) writeAuthChallenge(w http.ResponseWriter, authErr *AuthError) {
+	bearerCode := authErr.Code
+	if bearerCode == "invalid_dpop_proof" {
+		bearerCode = "invalid_token"
+	}
+
+	// Advertise the scopes needed to access this resource (RFC 6750 Section 3)
+	if _, ok := authErr.Params["scope"]; !ok && s.config != nil && len(s.config.Scopes) > 0 {
+		params := map[string]string{"scope": strings.Join(s.config.Scopes, " ")}
+		for key, value := range authErr.Params {
+			params[key] = value
+		}
+		scoped := *authErr
+		scoped.Params = params
+		authErr = &scoped
+	}
+
+	if s.dpop == nil || s.dpop.mode != DPoPModeRequired {
+		w.Header().Add("WWW-Authenticate", formatChallenge(authSchemeBearer, bearerCode, authErr))
+	}
+	if s.dpop != nil {
+		w.Header().Add("WWW-Authenticate", formatChallenge(authSchemeDPoP, authErr.Code, authErr,
+			"algs", strings.Join(s.dpop.algorithms, " ")))
+	}
+	w.Header().Add("WWW-Authenticate", challengeParam("resource_metadata", s.GetProtectedResourceMetadataURL()))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(authErr.status())
+
+	if err := json.NewEncoder(w).Encode(oauthErrorResponse{
+		Error:            authErr.Code,
+		ErrorDescription: authErr.Description,
+	}); err != nil {
+		s.logger.Error("Error encoding OAuth error response: %v", err)
+	}
+}
+
+// formatChallenge builds a single WWW-Authenticate challenge value
+func formatChallenge(scheme, code string, authErr *AuthError, extra ...string) string {
+	params := []string{challengeParam("realm", "OAuth")}
+	for i := 0; i+1 < len(extra); i += 2 {
+		params = append(params, challengeParam(extra[i], extra[i+1]))
+	}
+	params = append(params,
+		challengeParam("error", code),
+		challengeParam("error_description", authErr.Description),
+	)
+
+	keys := make([]string, 0, len(authErr.Params))
+	for key := range authErr.Params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		params = append(params, challengeParam(key, authErr.Params[key]))
+	}
+
+	return scheme + " " + strings.Join(params, ", ")
+}
+
+// challengeQuoter escapes a value for an RFC 7230 quoted-string
+var challengeQuoter = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// challengeParam formats an auth-param with its value as a quoted-string
+func challengeParam(name, value string) string {
+	return name + `="` + challengeQuoter.Replace(value) + `"`
+}
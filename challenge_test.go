@@ -0,0 +1,63 @@
+package oauth
+
+import (
+	"strings"
+	"testing"
+)
+
+// parseChallenge returns the auth-params of a WWW-Authenticate challenge,
+// unescaping quoted-string values (RFC 7235 Section 2.1)
+func parseChallenge(t *testing.T, challenge string) map[string]string {
+	t.Helper()
+
+	_, rest, _ := strings.Cut(challenge, " ")
+	params := map[string]string{}
+	for rest != "" {
+		name, value, ok := strings.Cut(rest, `="`)
+		if !ok {
+			t.Fatalf("Malformed challenge %s", challenge)
+		}
+		var unquoted strings.Builder
+		i := 0
+		for ; i < len(value) && value[i] != '"'; i++ {
+			if value[i] == '\\' && i+1 < len(value) {
+				i++
+			}
+			unquoted.WriteByte(value[i])
+		}
+		if i == len(value) {
+			t.Fatalf("Unterminated quoted-string in challenge %s", challenge)
+		}
+		params[name] = unquoted.String()
+
+		rest = value[i+1:]
+		if rest != "" && !strings.HasPrefix(rest, ", ") {
+			t.Fatalf("Unexpected %q after the %s parameter in challenge %s", rest, name, challenge)
+		}
+		rest = strings.TrimPrefix(rest, ", ")
+	}
+	return params
+}
+
+// TestFormatChallenge tests that parameter values are sent as quoted-strings
+func TestFormatChallenge(t *testing.T) {
+	authErr := &AuthError{
+		Code:        "invalid_token",
+		Description: `Claim "aud" must match C:\mcp`,
+		Params:      map[string]string{"scope": `read "all"`},
+	}
+	params := parseChallenge(t, formatChallenge(authSchemeBearer, authErr.Code, authErr, "algs", `ES256 "x"`))
+
+	expected := map[string]string{
+		"realm":             "OAuth",
+		"algs":              `ES256 "x"`,
+		"error":             "invalid_token",
+		"error_description": `Claim "aud" must match C:\mcp`,
+		"scope":             `read "all"`,
+	}
+	for name, value := range expected {
+		if params[name] != value {
+			t.Errorf("Expected %s=%q, got %q", name, value, params[name])
+		}
+	}
+}
@@ -2,10 +2,24 @@ package oauth
 
 import (
 	"fmt"
+	"html/template"
+	"io/fs"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/tuannvm/oauth-mcp-proxy/provider"
 )
 
+// IssuerConfig describes an additional trusted OIDC issuer (see Config.Issuers)
+type IssuerConfig = provider.IssuerConfig
+
+// ClaimMapping controls which token claims populate User fields
+type ClaimMapping = provider.ClaimMapping
+
+// ClaimPolicy configures which registered claims a token must carry
+type ClaimPolicy = provider.ClaimPolicy
+
 // Config holds OAuth configuration
 type Config struct {
 	// OAuth settings
@@ -19,9 +33,167 @@ type Config struct {
 	ClientID     string
 	ClientSecret string
 
+	// Optional - Multiple issuers and audiences
+	// Audiences lists additional accepted token audiences besides Audience
+	// (e.g. a legacy API identifier during a migration). When Resources is set,
+	// ServerURL and Resources are also accepted (RFC 8707 resource indicators).
+	Audiences []string
+	// Issuers lists additional trusted OIDC issuers. Tokens are routed to the
+	// matching issuer by their "iss" claim; tokens from other issuers are rejected.
+	Issuers []IssuerConfig
+	// ClaimMapping overrides which claims populate User fields (nil uses sub,
+	// preferred_username and email).
+	ClaimMapping *ClaimMapping
+	// UserInfoEnrichment fills in User.Username and User.Email when the access
+	// token lacks them, by calling the issuer's discovered userinfo_endpoint
+	// with the token. Results are cached with the validation. OIDC providers only.
+	UserInfoEnrichment bool
+
+	// Resources lists additional resource identifiers (RFC 8707) served by this
+	// server besides ServerURL, e.g. "https://mcp.example.com/admin". Clients may
+	// request tokens for any of them, and such tokens are only accepted at the
+	// matching resource.
+	Resources []string
+
+	// Optional - Scopes
+	// Scopes are requested from the upstream provider when the client does not
+	// send a scope parameter (default: openid, profile, email) and are listed in
+	// the scope parameter of WWW-Authenticate challenges.
+	Scopes []string
+	// ScopesSupported are advertised as scopes_supported in the metadata
+	// endpoints and bound the scopes clients may request (default: Scopes)
+	ScopesSupported []string
+
+	// Optional - Step-up authentication (RFC 9470)
+	// StepUp maps tool names to the authentication strength they require
+	// (acr, amr, auth_time). Callers that do not meet it receive an
+	// insufficient_user_authentication challenge.
+	StepUp map[string]StepUpRequirement
+
+	// Optional - Access token profile
+	// AccessTokenProfile enforces a JWT access token profile. Set to "rfc9068"
+	// to require typ "at+jwt" and the client_id, scope and jti claims, which
+	// also rejects OIDC ID tokens presented as access tokens. Empty accepts any JWT.
+	AccessTokenProfile string
+	// AllowedAlgorithms lists the accepted token signing algorithms. Defaults to
+	// HS256 for HMAC and RS256/ES256 for OIDC providers. OIDC providers accept
+	// RS*, ES*, PS* and EdDSA; HMAC accepts HS256, HS384 and HS512.
+	AllowedAlgorithms []string
+	// Leeway is the clock skew tolerated when checking exp, nbf and iat (default: 0)
+	Leeway time.Duration
+	// Clock returns the current time used for token validation. Defaults to
+	// time.Now; inject a fixed clock in tests.
+	Clock func() time.Time
+	// ClaimPolicy configures required claims. By default "exp" is mandatory;
+	// MaxTokenAge and RequireJTI add further requirements.
+	ClaimPolicy ClaimPolicy
+
+	// Optional - DPoP sender-constrained tokens (RFC 9449)
+	// DPoPMode enables DPoP proof validation: "allowed" accepts both Bearer and
+	// DPoP-bound tokens, "required" rejects Bearer tokens. Empty disables DPoP
+	// and rejects DPoP-bound (cnf.jkt) tokens.
+	DPoPMode string
+	// DPoPSigningAlgorithms lists the accepted proof signing algorithms
+	// (default: ES256, RS256, PS256, EdDSA)
+	DPoPSigningAlgorithms []string
+	// DPoPProofLifetime is how long a proof is accepted after its iat (default: 5 minutes)
+	DPoPProofLifetime time.Duration
+
+	// Optional - Mutual-TLS certificate-bound tokens (RFC 8705)
+	// CertificateBoundTokens requires every token to carry a "cnf.x5t#S256"
+	// claim matching the TLS client certificate of the request. TLS must be
+	// terminated by this server so that http.Request.TLS holds the certificate.
+	CertificateBoundTokens bool
+
+	// Optional - PKCE policy (RFC 7636, proxy mode)
+	// PKCEMethods lists the code_challenge_method values accepted by
+	// /oauth/authorize (default: S256). Add "plain" only for legacy clients.
+	// PKCE is always required because proxy clients are public clients.
+	PKCEMethods []string
+
+	// Optional - Client redirect URIs (RFC 8252, proxy mode)
+	// ClientRedirectURIs lists the client redirect URIs accepted in fixed
+	// redirect mode (a single RedirectURIs value). Defaults to any loopback URI
+	// (localhost, 127.0.0.1, [::1]). Entries use the same patterns as allowlist
+	// mode RedirectURIs: loopback hosts match any port, a path ending in "*"
+	// matches by prefix, "https://*.example.com/..." matches one subdomain
+	// label, and private-use schemes such as "cursor://" are allowed.
+	ClientRedirectURIs []string
+
+	// Optional - Consent page (proxy mode)
+	// Consent shows a page at /oauth/authorize with the client name, redirect
+	// host and requested scopes, where the user can allow the request, deny it
+	// or sign in with another account, before redirecting to the provider.
+	// Approvals are remembered per subject and client once the code is
+	// redeemed; in allowlist mode, where the callback bypasses the proxy, the
+	// page is shown for every authorization.
+	Consent bool
+	// ConsentStore remembers approvals (default: NewMemoryConsentStore())
+	ConsentStore ConsentStore
+
+	// Optional - HTML pages (proxy mode)
+	// Templates replaces the built-in "success.html", "error.html",
+	// "consent.html" and "logout.html" pages. Pages are looked up by name and
+	// executed with PageData; pages that are not defined keep the default.
+	// Inline <style> and <script> elements must carry nonce="{{.Nonce}}".
+	Templates *template.Template
+	// TemplatesFS is parsed with template.ParseFS(TemplatesFS, "*.html")
+	// instead of setting Templates; each file replaces the page of its name.
+	TemplatesFS fs.FS
+
+	// Optional - Multiple upstream identity providers (proxy mode)
+	// UpstreamProviders lets users sign in with other identity providers
+	// besides Provider and Issuer, each with its own client credentials.
+	// /oauth/authorize accepts an idp parameter naming the provider, or shows
+	// a selection page without one. Requires an OIDC Provider and fixed
+	// redirect mode, since the proxy must see each code to know which provider
+	// redeems it. Their issuers are trusted for token validation like Issuers.
+	// The device authorization grant only uses the primary provider.
+	UpstreamProviders []UpstreamProvider
+	// ProviderName is the idp value of Provider and Issuer (default: Provider)
+	ProviderName string
+
+	// Optional - Pushed authorization requests (RFC 9126, proxy mode)
+	// RequirePushedAuthorizationRequests rejects /oauth/authorize requests that
+	// do not use a request_uri obtained from /oauth/par. The PAR endpoint is
+	// available either way.
+	RequirePushedAuthorizationRequests bool
+
+	// Optional - Upstream token vault (proxy mode)
+	// TokenVault keeps the upstream access, refresh and ID tokens obtained by
+	// /oauth/token, encrypted and keyed by subject, so that tool handlers can
+	// call the provider's APIs as the user via GetUpstreamToken(ctx).
+	// Create it with NewTokenVault. Nil disables the vault.
+	TokenVault *TokenVault
+
 	// Server configuration
 	ServerURL string // Full URL of the MCP server
 
+	// Optional - Endpoint paths
+	// BasePath prefixes the paths of all OAuth endpoints and metadata
+	// documents, e.g. "/api/mcp" when the server is mounted under /api/mcp/.
+	// Endpoint URLs use the scheme and host of ServerURL followed by BasePath
+	// (a path in ServerURL is ignored), which is also the issuer identifier
+	// in proxy mode.
+	BasePath string
+	// Paths overrides individual endpoint paths (relative to BasePath)
+	Paths Paths
+
+	// Optional - Metadata documents
+	// MetadataOverrides customizes the authorization server metadata, OpenID
+	// Provider configuration and protected resource metadata, e.g. to set
+	// service_documentation or add extension parameters.
+	MetadataOverrides MetadataOverrides
+	// UpstreamMetadata selects how native mode serves the authorization server
+	// metadata and OpenID Provider configuration: "mirror" (default) serves the
+	// issuer's discovery document from a cache, "redirect" redirects clients to
+	// it. Either way the endpoints are the issuer's own.
+	UpstreamMetadata string
+	// UpstreamMetadataRefresh is how often the mirrored document, and the
+	// discovery documents used by UserInfoEnrichment, are revalidated with the
+	// issuer (default: 1 hour)
+	UpstreamMetadataRefresh time.Duration
+
 	// Security
 	JWTSecret []byte // For HMAC provider and state signing
 
@@ -68,11 +240,137 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("unknown provider: %s (supported: hmac, okta, google, azure)", c.Provider)
 	}
 
+	// Validate signing algorithm allowlist
+	if err := provider.ValidateAlgorithms(c.Provider, c.AllowedAlgorithms); err != nil {
+		return err
+	}
+
+	// Validate clock skew and claim policy durations
+	if c.Leeway < 0 {
+		return fmt.Errorf("leeway must not be negative")
+	}
+	if c.ClaimPolicy.MaxTokenAge < 0 {
+		return fmt.Errorf("ClaimPolicy.MaxTokenAge must not be negative")
+	}
+
+	// Validate DPoP settings
+	if c.DPoPMode != "" && c.DPoPMode != DPoPModeAllowed && c.DPoPMode != DPoPModeRequired {
+		return fmt.Errorf("unknown DPoP mode: %s (supported: %s, %s)", c.DPoPMode, DPoPModeAllowed, DPoPModeRequired)
+	}
+	if c.DPoPProofLifetime < 0 {
+		return fmt.Errorf("DPoPProofLifetime must not be negative")
+	}
+	if err := validateDPoPAlgorithms(c.DPoPSigningAlgorithms); err != nil {
+		return err
+	}
+
 	// Validate audience
 	if c.Audience == "" {
 		return fmt.Errorf("audience is required")
 	}
 
+	// Validate resource indicators
+	for _, resource := range c.Resources {
+		if err := validateResourceIndicator(resource); err != nil {
+			return err
+		}
+	}
+
+	// Validate scopes
+	if err := validateScopes("Scopes", c.Scopes); err != nil {
+		return err
+	}
+	if err := validateScopes("ScopesSupported", c.ScopesSupported); err != nil {
+		return err
+	}
+	if len(c.ScopesSupported) > 0 {
+		for _, scope := range c.Scopes {
+			if !slices.Contains(c.ScopesSupported, scope) {
+				return fmt.Errorf("scope %q must also be listed in ScopesSupported", scope)
+			}
+		}
+	}
+
+	// Validate step-up requirements
+	for tool, requirement := range c.StepUp {
+		if requirement.MaxAge < 0 {
+			return fmt.Errorf("StepUp[%s].MaxAge must not be negative", tool)
+		}
+	}
+
+	// Validate PKCE methods
+	if err := validatePKCEMethods(c.PKCEMethods); err != nil {
+		return err
+	}
+
+	// Validate redirect URI patterns
+	if strings.Contains(c.RedirectURIs, ",") {
+		if err := validateRedirectPatterns("RedirectURIs", strings.Split(c.RedirectURIs, ",")); err != nil {
+			return err
+		}
+		if len(c.ClientRedirectURIs) > 0 {
+			return fmt.Errorf("ClientRedirectURIs requires fixed redirect mode (a single RedirectURIs value)")
+		}
+	}
+	if err := validateRedirectPatterns("ClientRedirectURIs", c.ClientRedirectURIs); err != nil {
+		return err
+	}
+
+	// Validate access token profile
+	if c.AccessTokenProfile != "" && c.AccessTokenProfile != provider.ProfileRFC9068 {
+		return fmt.Errorf("unknown access token profile: %s (supported: %s)", c.AccessTokenProfile, provider.ProfileRFC9068)
+	}
+
+	// Validate UserInfo enrichment
+	if c.UserInfoEnrichment && c.Provider == "hmac" {
+		return fmt.Errorf("UserInfoEnrichment is only supported for OIDC providers")
+	}
+
+	// Validate additional trusted issuers
+	if len(c.Issuers) > 0 && c.Provider == "hmac" {
+		return fmt.Errorf("additional issuers are only supported for OIDC providers")
+	}
+	for _, issuer := range c.Issuers {
+		if issuer.Issuer == "" {
+			return fmt.Errorf("issuer URL is required for each entry in Issuers")
+		}
+	}
+
+	// Validate consent page
+	if (c.Consent || c.ConsentStore != nil) && c.Mode != "proxy" {
+		return fmt.Errorf("Consent requires proxy mode")
+	}
+
+	// Validate page templates
+	if c.Templates != nil || c.TemplatesFS != nil {
+		if c.Mode != "proxy" {
+			return fmt.Errorf("Templates requires proxy mode")
+		}
+		if _, err := loadTemplates(c); err != nil {
+			return err
+		}
+	}
+
+	// Validate upstream identity providers
+	if err := validateUpstreamProviders(c); err != nil {
+		return err
+	}
+
+	// Validate native mode metadata
+	if err := validateUpstreamMetadata(c); err != nil {
+		return err
+	}
+
+	// Validate endpoint paths
+	if err := validatePaths(c); err != nil {
+		return err
+	}
+
+	// Validate token vault
+	if c.TokenVault != nil && c.Mode != "proxy" {
+		return fmt.Errorf("TokenVault requires proxy mode")
+	}
+
 	// Validate proxy mode requirements
 	if c.Mode == "proxy" {
 		if c.ClientID == "" {
@@ -124,6 +422,16 @@ func createValidator(cfg *Config, logger Logger) (provider.TokenValidator, error
 		Audience:  cfg.Audience,
 		JWTSecret: cfg.JWTSecret,
 		Logger:    logger,
+
+		Audiences:    tokenAudiences(cfg),
+		Issuers:      trustedIssuers(cfg),
+		ClaimMapping: cfg.ClaimMapping,
+
+		AccessTokenProfile: cfg.AccessTokenProfile,
+		AllowedAlgorithms:  cfg.AllowedAlgorithms,
+		Leeway:             cfg.Leeway,
+		Clock:              cfg.Clock,
+		ClaimPolicy:        cfg.ClaimPolicy,
 	}
 
 	var validator provider.TokenValidator
@@ -131,7 +439,11 @@ func createValidator(cfg *Config, logger Logger) (provider.TokenValidator, error
 	case "hmac":
 		validator = &provider.HMACValidator{}
 	case "okta", "google", "azure":
-		validator = &provider.OIDCValidator{}
+		if len(providerCfg.Issuers) > 0 {
+			validator = &provider.MultiIssuerValidator{}
+		} else {
+			validator = &provider.OIDCValidator{}
+		}
 	default:
 		return nil, fmt.Errorf("unknown OAuth provider: %s", cfg.Provider)
 	}
@@ -199,6 +511,148 @@ func (b *ConfigBuilder) WithAudience(audience string) *ConfigBuilder {
 	return b
 }
 
+// WithAudiences sets additional accepted audiences
+func (b *ConfigBuilder) WithAudiences(audiences ...string) *ConfigBuilder {
+	b.config.Audiences = audiences
+	return b
+}
+
+// WithResources sets additional resource identifiers served by this server
+func (b *ConfigBuilder) WithResources(resources ...string) *ConfigBuilder {
+	b.config.Resources = resources
+	return b
+}
+
+// WithScopes sets the scopes requested from the upstream provider
+func (b *ConfigBuilder) WithScopes(scopes ...string) *ConfigBuilder {
+	b.config.Scopes = scopes
+	return b
+}
+
+// WithScopesSupported sets the scopes advertised in metadata
+func (b *ConfigBuilder) WithScopesSupported(scopes ...string) *ConfigBuilder {
+	b.config.ScopesSupported = scopes
+	return b
+}
+
+// WithStepUp sets the step-up authentication requirement for a tool
+func (b *ConfigBuilder) WithStepUp(tool string, requirement StepUpRequirement) *ConfigBuilder {
+	if b.config.StepUp == nil {
+		b.config.StepUp = make(map[string]StepUpRequirement)
+	}
+	b.config.StepUp[tool] = requirement
+	return b
+}
+
+// WithIssuers sets additional trusted OIDC issuers
+func (b *ConfigBuilder) WithIssuers(issuers ...IssuerConfig) *ConfigBuilder {
+	b.config.Issuers = issuers
+	return b
+}
+
+// WithClaimMapping sets the claim mapping used to populate User fields
+func (b *ConfigBuilder) WithClaimMapping(mapping *ClaimMapping) *ConfigBuilder {
+	b.config.ClaimMapping = mapping
+	return b
+}
+
+// WithAccessTokenProfile sets the enforced JWT access token profile ("rfc9068")
+func (b *ConfigBuilder) WithAccessTokenProfile(profile string) *ConfigBuilder {
+	b.config.AccessTokenProfile = profile
+	return b
+}
+
+// WithAllowedAlgorithms sets the accepted token signing algorithms
+func (b *ConfigBuilder) WithAllowedAlgorithms(algorithms ...string) *ConfigBuilder {
+	b.config.AllowedAlgorithms = algorithms
+	return b
+}
+
+// WithLeeway sets the clock skew tolerated when validating token timestamps
+func (b *ConfigBuilder) WithLeeway(leeway time.Duration) *ConfigBuilder {
+	b.config.Leeway = leeway
+	return b
+}
+
+// WithClaimPolicy sets the required-claims policy
+func (b *ConfigBuilder) WithClaimPolicy(policy ClaimPolicy) *ConfigBuilder {
+	b.config.ClaimPolicy = policy
+	return b
+}
+
+// WithDPoP enables DPoP proof validation ("allowed" or "required")
+func (b *ConfigBuilder) WithDPoP(mode string) *ConfigBuilder {
+	b.config.DPoPMode = mode
+	return b
+}
+
+// WithDPoPSigningAlgorithms sets the accepted DPoP proof signing algorithms
+func (b *ConfigBuilder) WithDPoPSigningAlgorithms(algorithms ...string) *ConfigBuilder {
+	b.config.DPoPSigningAlgorithms = algorithms
+	return b
+}
+
+// WithCertificateBoundTokens requires mutual-TLS certificate-bound tokens
+func (b *ConfigBuilder) WithCertificateBoundTokens(required bool) *ConfigBuilder {
+	b.config.CertificateBoundTokens = required
+	return b
+}
+
+// WithUserInfoEnrichment fills in missing profile claims from the UserInfo endpoint
+func (b *ConfigBuilder) WithUserInfoEnrichment(enabled bool) *ConfigBuilder {
+	b.config.UserInfoEnrichment = enabled
+	return b
+}
+
+// WithPKCEMethods sets the accepted PKCE code challenge methods (default: S256)
+func (b *ConfigBuilder) WithPKCEMethods(methods ...string) *ConfigBuilder {
+	b.config.PKCEMethods = methods
+	return b
+}
+
+// WithUpstreamProviders adds upstream identity providers users can sign in with (proxy mode)
+func (b *ConfigBuilder) WithUpstreamProviders(providers ...UpstreamProvider) *ConfigBuilder {
+	b.config.UpstreamProviders = providers
+	return b
+}
+
+// WithTemplates replaces the built-in HTML pages defined in tmpl (see Config.Templates)
+func (b *ConfigBuilder) WithTemplates(tmpl *template.Template) *ConfigBuilder {
+	b.config.Templates = tmpl
+	return b
+}
+
+// WithTemplatesFS replaces the built-in HTML pages with the *.html files of fsys
+func (b *ConfigBuilder) WithTemplatesFS(fsys fs.FS) *ConfigBuilder {
+	b.config.TemplatesFS = fsys
+	return b
+}
+
+// WithClientRedirectURIs sets the client redirect URI patterns accepted in fixed redirect mode
+func (b *ConfigBuilder) WithClientRedirectURIs(uris ...string) *ConfigBuilder {
+	b.config.ClientRedirectURIs = uris
+	return b
+}
+
+// WithConsent enables the consent page with an optional store (nil uses memory)
+func (b *ConfigBuilder) WithConsent(store ConsentStore) *ConfigBuilder {
+	b.config.Consent = true
+	b.config.ConsentStore = store
+	return b
+}
+
+// WithRequirePushedAuthorizationRequests requires clients to use /oauth/par (RFC 9126)
+func (b *ConfigBuilder) WithRequirePushedAuthorizationRequests(required bool) *ConfigBuilder {
+	b.config.RequirePushedAuthorizationRequests = required
+	return b
+}
+
+// WithTokenVault stores upstream tokens for GetUpstreamToken (proxy mode)
+func (b *ConfigBuilder) WithTokenVault(vault *TokenVault) *ConfigBuilder {
+	b.config.TokenVault = vault
+	return b
+}
+
 // WithClientID sets the client ID
 func (b *ConfigBuilder) WithClientID(clientID string) *ConfigBuilder {
 	b.config.ClientID = clientID
@@ -223,6 +677,36 @@ func (b *ConfigBuilder) WithLogger(logger Logger) *ConfigBuilder {
 	return b
 }
 
+// WithBasePath sets the path prefix of the OAuth endpoints, e.g. "/api/mcp"
+func (b *ConfigBuilder) WithBasePath(basePath string) *ConfigBuilder {
+	b.config.BasePath = basePath
+	return b
+}
+
+// WithPaths overrides individual endpoint paths (relative to BasePath)
+func (b *ConfigBuilder) WithPaths(paths Paths) *ConfigBuilder {
+	b.config.Paths = paths
+	return b
+}
+
+// WithMetadataOverrides customizes the metadata documents
+func (b *ConfigBuilder) WithMetadataOverrides(overrides MetadataOverrides) *ConfigBuilder {
+	b.config.MetadataOverrides = overrides
+	return b
+}
+
+// WithUpstreamMetadata sets how native mode serves the issuer's discovery document ("mirror" or "redirect")
+func (b *ConfigBuilder) WithUpstreamMetadata(mode string) *ConfigBuilder {
+	b.config.UpstreamMetadata = mode
+	return b
+}
+
+// WithUpstreamMetadataRefresh sets how often the mirrored discovery document is revalidated
+func (b *ConfigBuilder) WithUpstreamMetadataRefresh(interval time.Duration) *ConfigBuilder {
+	b.config.UpstreamMetadataRefresh = interval
+	return b
+}
+
 // WithServerURL sets the full server URL directly
 func (b *ConfigBuilder) WithServerURL(url string) *ConfigBuilder {
 	b.config.ServerURL = url
@@ -80,6 +80,18 @@ func TestConfigBuilder(t *testing.T) {
 			wantMode:     "proxy",
 			wantProvider: "okta",
 		},
+		{
+			name: "additional issuers with HMAC returns error",
+			buildFunc: func() (*Config, error) {
+				return NewConfigBuilder().
+					WithProvider("hmac").
+					WithAudience("test-audience").
+					WithJWTSecret([]byte("secret")).
+					WithIssuers(IssuerConfig{Issuer: "https://other.example.com"}).
+					Build()
+			},
+			wantErr: true,
+		},
 		{
 			name: "missing required fields returns error",
 			buildFunc: func() (*Config, error) {
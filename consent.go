@@ -0,0 +1,488 @@
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// consentCookieName identifies the browser session on the consent page
+	consentCookieName = "mcp_oauth_session"
+	// consentRequestLifetime is how long a consent page can be submitted
+	consentRequestLifetime = 10 * time.Minute
+	// consentSessionLifetime is how long a browser session is remembered
+	consentSessionLifetime = 30 * 24 * time.Hour
+	// maxRegisteredClients bounds the client names kept from dynamic registration
+	maxRegisteredClients = 1000
+)
+
+// ConsentStore remembers which scopes a user granted to a client on the
+// proxy's consent page (see Config.Consent). Implementations must be safe for
+// concurrent use; NewMemoryConsentStore is used by default.
+type ConsentStore interface {
+	// GrantedScopes returns the scopes subject granted to client, or nil if
+	// the user has not consented to the client
+	GrantedScopes(ctx context.Context, subject, client string) ([]string, error)
+	// Grant records that subject granted scopes to client
+	Grant(ctx context.Context, subject, client string, scopes []string) error
+}
+
+// MemoryConsentStore is an in-memory ConsentStore. Decisions are lost on restart.
+type MemoryConsentStore struct {
+	mu     sync.RWMutex
+	grants map[string][]string
+}
+
+// NewMemoryConsentStore creates an empty in-memory consent store
+func NewMemoryConsentStore() *MemoryConsentStore {
+	return &MemoryConsentStore{grants: make(map[string][]string)}
+}
+
+// GrantedScopes implements ConsentStore
+func (s *MemoryConsentStore) GrantedScopes(_ context.Context, subject, client string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.grants[subject+"\x00"+client], nil
+}
+
+// Grant implements ConsentStore. Scopes add to those granted earlier.
+func (s *MemoryConsentStore) Grant(_ context.Context, subject, client string, scopes []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := subject + "\x00" + client
+	granted := append([]string(nil), s.grants[key]...)
+	for _, scope := range scopes {
+		if !slices.Contains(granted, scope) {
+			granted = append(granted, scope)
+		}
+	}
+	s.grants[key] = granted
+	return nil
+}
+
+// pendingConsent is an authorization request waiting for the user's decision
+type pendingConsent struct {
+	request   *authorizationRequest
+	client    string
+	scopes    []string
+	sessionID string
+	csrfToken string
+	expiresAt time.Time
+}
+
+// consentSession is a browser session on the consent page. Approvals are
+// kept here until the token exchange reveals the user's subject.
+type consentSession struct {
+	subject     string
+	approvals   map[string][]string
+	logoutToken string // CSRF token of the logout confirmation form
+	clientID    string // Client of the last authorization request sent to the provider
+	client      string // Client key of that request
+	expiresAt   time.Time
+}
+
+// expiringValue is a map value with an expiry time
+type expiringValue struct {
+	value     string
+	expiresAt time.Time
+}
+
+// consentManager implements the consent page (see Config.Consent)
+type consentManager struct {
+	store ConsentStore
+
+	mu       sync.Mutex
+	pending  map[string]*pendingConsent
+	sessions map[string]*consentSession
+	codes    map[string]expiringValue // authorization code key -> session ID
+	clients  map[string]expiringValue // client key -> registered client_name
+}
+
+// newConsentManager creates the consent page state, or nil if consent is disabled
+func newConsentManager(cfg *OAuth2Config) *consentManager {
+	if !cfg.Consent {
+		return nil
+	}
+	store := cfg.ConsentStore
+	if store == nil {
+		store = NewMemoryConsentStore()
+	}
+	return &consentManager{
+		store:    store,
+		pending:  make(map[string]*pendingConsent),
+		sessions: make(map[string]*consentSession),
+		codes:    make(map[string]expiringValue),
+		clients:  make(map[string]expiringValue),
+	}
+}
+
+// consentClientKey identifies a client by client_id and redirect URI. The
+// port of loopback redirect URIs is ignored because native clients pick a
+// new one for every authorization (RFC 8252 Section 7.3).
+func consentClientKey(clientID, redirectURI string) string {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		return clientID + " " + redirectURI
+	}
+	host := strings.ToLower(u.Host)
+	if isLoopbackHost(strings.ToLower(u.Hostname())) {
+		host = strings.ToLower(u.Hostname())
+	}
+	return fmt.Sprintf("%s %s://%s%s", clientID, u.Scheme, host, u.Path)
+}
+
+// redirectHost returns the destination shown on the consent page
+func redirectHost(redirectURI string) string {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		return redirectURI
+	}
+	if u.Host == "" {
+		return u.Scheme + ":"
+	}
+	if isLoopbackHost(strings.ToLower(u.Hostname())) {
+		return u.Hostname() + " (this computer)"
+	}
+	return u.Host
+}
+
+// randomToken returns a random URL-safe token
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// session returns the unexpired session of the request's cookie, if any.
+// Callers must hold c.mu.
+func (c *consentManager) session(r *http.Request) (string, *consentSession) {
+	cookie, err := r.Cookie(consentCookieName)
+	if err != nil {
+		return "", nil
+	}
+	session, ok := c.sessions[cookie.Value]
+	if !ok || time.Now().After(session.expiresAt) {
+		return "", nil
+	}
+	return cookie.Value, session
+}
+
+// removeExpired drops expired entries. Callers must hold c.mu.
+func (c *consentManager) removeExpired(now time.Time) {
+	for key, pending := range c.pending {
+		if now.After(pending.expiresAt) {
+			delete(c.pending, key)
+		}
+	}
+	for key, session := range c.sessions {
+		if now.After(session.expiresAt) {
+			delete(c.sessions, key)
+		}
+	}
+	for key, code := range c.codes {
+		if now.After(code.expiresAt) {
+			delete(c.codes, key)
+		}
+	}
+	for key, client := range c.clients {
+		if now.After(client.expiresAt) {
+			delete(c.clients, key)
+		}
+	}
+}
+
+// hasConsent reports whether the signed-in user of this browser already
+// granted the requested scopes to the client
+func (c *consentManager) hasConsent(r *http.Request, client string, scopes []string, prompt string) bool {
+	if slices.Contains(strings.Fields(prompt), "consent") {
+		return false
+	}
+
+	c.mu.Lock()
+	_, session := c.session(r)
+	subject := ""
+	if session != nil {
+		subject = session.subject
+	}
+	c.mu.Unlock()
+	if subject == "" {
+		return false
+	}
+
+	granted, err := c.store.GrantedScopes(r.Context(), subject, client)
+	if err != nil || granted == nil {
+		return false
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(granted, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+// begin stores a pending consent request and returns its ID, CSRF token and
+// the session ID to set as cookie
+func (c *consentManager) begin(r *http.Request, request *authorizationRequest, client string, scopes []string) (*pendingConsent, string, error) {
+	consentID, err := randomToken()
+	if err != nil {
+		return nil, "", err
+	}
+	csrfToken, err := randomToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.removeExpired(now)
+
+	sessionID, session := c.session(r)
+	if session == nil {
+		if sessionID, err = randomToken(); err != nil {
+			return nil, "", err
+		}
+		session = &consentSession{approvals: make(map[string][]string)}
+		c.sessions[sessionID] = session
+	}
+	session.expiresAt = now.Add(consentSessionLifetime)
+
+	pending := &pendingConsent{
+		request:   request,
+		client:    client,
+		scopes:    scopes,
+		sessionID: sessionID,
+		csrfToken: csrfToken,
+		expiresAt: now.Add(consentRequestLifetime),
+	}
+	c.pending[consentID] = pending
+	return pending, consentID, nil
+}
+
+// take removes and returns the pending consent request if the form was
+// submitted from the browser session that received it
+func (c *consentManager) take(r *http.Request, consentID, csrfToken string) (*pendingConsent, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	pending, ok := c.pending[consentID]
+	if !ok || time.Now().After(pending.expiresAt) {
+		delete(c.pending, consentID)
+		return nil, fmt.Errorf("unknown or expired consent request")
+	}
+	sessionID, session := c.session(r)
+	if session == nil || sessionID != pending.sessionID {
+		return nil, fmt.Errorf("consent request belongs to another browser session")
+	}
+	if subtle.ConstantTimeCompare([]byte(csrfToken), []byte(pending.csrfToken)) != 1 {
+		return nil, fmt.Errorf("invalid CSRF token")
+	}
+	delete(c.pending, consentID)
+	return pending, nil
+}
+
+// approve records an approval in the browser session until the subject is known
+func (c *consentManager) approve(pending *pendingConsent) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if session, ok := c.sessions[pending.sessionID]; ok {
+		session.approvals[pending.client] = pending.scopes
+	}
+}
+
+// bindCode links an authorization code to the browser session at the callback
+func (c *consentManager) bindCode(r *http.Request, code string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	sessionID, session := c.session(r)
+	if session == nil || len(session.approvals) == 0 {
+		return
+	}
+	c.codes[codeKey(code)] = expiringValue{value: sessionID, expiresAt: time.Now().Add(pkceTransactionLifetime)}
+}
+
+// complete stores the approvals of the session bound to code for the user
+// who redeemed it, and remembers that user for the session
+func (c *consentManager) complete(ctx context.Context, code, subject string) error {
+	if subject == "" {
+		return nil
+	}
+
+	c.mu.Lock()
+	binding, ok := c.codes[codeKey(code)]
+	delete(c.codes, codeKey(code))
+	var approvals map[string][]string
+	if session, exists := c.sessions[binding.value]; ok && exists {
+		session.subject = subject
+		approvals = session.approvals
+		session.approvals = make(map[string][]string)
+	}
+	c.mu.Unlock()
+
+	for client, scopes := range approvals {
+		if err := c.store.Grant(ctx, subject, client, scopes); err != nil {
+			return fmt.Errorf("failed to store consent: %w", err)
+		}
+	}
+	return nil
+}
+
+// registerClient remembers the client_name of a dynamically registered client
+func (c *consentManager) registerClient(clientID string, redirectURIs []string, name string) {
+	if name == "" {
+		return
+	}
+
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.removeExpired(now)
+
+	for _, redirectURI := range redirectURIs {
+		if len(c.clients) >= maxRegisteredClients {
+			return
+		}
+		c.clients[consentClientKey(clientID, redirectURI)] = expiringValue{value: name, expiresAt: now.Add(consentSessionLifetime)}
+	}
+}
+
+// startAuthorization remembers the client of an authorization request sent
+// to the provider from the request's browser session, for the success page
+func (c *consentManager) startAuthorization(r *http.Request, clientID, client string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, session := c.session(r); session != nil {
+		session.clientID, session.client = clientID, client
+	}
+}
+
+// sessionPageData returns the client of the last authorization request of the
+// request's browser session and the user who signed in with it, if known
+func (c *consentManager) sessionPageData(r *http.Request) PageData {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, session := c.session(r)
+	if session == nil {
+		return PageData{}
+	}
+	return PageData{
+		ClientID:   session.clientID,
+		ClientName: c.clients[session.client].value,
+		User:       keySubject(session.subject),
+	}
+}
+
+// clientName returns the registered client_name for a client key
+func (c *consentManager) clientName(client string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.clients[client].value
+}
+
+// consentScopes returns the scopes shown on the consent page and remembered
+// for the request (the requested scopes, or the scopes requested upstream by default)
+func (h *OAuth2Handler) consentScopes(req *authorizationRequest) []string {
+	if scopes := strings.Fields(req.scope); len(scopes) > 0 {
+		return scopes
+	}
+	return h.oauth2Config.Scopes
+}
+
+// showConsentPage asks the user to approve the authorization request
+func (h *OAuth2Handler) showConsentPage(w http.ResponseWriter, r *http.Request, req *authorizationRequest) {
+	client := consentClientKey(req.clientID, req.clientRedirectURI)
+	scopes := h.consentScopes(req)
+
+	pending, consentID, err := h.consent.begin(r, req, client, scopes)
+	if err != nil {
+		h.logger.Error("OAuth2: Failed to start consent: %v", err)
+		redirectOAuthError(w, r, req.clientRedirectURI, req.state, &OAuthError{Code: "server_error", Description: "Internal server error"})
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     consentCookieName,
+		Value:    pending.sessionID,
+		Path:     h.cookiePath(),
+		MaxAge:   int(consentSessionLifetime.Seconds()),
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(h.config.MCPURL, "https://"),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	data := PageData{
+		ClientName:   h.consent.clientName(client),
+		ClientID:     req.clientID,
+		RedirectHost: redirectHost(req.clientRedirectURI),
+		Scopes:       scopes,
+		Action:       h.endpointURL(h.paths().Consent),
+		ConsentID:    consentID,
+		CSRFToken:    pending.csrfToken,
+	}
+
+	h.logger.Info("OAuth2: Showing consent page for client %s", client)
+	h.renderPage(w, http.StatusOK, consentPageTemplate, data)
+}
+
+// HandleConsent handles the consent page form (see Config.Consent). Approved
+// requests continue to the provider; "switch_account" also asks the provider
+// to show its account chooser (prompt=select_account); denied requests return
+// access_denied to the client.
+func (h *OAuth2Handler) HandleConsent(w http.ResponseWriter, r *http.Request) {
+	if h.consent == nil {
+		h.writeErrorPage(w, r, http.StatusNotFound, "invalid_request", "Consent page is not enabled")
+		return
+	}
+	if r.Method != "POST" {
+		h.writeErrorPage(w, r, http.StatusMethodNotAllowed, "invalid_request", "Method not allowed")
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		h.writeErrorPage(w, r, http.StatusBadRequest, "invalid_request", "Invalid request")
+		return
+	}
+
+	pending, err := h.consent.take(r, r.PostForm.Get("consent_id"), r.PostForm.Get("csrf_token"))
+	if err != nil {
+		h.logger.Warn("SECURITY: Rejected consent form from %s: %v", r.RemoteAddr, err)
+		h.writeErrorPage(w, r, http.StatusBadRequest, "invalid_request", "Invalid or expired consent request")
+		return
+	}
+	req := pending.request
+
+	switch r.PostForm.Get("action") {
+	case "approve":
+	case "switch_account":
+		// Copy so the stored request is not modified
+		switched := *req
+		if !slices.Contains(strings.Fields(switched.prompt), "select_account") {
+			switched.prompt = strings.TrimSpace(switched.prompt + " select_account")
+		}
+		req = &switched
+	default:
+		h.logger.Info("OAuth2: User denied consent for client %s", pending.client)
+		redirectOAuthError(w, r, req.clientRedirectURI, req.state, &OAuthError{Code: "access_denied", Description: "The user denied the request"})
+		return
+	}
+
+	h.logger.Info("OAuth2: User approved consent for client %s", pending.client)
+	h.consent.approve(pending)
+	h.redirectToProvider(w, r, req)
+}
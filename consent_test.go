@@ -0,0 +1,207 @@
+package oauth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	consentIDPattern = regexp.MustCompile(`name="consent_id" value="([^"]+)"`)
+	csrfTokenPattern = regexp.MustCompile(`name="csrf_token" value="([^"]+)"`)
+)
+
+// consentTestFlow drives the consent page of a fixed redirect mode proxy in one browser
+type consentTestFlow struct {
+	t       *testing.T
+	handler *OAuth2Handler
+	cookie  *http.Cookie
+}
+
+// newConsentTestFlow creates a fixed redirect mode proxy with the consent page enabled
+func newConsentTestFlow(t *testing.T, store ConsentStore) *consentTestFlow {
+	t.Helper()
+
+	accessToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "user-1"}).SignedString([]byte("upstream-key"))
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"access_token": accessToken, "token_type": "Bearer", "expires_in": 3600})
+	}))
+	t.Cleanup(upstream.Close)
+
+	handler := NewOAuth2Handler(&OAuth2Config{
+		Mode:            "proxy",
+		Provider:        "hmac",
+		Issuer:          upstream.URL,
+		ClientID:        "client-id",
+		RedirectURIs:    "https://mcp.example.com/oauth/callback",
+		MCPURL:          "https://mcp.example.com",
+		Scopes:          []string{"openid", "email", "profile"},
+		Consent:         true,
+		ConsentStore:    store,
+		stateSigningKey: []byte("test-state-signing-key"),
+	}, nil)
+	return &consentTestFlow{t: t, handler: handler}
+}
+
+// do sends a request with the browser's session cookie and keeps any new one
+func (f *consentTestFlow) do(handler http.HandlerFunc, req *http.Request) *httptest.ResponseRecorder {
+	if f.cookie != nil {
+		req.AddCookie(f.cookie)
+	}
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == consentCookieName {
+			f.cookie = cookie
+		}
+	}
+	return rec
+}
+
+// authorize starts an authorization request for the client
+func (f *consentTestFlow) authorize(extra url.Values) *httptest.ResponseRecorder {
+	params := url.Values{"client_id": {"client-id"}, "redirect_uri": {"http://localhost:3000/callback"}, "state": {"client-state"}, "scope": {"openid email"}}
+	setTestCodeChallenge(params)
+	for key, values := range extra {
+		params[key] = values
+	}
+	return f.do(f.handler.HandleAuthorize, httptest.NewRequest(http.MethodGet, "/oauth/authorize?"+params.Encode(), nil))
+}
+
+// submit posts the consent form rendered in page
+func (f *consentTestFlow) submit(page *httptest.ResponseRecorder, action string) *httptest.ResponseRecorder {
+	f.t.Helper()
+
+	consentID := consentIDPattern.FindStringSubmatch(page.Body.String())
+	csrfToken := csrfTokenPattern.FindStringSubmatch(page.Body.String())
+	if consentID == nil || csrfToken == nil {
+		f.t.Fatalf("Consent form fields not found in page: %s", page.Body.String())
+	}
+	form := url.Values{"consent_id": {consentID[1]}, "csrf_token": {csrfToken[1]}, "action": {action}}
+	req := httptest.NewRequest(http.MethodPost, "/oauth/consent", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return f.do(f.handler.HandleConsent, req)
+}
+
+// complete runs the provider callback and token exchange for a redirect to the provider
+func (f *consentTestFlow) complete(rec *httptest.ResponseRecorder, code string) {
+	f.t.Helper()
+
+	if rec.Code != http.StatusTemporaryRedirect {
+		f.t.Fatalf("Expected redirect to the provider, got %d: %s", rec.Code, rec.Body.String())
+	}
+	location, _ := url.Parse(rec.Header().Get("Location"))
+	callback := url.Values{"code": {code}, "state": {location.Query().Get("state")}}
+	if rec := f.do(f.handler.HandleCallback, httptest.NewRequest(http.MethodGet, "/oauth/callback?"+callback.Encode(), nil)); rec.Code != http.StatusFound {
+		f.t.Fatalf("Expected callback redirect, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	form := url.Values{"grant_type": {"authorization_code"}, "code": {code}, "redirect_uri": {"http://localhost:3000/callback"}, "code_verifier": {testCodeVerifier}}
+	if rec := postForm(f.handler.HandleToken, "/oauth/token", form); rec.Code != http.StatusOK {
+		f.t.Fatalf("Token exchange failed: %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+// TestConsentPage tests showing, approving and remembering consent
+func TestConsentPage(t *testing.T) {
+	store := NewMemoryConsentStore()
+	flow := newConsentTestFlow(t, store)
+
+	body, _ := json.Marshal(map[string]interface{}{"client_name": "<b>Inspector</b>", "redirect_uris": []string{"http://localhost:3000/callback"}})
+	if rec := flow.do(flow.handler.HandleRegister, httptest.NewRequest(http.MethodPost, "/oauth/register", bytes.NewReader(body))); rec.Code != http.StatusCreated {
+		t.Fatalf("Registration failed: %d %s", rec.Code, rec.Body.String())
+	}
+
+	page := flow.authorize(nil)
+	if page.Code != http.StatusOK {
+		t.Fatalf("Expected consent page, got %d: %s", page.Code, page.Body.String())
+	}
+	html := page.Body.String()
+	for _, expected := range []string{"&lt;b&gt;Inspector&lt;/b&gt;", "localhost (this computer)", "<li>openid</li>", "<li>email</li>"} {
+		if !strings.Contains(html, expected) {
+			t.Errorf("Expected consent page to contain %q: %s", expected, html)
+		}
+	}
+	if page.Header().Get("X-Frame-Options") != "DENY" || !strings.Contains(page.Header().Get("Content-Security-Policy"), "frame-ancestors 'none'") {
+		t.Error("Expected anti-framing headers on the consent page")
+	}
+	if flow.cookie == nil || !flow.cookie.HttpOnly || !flow.cookie.Secure {
+		t.Fatalf("Expected a secure session cookie, got %+v", flow.cookie)
+	}
+
+	flow.complete(flow.submit(page, "approve"), "code-1")
+
+	granted, _ := store.GrantedScopes(context.Background(), subjectKey(flow.handler.config.Issuer, "user-1"), consentClientKey("client-id", "http://localhost:3000/callback"))
+	if len(granted) != 2 {
+		t.Fatalf("Expected consent to be stored for the subject, got %v", granted)
+	}
+
+	// The same browser is not asked again, even on another loopback port
+	rec := flow.authorize(url.Values{"redirect_uri": {"http://localhost:4000/callback"}})
+	if rec.Code != http.StatusTemporaryRedirect {
+		t.Errorf("Expected remembered consent to skip the page, got %d", rec.Code)
+	}
+
+	// prompt=consent and new scopes show the page again
+	if rec := flow.authorize(url.Values{"prompt": {"consent"}}); rec.Code != http.StatusOK {
+		t.Errorf("Expected prompt=consent to show the page, got %d", rec.Code)
+	}
+	if rec := flow.authorize(url.Values{"scope": {"openid email profile"}}); rec.Code != http.StatusOK {
+		t.Errorf("Expected additional scopes to show the page, got %d", rec.Code)
+	}
+}
+
+// TestConsentPage_Decisions tests denying, switching accounts and CSRF protection
+func TestConsentPage_Decisions(t *testing.T) {
+	flow := newConsentTestFlow(t, nil)
+
+	rec := flow.submit(flow.authorize(nil), "deny")
+	if redirectedErrorCode(rec) != "access_denied" || !strings.HasPrefix(rec.Header().Get("Location"), "http://localhost:3000/callback?") {
+		t.Errorf("Expected access_denied redirect to the client, got %d: %s", rec.Code, rec.Header().Get("Location"))
+	}
+
+	rec = flow.submit(flow.authorize(url.Values{"prompt": {"login"}}), "switch_account")
+	if rec.Code != http.StatusTemporaryRedirect {
+		t.Fatalf("Expected redirect to the provider, got %d: %s", rec.Code, rec.Body.String())
+	}
+	location, _ := url.Parse(rec.Header().Get("Location"))
+	if prompt := location.Query().Get("prompt"); prompt != "login select_account" {
+		t.Errorf("Expected account selection prompt, got %q", prompt)
+	}
+
+	// The form is single use and bound to the browser session
+	page := flow.authorize(nil)
+	if rec := flow.submit(page, "approve"); rec.Code != http.StatusTemporaryRedirect {
+		t.Fatalf("Expected approval, got %d", rec.Code)
+	}
+	if rec := flow.submit(page, "approve"); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected replayed form to be rejected, got %d", rec.Code)
+	}
+
+	page = flow.authorize(nil)
+	flow.cookie = &http.Cookie{Name: consentCookieName, Value: "another-browser"}
+	if rec := flow.submit(page, "approve"); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected form from another session to be rejected, got %d", rec.Code)
+	}
+
+	// Disabled consent page and native mode
+	if rec := postForm(newPARTestHandler(false).HandleConsent, "/oauth/consent", url.Values{}); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 without Consent, got %d", rec.Code)
+	}
+	cfg := &Config{Mode: "native", Provider: "hmac", Audience: "api://test", JWTSecret: []byte("secret"), Consent: true}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected Consent to require proxy mode")
+	}
+}
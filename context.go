@@ -1,13 +1,27 @@
 package oauth
 
-import "context"
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"strings"
+)
 
 // Context keys
 type contextKey string
 
 const (
-	oauthTokenKey  contextKey = "oauth_token"
-	userContextKey contextKey = "user"
+	oauthTokenKey      contextKey = "oauth_token"
+	userContextKey     contextKey = "user"
+	requestInfoKey     contextKey = "request_info"
+	tokenBindingCtxKey contextKey = "token_binding_verified"
+	serverContextKey   contextKey = "oauth_server"
+)
+
+// Authorization header schemes
+const (
+	authSchemeBearer = "Bearer"
+	authSchemeDPoP   = "DPoP"
 )
 
 // WithOAuthToken adds an OAuth token to the context
@@ -43,3 +57,68 @@ func GetUserFromContext(ctx context.Context) (*User, bool) {
 	user, ok := ctx.Value(userContextKey).(*User)
 	return user, ok
 }
+
+// requestInfo captures the parts of the HTTP request needed to verify
+// sender-constrained tokens (DPoP proofs) after the request has been reduced
+// to a context, e.g. inside MCP tool middleware.
+type requestInfo struct {
+	method         string
+	host           string
+	path           string
+	forwardedProto string
+	tls            *tls.ConnectionState
+	authScheme     string
+	dpopProofs     []string
+}
+
+// newRequestInfo snapshots the request details used for token binding checks
+func newRequestInfo(r *http.Request, authScheme string) *requestInfo {
+	info := &requestInfo{
+		method:         r.Method,
+		host:           r.Host,
+		forwardedProto: r.Header.Get("X-Forwarded-Proto"),
+		tls:            r.TLS,
+		authScheme:     authScheme,
+		dpopProofs:     r.Header.Values("DPoP"),
+	}
+	if r.URL != nil {
+		info.path = r.URL.Path
+	}
+	return info
+}
+
+// withRequestInfo adds request details to the context
+func withRequestInfo(ctx context.Context, info *requestInfo) context.Context {
+	return context.WithValue(ctx, requestInfoKey, info)
+}
+
+// getRequestInfo extracts request details from the context
+func getRequestInfo(ctx context.Context) (*requestInfo, bool) {
+	info, ok := ctx.Value(requestInfoKey).(*requestInfo)
+	return info, ok
+}
+
+// withTokenBindingVerified records that the sender constraint of token was
+// already verified for this request, so nested middleware does not verify
+// (and replay-check) the same DPoP proof twice
+func withTokenBindingVerified(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, tokenBindingCtxKey, token)
+}
+
+// tokenBindingVerified reports whether the token binding was verified upstream in the chain
+func tokenBindingVerified(ctx context.Context, token string) bool {
+	verified, ok := ctx.Value(tokenBindingCtxKey).(string)
+	return ok && verified == token
+}
+
+// parseAuthorizationHeader splits an Authorization header into scheme and token.
+// Only the Bearer and DPoP schemes are recognized (case-insensitive).
+func parseAuthorizationHeader(header string) (scheme, token string) {
+	for _, candidate := range []string{authSchemeBearer, authSchemeDPoP} {
+		prefix := candidate + " "
+		if len(header) > len(prefix) && strings.EqualFold(header[:len(prefix)], prefix) {
+			return candidate, strings.TrimSpace(header[len(prefix):])
+		}
+	}
+	return "", ""
+}
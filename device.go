@@ -0,0 +1,242 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// grantTypeDeviceCode is the RFC 8628 device authorization grant type
+const grantTypeDeviceCode = "urn:ietf:params:oauth:grant-type:device_code"
+
+// deviceAuthorizationResponse is the RFC 8628 device authorization response
+type deviceAuthorizationResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete,omitempty"`
+	ExpiresIn               int64  `json:"expires_in"`
+	Interval                int64  `json:"interval,omitempty"`
+}
+
+// supportsDeviceAuthorization reports whether the upstream provider has a device authorization endpoint
+func (h *OAuth2Handler) supportsDeviceAuthorization() bool {
+	return h.config.Mode == "proxy" && h.oauth2Config != nil && h.oauth2Config.Endpoint.DeviceAuthURL != ""
+}
+
+// HandleDeviceAuthorization handles the device authorization endpoint (RFC 8628)
+// for clients that cannot receive a browser redirect. The request is proxied to
+// the upstream provider's device endpoint with the server's client credentials;
+// the client then polls /oauth/token with the device_code grant.
+//
+// The device flow always uses the primary provider (Config.Provider and
+// Config.Issuer); an idp parameter naming one of Config.UpstreamProviders is
+// rejected.
+func (h *OAuth2Handler) HandleDeviceAuthorization(w http.ResponseWriter, r *http.Request) {
+	// Defense in depth: Check OAuth mode
+	if h.config.Mode == "native" {
+		writeOAuthError(w, http.StatusNotFound, "invalid_request", "OAuth proxy disabled in native mode")
+		return
+	}
+
+	// Add CORS headers for browser-based MCP clients
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Authorization, *")
+	w.Header().Set("Access-Control-Max-Age", "86400")
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "POST" {
+		writeOAuthError(w, http.StatusMethodNotAllowed, "invalid_request", "Method not allowed")
+		return
+	}
+
+	if !h.supportsDeviceAuthorization() {
+		writeOAuthError(w, http.StatusNotFound, "invalid_request", "Device authorization is not supported by the upstream provider")
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		h.logger.Error("OAuth2: Failed to parse form: %v", err)
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "Invalid request")
+		return
+	}
+
+	// The device_code polled at /oauth/token does not say which provider issued
+	// it, so the flow is limited to the primary provider
+	if idp := r.FormValue("idp"); idp != "" && h.upstream(idp) != h.upstream("") {
+		h.logger.Warn("OAuth2: Device authorization requested for upstream provider %s", idp)
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "Device authorization is only supported with the primary identity provider")
+		return
+	}
+
+	scope := r.FormValue("scope")
+	resources := r.Form["resource"]
+
+	h.logger.Info("OAuth2: Device authorization request from %s - scope: %s, resource: %v", r.RemoteAddr, scope, resources)
+
+	if err := h.validateRequestedScope(scope); err != nil {
+		h.logger.Warn("SECURITY: Invalid scope parameter: %v", err)
+		writeOAuthError(w, http.StatusBadRequest, "invalid_scope", "Invalid scope")
+		return
+	}
+	if err := h.validateResources(resources); err != nil {
+		h.logger.Warn("SECURITY: Invalid resource parameter: %v", err)
+		writeOAuthError(w, http.StatusBadRequest, "invalid_target", "Invalid resource parameter")
+		return
+	}
+
+	if scope == "" {
+		scope = strings.Join(h.scopes(), " ")
+	}
+	form := url.Values{"scope": {scope}, "resource": resources}
+
+	status, body, err := h.postUpstreamForm(r.Context(), h.oauth2Config.Endpoint.DeviceAuthURL, form)
+	if err != nil {
+		h.logger.Error("OAuth2: Device authorization request failed: %v", err)
+		writeOAuthError(w, http.StatusBadGateway, "server_error", "Device authorization failed")
+		return
+	}
+	if status != http.StatusOK {
+		h.logger.Error("OAuth2: Upstream device authorization failed (status %d)", status)
+		relayUpstreamError(w, status, body)
+		return
+	}
+
+	// Google returns verification_url instead of verification_uri
+	var upstream struct {
+		deviceAuthorizationResponse
+		VerificationURL string `json:"verification_url"`
+	}
+	if err := json.Unmarshal(body, &upstream); err != nil || upstream.DeviceCode == "" {
+		h.logger.Error("OAuth2: Invalid upstream device authorization response: %v", err)
+		writeOAuthError(w, http.StatusBadGateway, "server_error", "Device authorization failed")
+		return
+	}
+	response := upstream.deviceAuthorizationResponse
+	if response.VerificationURI == "" {
+		response.VerificationURI = upstream.VerificationURL
+	}
+
+	h.logger.Info("OAuth2: Device authorization successful - user_code: %s", response.UserCode)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.logger.Error("OAuth2: Failed to encode device authorization response: %v", err)
+	}
+}
+
+// handleDeviceCodeToken handles the device_code grant of the token endpoint.
+// Pending, slow_down, denied and expired responses from the upstream provider
+// are relayed unchanged so that the client's polling loop sees them.
+func (h *OAuth2Handler) handleDeviceCodeToken(w http.ResponseWriter, r *http.Request) {
+	if !h.supportsDeviceAuthorization() {
+		writeOAuthError(w, http.StatusBadRequest, "unsupported_grant_type", "Device authorization is not supported")
+		return
+	}
+
+	deviceCode := r.FormValue("device_code")
+	if deviceCode == "" {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "Missing device_code")
+		return
+	}
+	resources := r.Form["resource"]
+	if err := h.validateResources(resources); err != nil {
+		h.logger.Warn("SECURITY: Invalid resource parameter: %v", err)
+		writeOAuthError(w, http.StatusBadRequest, "invalid_target", "Invalid resource parameter")
+		return
+	}
+
+	form := url.Values{
+		"grant_type":  {grantTypeDeviceCode},
+		"device_code": {deviceCode},
+		"resource":    resources,
+	}
+	status, body, err := h.postUpstreamForm(r.Context(), h.oauth2Config.Endpoint.TokenURL, form)
+	if err != nil {
+		h.logger.Error("OAuth2: Device token request failed: %v", err)
+		writeOAuthError(w, http.StatusBadGateway, "server_error", "Token exchange failed")
+		return
+	}
+	if status != http.StatusOK {
+		relayUpstreamError(w, status, body)
+		return
+	}
+
+	var result struct {
+		AccessToken  string `json:"access_token"`
+		TokenType    string `json:"token_type"`
+		RefreshToken string `json:"refresh_token"`
+		ExpiresIn    int64  `json:"expires_in"`
+		IDToken      string `json:"id_token"`
+		Scope        string `json:"scope"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil || result.AccessToken == "" {
+		h.logger.Error("OAuth2: Invalid upstream device token response: %v", err)
+		writeOAuthError(w, http.StatusBadGateway, "server_error", "Token exchange failed")
+		return
+	}
+
+	h.logger.Info("OAuth2: Device token exchange successful")
+
+	token := &oauth2.Token{
+		AccessToken:  result.AccessToken,
+		TokenType:    result.TokenType,
+		RefreshToken: result.RefreshToken,
+	}
+	if result.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(result.ExpiresIn) * time.Second)
+	}
+	extra := map[string]interface{}{}
+	if result.IDToken != "" {
+		extra["id_token"] = result.IDToken
+	}
+	if result.Scope != "" {
+		extra["scope"] = result.Scope
+	}
+	h.writeTokenResponse(w, h.upstream(""), token.WithExtra(extra))
+}
+
+// postUpstreamForm posts form to an upstream endpoint with the server's client
+// credentials and returns the status code and body
+func (h *OAuth2Handler) postUpstreamForm(ctx context.Context, endpoint string, form url.Values) (int, []byte, error) {
+	form.Set("client_id", h.oauth2Config.ClientID)
+	if h.oauth2Config.ClientSecret != "" {
+		form.Set("client_secret", h.oauth2Config.ClientSecret)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := h.upstreamClient().Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	return resp.StatusCode, body, nil
+}
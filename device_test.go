@@ -0,0 +1,160 @@
+package oauth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// deviceTestConfig uses an upstream provider that supports device authorization
+func deviceTestConfig(cfg *Config) {
+	cfg.Provider = "okta"
+}
+
+// postForm sends a form POST to an OAuth2Handler endpoint
+func postForm(handler http.HandlerFunc, path string, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec
+}
+
+// TestDeviceAuthorizationGrant tests proxying the RFC 8628 flow to the upstream provider
+func TestDeviceAuthorizationGrant(t *testing.T) {
+	approved := false
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		w.Header().Set("Content-Type", "application/json")
+		if r.PostForm.Get("client_id") != "mcp-server" || r.PostForm.Get("client_secret") != "mcp-secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+			return
+		}
+		switch r.URL.Path {
+		case "/oauth2/v1/device/authorize":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"device_code":      "device-123",
+				"user_code":        "ABCD-EFGH",
+				"verification_url": "https://idp.example.com/device",
+				"expires_in":       600,
+				"interval":         5,
+				"scope_received":   r.PostForm.Get("scope"),
+			})
+		case "/oauth2/v1/token":
+			if r.PostForm.Get("grant_type") != grantTypeDeviceCode || r.PostForm.Get("device_code") != "device-123" {
+				w.WriteHeader(http.StatusBadRequest)
+				_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+				return
+			}
+			if !approved {
+				w.WriteHeader(http.StatusBadRequest)
+				_ = json.NewEncoder(w).Encode(map[string]string{"error": "authorization_pending"})
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"access_token": "device-access-token",
+				"token_type":   "Bearer",
+				"expires_in":   3600,
+				"id_token":     "device-id-token",
+			})
+		}
+	}, deviceTestConfig)
+
+	rec := postForm(server.handler.HandleDeviceAuthorization, "/oauth/device_authorization", url.Values{"client_id": {"cli"}, "scope": {"openid email"}})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Device authorization failed: %d %s", rec.Code, rec.Body.String())
+	}
+	var authorization deviceAuthorizationResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &authorization); err != nil {
+		t.Fatalf("Invalid response: %v", err)
+	}
+	if authorization.DeviceCode != "device-123" || authorization.UserCode != "ABCD-EFGH" ||
+		authorization.VerificationURI != "https://idp.example.com/device" || authorization.Interval != 5 {
+		t.Errorf("Unexpected device authorization response: %+v", authorization)
+	}
+
+	poll := func() *httptest.ResponseRecorder {
+		return postForm(server.handler.HandleToken, "/oauth/token", url.Values{"grant_type": {grantTypeDeviceCode}, "device_code": {"device-123"}})
+	}
+
+	rec = poll()
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), `"authorization_pending"`) {
+		t.Errorf("Expected authorization_pending to be relayed, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	approved = true
+	rec = poll()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected token after approval, got %d %s", rec.Code, rec.Body.String())
+	}
+	var token map[string]interface{}
+	_ = json.Unmarshal(rec.Body.Bytes(), &token)
+	if token["access_token"] != "device-access-token" || token["id_token"] != "device-id-token" {
+		t.Errorf("Unexpected token response: %v", token)
+	}
+}
+
+// TestDeviceAuthorizationGrant_HTTPClient tests that upstream requests use the handler's HTTP client
+func TestDeviceAuthorizationGrant_HTTPClient(t *testing.T) {
+	server := newTestServer(t, http.NotFound, deviceTestConfig)
+	server.handler.httpClient = &http.Client{Transport: handlerTransport{http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"device_code": "device-123", "user_code": "ABCD-EFGH", "verification_uri": "https://idp.example.com/device", "expires_in": 600})
+	})}}
+
+	rec := postForm(server.handler.HandleDeviceAuthorization, "/oauth/device_authorization", url.Values{"client_id": {"cli"}})
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected the device authorization request to use the handler's client, got %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+// TestDeviceAuthorizationGrant_UpstreamProviders tests that the device flow is limited to the primary provider
+func TestDeviceAuthorizationGrant_UpstreamProviders(t *testing.T) {
+	var googleTokens int32
+	google := newTestIdP(t, "google-token", &googleTokens)
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"device_code": "device-123", "user_code": "ABCD-EFGH", "verification_uri": "https://idp.example.com/device", "expires_in": 600})
+	}, func(cfg *Config) {
+		deviceTestConfig(cfg)
+		cfg.UpstreamProviders = []UpstreamProvider{{Name: "google", Issuer: google.URL, ClientID: "google-client"}}
+	})
+
+	for idp, expected := range map[string]int{"": http.StatusOK, "okta": http.StatusOK, "google": http.StatusBadRequest} {
+		rec := postForm(server.handler.HandleDeviceAuthorization, "/oauth/device_authorization", url.Values{"client_id": {"cli"}, "idp": {idp}})
+		if rec.Code != expected {
+			t.Errorf("Expected %d for idp %q, got %d %s", expected, idp, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+// TestDeviceAuthorizationGrant_Metadata tests that the endpoint is only advertised when the upstream supports it
+func TestDeviceAuthorizationGrant_Metadata(t *testing.T) {
+	server := newTestServer(t, http.NotFound, deviceTestConfig)
+
+	metadata := server.handler.GetAuthorizationServerMetadata()
+	if metadata.DeviceAuthorizationEndpoint != "https://mcp.example.com/oauth/device_authorization" {
+		t.Errorf("Expected device_authorization_endpoint, got %v", metadata.DeviceAuthorizationEndpoint)
+	}
+	if !slices.Contains(metadata.GrantTypesSupported, grantTypeDeviceCode) {
+		t.Errorf("Expected device_code grant type, got %v", metadata.GrantTypesSupported)
+	}
+
+	// The hmac provider does not use discovery and has no device authorization endpoint
+	server = newTestServer(t, http.NotFound, nil)
+	if server.handler.GetAuthorizationServerMetadata().DeviceAuthorizationEndpoint != "" {
+		t.Error("Expected no device_authorization_endpoint without upstream support")
+	}
+	if rec := postForm(server.handler.HandleDeviceAuthorization, "/oauth/device_authorization", url.Values{}); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 without upstream support, got %d", rec.Code)
+	}
+	rec := postForm(server.handler.HandleToken, "/oauth/token", url.Values{"grant_type": {grantTypeDeviceCode}, "device_code": {"x"}})
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "unsupported_grant_type") {
+		t.Errorf("Expected unsupported_grant_type, got %d %s", rec.Code, rec.Body.String())
+	}
+}
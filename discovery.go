@@ -0,0 +1,245 @@
+package oauth
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Modes for Config.UpstreamMetadata (native mode)
+const (
+	// UpstreamMetadataMirror serves the issuer's discovery document from a cache
+	UpstreamMetadataMirror = "mirror"
+	// UpstreamMetadataRedirect redirects metadata requests to the issuer's discovery document
+	UpstreamMetadataRedirect = "redirect"
+)
+
+// defaultUpstreamMetadataRefresh is how often the mirrored discovery document is revalidated
+const defaultUpstreamMetadataRefresh = time.Hour
+
+// upstreamMetadataRetry is how long a failed fetch is remembered before trying again
+const upstreamMetadataRetry = time.Minute
+
+// maxDiscoveryDocumentSize limits the size of the discovery document read from the issuer
+const maxDiscoveryDocumentSize = 1 << 20
+
+// upstreamMetadata mirrors the discovery document of the upstream provider in
+// native mode. The document is fetched on first use and revalidated with
+// If-None-Match and If-Modified-Since once the refresh interval has passed;
+// if the issuer is unreachable the last document keeps being served.
+// Fetches run without holding mu, one at a time; while a refresh is in flight
+// the cached document is served.
+type upstreamMetadata struct {
+	issuer  string
+	urls    []string // Candidate document locations, in order
+	refresh time.Duration
+	client  *http.Client
+	logger  Logger
+
+	mu        sync.Mutex
+	document  *discoveryDocument // Last document fetched, nil until the first success
+	nextFetch time.Time
+	err       error         // Error of the last fetch
+	fetching  chan struct{} // Closed when the fetch in flight completes, nil if none
+}
+
+// discoveryDocument is a discovery document and the validators to revalidate it
+type discoveryDocument struct {
+	url          string // Location the document was found at
+	body         []byte
+	etag         string
+	lastModified string
+}
+
+// validateUpstreamMetadata checks Config.UpstreamMetadata and Config.UpstreamMetadataRefresh
+func validateUpstreamMetadata(c *Config) error {
+	switch c.UpstreamMetadata {
+	case "", UpstreamMetadataMirror, UpstreamMetadataRedirect:
+	default:
+		return fmt.Errorf("unknown UpstreamMetadata mode: %s (supported: %s, %s)", c.UpstreamMetadata, UpstreamMetadataMirror, UpstreamMetadataRedirect)
+	}
+	if c.UpstreamMetadataRefresh < 0 {
+		return fmt.Errorf("UpstreamMetadataRefresh must not be negative")
+	}
+	return nil
+}
+
+// newUpstreamMetadata creates the discovery document mirror for native mode
+// with an OIDC provider, or returns nil
+func newUpstreamMetadata(cfg *OAuth2Config, logger Logger) *upstreamMetadata {
+	if cfg.Mode != "native" || cfg.Provider == "hmac" || cfg.Issuer == "" {
+		return nil
+	}
+	return newDiscoveryMirror(cfg.Issuer, cfg.UpstreamMetadataRefresh, logger)
+}
+
+// newDiscoveryMirror creates a mirror of issuer's discovery document that is
+// revalidated every refresh (default: defaultUpstreamMetadataRefresh)
+func newDiscoveryMirror(issuer string, refresh time.Duration, logger Logger) *upstreamMetadata {
+	if refresh == 0 {
+		refresh = defaultUpstreamMetadataRefresh
+	}
+	return &upstreamMetadata{
+		issuer:  issuer,
+		urls:    discoveryURLs(issuer),
+		refresh: refresh,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		logger:  logger,
+	}
+}
+
+// discoveryURLs returns the locations of an issuer's metadata: OpenID Connect
+// Discovery 1.0 Section 4, then RFC 8414 Section 3.1
+func discoveryURLs(issuer string) []string {
+	urls := []string{strings.TrimSuffix(issuer, "/") + oidcDiscoveryPath}
+	if parsed, err := url.Parse(issuer); err == nil && parsed.Host != "" {
+		urls = append(urls, parsed.Scheme+"://"+parsed.Host+authorizationServerMetadataPath+strings.TrimSuffix(parsed.EscapedPath(), "/"))
+	}
+	return urls
+}
+
+// documentURL returns the location of the issuer's discovery document
+func (u *upstreamMetadata) documentURL() string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.document != nil {
+		return u.document.url
+	}
+	return u.urls[0]
+}
+
+// get returns the discovery document, fetching or revalidating it when due.
+// Each call decodes a new copy that the caller may change.
+func (u *upstreamMetadata) get() (*AuthorizationServerMetadata, error) {
+	u.mu.Lock()
+	switch {
+	case u.fetching == nil && !time.Now().Before(u.nextFetch):
+		fetching := make(chan struct{})
+		u.fetching = fetching
+		cached := u.document
+		u.mu.Unlock()
+
+		document, err := u.fetch(cached)
+
+		u.mu.Lock()
+		u.finishFetch(document, err)
+		close(fetching)
+	case u.fetching != nil && u.document == nil:
+		// Nothing to serve yet: wait for the first fetch
+		fetching := u.fetching
+		u.mu.Unlock()
+		<-fetching
+		u.mu.Lock()
+	}
+	document, err := u.document, u.err
+	u.mu.Unlock()
+
+	if document == nil {
+		return nil, err
+	}
+	var metadata AuthorizationServerMetadata
+	if err := json.Unmarshal(document.body, &metadata); err != nil {
+		return nil, fmt.Errorf("failed to decode discovery document: %w", err)
+	}
+	return &metadata, nil
+}
+
+// finishFetch stores the result of a fetch and schedules the next one.
+// Callers must hold u.mu.
+func (u *upstreamMetadata) finishFetch(document *discoveryDocument, err error) {
+	u.fetching = nil
+	u.err = err
+	if err == nil {
+		u.document = document
+		u.nextFetch = time.Now().Add(u.refresh)
+		return
+	}
+	u.nextFetch = time.Now().Add(upstreamMetadataRetry)
+	if u.document != nil {
+		u.logger.Warn("OAuth2: Failed to refresh discovery document, serving cached copy: %v", err)
+	} else {
+		u.logger.Error("OAuth2: Failed to fetch discovery document: %v", err)
+	}
+}
+
+// fetch revalidates cached at its location, or without a cached document
+// tries each candidate location until one exists
+func (u *upstreamMetadata) fetch(cached *discoveryDocument) (*discoveryDocument, error) {
+	urls := u.urls
+	if cached != nil {
+		urls = []string{cached.url}
+	}
+
+	var err error
+	for _, documentURL := range urls {
+		var document *discoveryDocument
+		var notFound bool
+		if document, notFound, err = u.fetchURL(documentURL, cached); err == nil || !notFound {
+			return document, err
+		}
+	}
+	return nil, err
+}
+
+// fetchURL loads the document at documentURL, revalidating cached if it is
+// from there, and reports whether the document does not exist
+func (u *upstreamMetadata) fetchURL(documentURL string, cached *discoveryDocument) (*discoveryDocument, bool, error) {
+	req, err := http.NewRequest(http.MethodGet, documentURL, nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid discovery URL %s: %w", documentURL, err)
+	}
+	req.Header.Set("Accept", "application/json")
+	revalidating := cached != nil && cached.url == documentURL
+	if revalidating {
+		if cached.etag != "" {
+			req.Header.Set("If-None-Match", cached.etag)
+		}
+		if cached.lastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.lastModified)
+		}
+	}
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to fetch %s: %w", documentURL, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && revalidating:
+		u.logger.Debug("OAuth2: Discovery document at %s not modified", documentURL)
+		return cached, false, nil
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, true, fmt.Errorf("no discovery document at %s", documentURL)
+	case resp.StatusCode != http.StatusOK:
+		return nil, false, fmt.Errorf("failed to fetch %s: status %d", documentURL, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxDiscoveryDocumentSize))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read %s: %w", documentURL, err)
+	}
+	var metadata AuthorizationServerMetadata
+	if err := json.Unmarshal(body, &metadata); err != nil {
+		return nil, false, fmt.Errorf("invalid discovery document at %s: %w", documentURL, err)
+	}
+	// The issuer must be identical to the configured one (RFC 8414 Section 3.3,
+	// OpenID Connect Discovery 1.0 Section 4.3)
+	if metadata.Issuer != u.issuer {
+		return nil, false, fmt.Errorf("discovery document at %s is for issuer %q, expected %q", documentURL, metadata.Issuer, u.issuer)
+	}
+
+	u.logger.Info("OAuth2: Loaded discovery document from %s", documentURL)
+	return &discoveryDocument{
+		url:          documentURL,
+		body:         body,
+		etag:         resp.Header.Get("ETag"),
+		lastModified: resp.Header.Get("Last-Modified"),
+	}, false, nil
+}
@@ -0,0 +1,278 @@
+package oauth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// handlerTransport serves client requests with handler instead of the network
+type handlerTransport struct {
+	handler http.Handler
+}
+
+func (t handlerTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	rec := httptest.NewRecorder()
+	t.handler.ServeHTTP(rec, r)
+	return rec.Result(), nil
+}
+
+// withDiscoveryFixture makes the issuer of a native mode handler serve the
+// discovery document in testdata/discovery/<provider>.json
+func withDiscoveryFixture(t *testing.T, handler *OAuth2Handler) *OAuth2Handler {
+	t.Helper()
+
+	document, err := os.ReadFile(filepath.Join("testdata", "discovery", handler.config.Provider+".json"))
+	if err != nil {
+		t.Fatalf("Failed to read discovery fixture: %v", err)
+	}
+	handler.discovery = newUpstreamMetadata(handler.config, handler.logger)
+	handler.discovery.client = &http.Client{Transport: handlerTransport{http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, oidcDiscoveryPath) {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(document)
+	})}}
+	return handler
+}
+
+// newDiscoveryTestIdP creates an issuer at /oauth2/default whose discovery
+// document is served with an ETag. Requests for the document are counted.
+func newDiscoveryTestIdP(t *testing.T, requests *int32, status *int32) *httptest.Server {
+	t.Helper()
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/oauth2/default"+oidcDiscoveryPath {
+			http.NotFound(w, r)
+			return
+		}
+		atomic.AddInt32(requests, 1)
+		if code := atomic.LoadInt32(status); code != http.StatusOK {
+			w.WriteHeader(int(code))
+			return
+		}
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		issuer := server.URL + "/oauth2/default"
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", `"v1"`)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                      issuer,
+			"authorization_endpoint":      issuer + "/v1/authorize",
+			"token_endpoint":              issuer + "/v1/token",
+			"jwks_uri":                    issuer + "/v1/keys",
+			"response_types_supported":    []string{"code"},
+			"request_parameter_supported": true,
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// TestUpstreamMetadata tests mirroring, caching and revalidating the issuer's discovery document
+func TestUpstreamMetadata(t *testing.T) {
+	var requests int32
+	status := int32(http.StatusOK)
+	idp := newDiscoveryTestIdP(t, &requests, &status)
+	issuer := idp.URL + "/oauth2/default"
+
+	handler := NewOAuth2Handler(&OAuth2Config{
+		Mode:     "native",
+		Provider: "okta",
+		Issuer:   issuer,
+		Audience: "api://test",
+		MCPURL:   "https://mcp.example.com",
+		MetadataOverrides: MetadataOverrides{
+			AuthorizationServer: func(m *AuthorizationServerMetadata) {
+				m.ServiceDocumentation = "https://docs.example.com"
+			},
+		},
+	}, nil)
+	atomic.StoreInt32(&requests, 0)
+
+	rec := httptest.NewRecorder()
+	handler.HandleAuthorizationServerMetadata(rec, httptest.NewRequest(http.MethodGet, authorizationServerMetadataPath, nil))
+	var metadata map[string]interface{}
+	_ = json.Unmarshal(rec.Body.Bytes(), &metadata)
+	if rec.Code != http.StatusOK || metadata["authorization_endpoint"] != issuer+"/v1/authorize" || metadata["token_endpoint"] != issuer+"/v1/token" {
+		t.Fatalf("Expected the issuer's endpoints, got %d: %v", rec.Code, metadata)
+	}
+	if metadata["request_parameter_supported"] != true || metadata["service_documentation"] != "https://docs.example.com" {
+		t.Errorf("Expected unknown parameters and overrides to be kept, got %v", metadata)
+	}
+	if methods, _ := metadata["code_challenge_methods_supported"].([]interface{}); len(methods) != 1 || methods[0] != PKCEMethodS256 {
+		t.Errorf("Expected code_challenge_methods_supported to be added, got %v", metadata["code_challenge_methods_supported"])
+	}
+	if _, ok := metadata["registration_endpoint"]; ok {
+		t.Error("Expected no fabricated registration_endpoint")
+	}
+
+	// Served from the cache, and from the same document for OIDC discovery
+	oidc := handler.GetOpenIDProviderMetadata()
+	if oidc.Issuer != issuer || oidc.TokenEndpoint != issuer+"/v1/token" || oidc.EndSessionEndpoint != "" {
+		t.Errorf("Unexpected OpenID Provider configuration: %+v", oidc)
+	}
+	if servers := handler.GetProtectedResourceMetadata().AuthorizationServers; len(servers) != 1 || servers[0] != issuer {
+		t.Errorf("Expected the issuer as authorization server, got %v", servers)
+	}
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Errorf("Expected one fetch, got %d", n)
+	}
+
+	// Revalidated with the ETag once the refresh interval has passed
+	handler.discovery.nextFetch = time.Now().Add(-time.Second)
+	if metadata := handler.GetAuthorizationServerMetadata(); metadata.TokenEndpoint != issuer+"/v1/token" {
+		t.Errorf("Expected the document after revalidation, got %+v", metadata)
+	}
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Errorf("Expected a conditional request, got %d requests", n)
+	}
+
+	// The cached document is served while the issuer fails
+	atomic.StoreInt32(&status, http.StatusServiceUnavailable)
+	handler.discovery.nextFetch = time.Now().Add(-time.Second)
+	rec = httptest.NewRecorder()
+	handler.HandleOIDCDiscovery(rec, httptest.NewRequest(http.MethodGet, oidcDiscoveryPath, nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), issuer+"/v1/authorize") {
+		t.Errorf("Expected the cached document, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+// TestUpstreamMetadata_Errors tests unavailable and mismatched discovery documents
+func TestUpstreamMetadata_Errors(t *testing.T) {
+	var requests int32
+	status := int32(http.StatusInternalServerError)
+	idp := newDiscoveryTestIdP(t, &requests, &status)
+
+	handler := &OAuth2Handler{config: &OAuth2Config{Mode: "native", Provider: "okta", Issuer: idp.URL + "/oauth2/default", MCPURL: "https://mcp.example.com"}, logger: &defaultLogger{}}
+	handler.discovery = newUpstreamMetadata(handler.config, handler.logger)
+
+	rec := httptest.NewRecorder()
+	handler.HandleAuthorizationServerMetadata(rec, httptest.NewRequest(http.MethodGet, authorizationServerMetadataPath, nil))
+	if rec.Code != http.StatusBadGateway || decodeOAuthError(t, rec).Error != "temporarily_unavailable" {
+		t.Errorf("Expected 502 without a discovery document, got %d", rec.Code)
+	}
+	if metadata := handler.GetAuthorizationServerMetadata(); metadata.Issuer != handler.config.Issuer || metadata.AuthorizationEndpoint != "" {
+		t.Errorf("Expected only the issuer, got %+v", metadata)
+	}
+
+	// Failures are retried after a delay, not on every request
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Errorf("Expected one fetch, got %d", n)
+	}
+
+	// The issuer in the document must match the configured one
+	atomic.StoreInt32(&status, http.StatusOK)
+	handler.config.Issuer = idp.URL + "/oauth2/other"
+	handler.discovery = newUpstreamMetadata(handler.config, handler.logger)
+	handler.discovery.urls = []string{idp.URL + "/oauth2/default" + oidcDiscoveryPath}
+	if _, err := handler.discovery.get(); err == nil || !strings.Contains(err.Error(), "expected") {
+		t.Errorf("Expected issuer mismatch to be rejected, got %v", err)
+	}
+}
+
+// TestUpstreamMetadata_Locations tests the RFC 8414 fallback and redirect mode
+func TestUpstreamMetadata_Locations(t *testing.T) {
+	urls := discoveryURLs("https://idp.example.com/tenant/")
+	if len(urls) != 2 || urls[0] != "https://idp.example.com/tenant/.well-known/openid-configuration" || urls[1] != "https://idp.example.com/.well-known/oauth-authorization-server/tenant" {
+		t.Errorf("Unexpected discovery URLs: %v", urls)
+	}
+
+	// An issuer without OIDC discovery
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != authorizationServerMetadataPath+"/as" {
+			http.NotFound(w, r)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"issuer": server.URL + "/as", "token_endpoint": server.URL + "/as/token", "response_types_supported": []string{"code"}})
+	}))
+	defer server.Close()
+
+	config := &OAuth2Config{Mode: "native", Provider: "okta", Issuer: server.URL + "/as", MCPURL: "https://mcp.example.com", UpstreamMetadata: UpstreamMetadataRedirect}
+	handler := &OAuth2Handler{config: config, logger: &defaultLogger{}, discovery: newUpstreamMetadata(config, &defaultLogger{})}
+	if metadata := handler.GetAuthorizationServerMetadata(); metadata.TokenEndpoint != server.URL+"/as/token" {
+		t.Errorf("Expected the RFC 8414 document, got %+v", metadata)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.HandleAuthorizationServerMetadata(rec, httptest.NewRequest(http.MethodGet, authorizationServerMetadataPath, nil))
+	if location := rec.Header().Get("Location"); rec.Code != http.StatusFound || location != server.URL+authorizationServerMetadataPath+"/as" {
+		t.Errorf("Expected redirect to the issuer's document, got %d: %s", rec.Code, location)
+	}
+
+	invalid := []func(cfg *Config){
+		func(cfg *Config) { cfg.UpstreamMetadata = "proxy" },
+		func(cfg *Config) { cfg.UpstreamMetadataRefresh = -time.Minute },
+	}
+	for _, mutate := range invalid {
+		cfg := &Config{Mode: "native", Provider: "okta", Issuer: "https://idp.example.com", Audience: "api://test"}
+		mutate(cfg)
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("Expected configuration to be rejected: %+v", cfg)
+		}
+	}
+}
+
+// TestUpstreamMetadata_Refresh tests that a slow refresh does not block requests served from the cache
+func TestUpstreamMetadata_Refresh(t *testing.T) {
+	var requests int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	config := &OAuth2Config{Mode: "native", Provider: "okta", Issuer: "https://idp.example.com", MCPURL: "https://mcp.example.com"}
+	upstream := newUpstreamMetadata(config, &defaultLogger{})
+	upstream.client = &http.Client{Transport: handlerTransport{http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) > 1 {
+			close(started)
+			<-release
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"issuer": config.Issuer, "token_endpoint": config.Issuer + "/token", "response_types_supported": []string{"code"}})
+	})}}
+
+	if _, err := upstream.get(); err != nil {
+		t.Fatalf("Initial fetch failed: %v", err)
+	}
+
+	upstream.mu.Lock()
+	upstream.nextFetch = time.Now().Add(-time.Second)
+	upstream.mu.Unlock()
+	refreshed := make(chan struct{})
+	go func() {
+		_, _ = upstream.get()
+		close(refreshed)
+	}()
+	<-started
+
+	// While the refresh waits for the issuer, the cached document is served
+	served := make(chan *AuthorizationServerMetadata)
+	go func() {
+		metadata, _ := upstream.get()
+		_ = upstream.documentURL()
+		served <- metadata
+	}()
+	select {
+	case metadata := <-served:
+		if metadata == nil || metadata.TokenEndpoint != config.Issuer+"/token" {
+			t.Errorf("Expected the cached document, got %+v", metadata)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the cached document to be served during the refresh")
+	}
+
+	close(release)
+	<-refreshed
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Errorf("Expected a single refresh, got %d requests", n)
+	}
+}
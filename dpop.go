@@ -0,0 +1,279 @@
+package oauth
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// DPoP modes for Config.DPoPMode (RFC 9449)
+const (
+	// DPoPModeAllowed accepts Bearer tokens and DPoP-bound tokens with valid proofs
+	DPoPModeAllowed = "allowed"
+	// DPoPModeRequired only accepts DPoP-bound tokens with valid proofs
+	DPoPModeRequired = "required"
+)
+
+// defaultDPoPProofLifetime is how long a DPoP proof is accepted after its iat
+const defaultDPoPProofLifetime = 5 * time.Minute
+
+// defaultDPoPAlgorithms are the proof signing algorithms accepted by default
+var defaultDPoPAlgorithms = []string{"ES256", "RS256", "PS256", "EdDSA"}
+
+// supportedDPoPAlgorithms are the asymmetric algorithms accepted for DPoP proofs
+var supportedDPoPAlgorithms = map[string]bool{
+	"RS256": true, "RS384": true, "RS512": true,
+	"ES256": true, "ES384": true, "ES512": true,
+	"PS256": true, "PS384": true, "PS512": true,
+	"EdDSA": true,
+}
+
+// validateDPoPAlgorithms checks that every proof algorithm is asymmetric (RFC 9449 Section 4.2)
+func validateDPoPAlgorithms(algorithms []string) error {
+	for _, alg := range algorithms {
+		if !supportedDPoPAlgorithms[alg] {
+			return fmt.Errorf("DPoP signing algorithm %q is not supported (must be RS*, ES*, PS* or EdDSA)", alg)
+		}
+	}
+	return nil
+}
+
+// dpopVerifier validates DPoP proofs (RFC 9449 Section 4.3) and token binding
+type dpopVerifier struct {
+	mode       string
+	algorithms []string
+	lifetime   time.Duration
+	leeway     time.Duration
+	now        func() time.Time
+	serverURL  string
+	replay     *dpopReplayCache
+}
+
+// newDPoPVerifier creates a verifier from configuration. Returns nil when DPoP is disabled.
+func newDPoPVerifier(cfg *Config) *dpopVerifier {
+	if cfg.DPoPMode == "" {
+		return nil
+	}
+
+	now := cfg.Clock
+	if now == nil {
+		now = time.Now
+	}
+	lifetime := cfg.DPoPProofLifetime
+	if lifetime == 0 {
+		lifetime = defaultDPoPProofLifetime
+	}
+
+	return &dpopVerifier{
+		mode:       cfg.DPoPMode,
+		algorithms: dpopAlgorithms(cfg),
+		lifetime:   lifetime,
+		leeway:     cfg.Leeway,
+		now:        now,
+		serverURL:  cfg.ServerURL,
+		replay:     &dpopReplayCache{entries: make(map[string]time.Time)},
+	}
+}
+
+// dpopAlgorithms returns the configured DPoP proof algorithms or the defaults
+func dpopAlgorithms(cfg *Config) []string {
+	if len(cfg.DPoPSigningAlgorithms) > 0 {
+		return cfg.DPoPSigningAlgorithms
+	}
+	return defaultDPoPAlgorithms
+}
+
+// checkBinding enforces the DPoP sender constraint for a validated token
+func (v *dpopVerifier) checkBinding(info *requestInfo, token string, user *User) error {
+	jkt := confirmationClaim(user, "jkt")
+	scheme := authSchemeBearer
+	if info != nil && info.authScheme != "" {
+		scheme = info.authScheme
+	}
+
+	if scheme == authSchemeDPoP {
+		thumbprint, err := v.verifyProof(info, token)
+		if err != nil {
+			return &AuthError{Code: "invalid_dpop_proof", Description: "Invalid DPoP proof", Err: err}
+		}
+		if jkt == "" {
+			return invalidTokenError("Access token is not DPoP-bound", nil)
+		}
+		if jkt != thumbprint {
+			return invalidTokenError("DPoP proof key does not match the access token binding", nil)
+		}
+		return nil
+	}
+
+	// Bearer scheme (RFC 9449 Section 7.1: bound tokens must not be downgraded)
+	if jkt != "" {
+		return invalidTokenError("DPoP-bound access token must use the DPoP authorization scheme", nil)
+	}
+	if v.mode == DPoPModeRequired {
+		return invalidTokenError("DPoP-bound access token required", nil)
+	}
+	return nil
+}
+
+// verifyProof validates the DPoP proof of the request and returns the
+// base64url-encoded SHA-256 JWK thumbprint of the proof key
+func (v *dpopVerifier) verifyProof(info *requestInfo, accessToken string) (string, error) {
+	if len(info.dpopProofs) != 1 {
+		return "", fmt.Errorf("exactly one DPoP header is required, got %d", len(info.dpopProofs))
+	}
+
+	var thumbprint string
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(info.dpopProofs[0], claims, func(token *jwt.Token) (interface{}, error) {
+		if typ, _ := token.Header["typ"].(string); !strings.EqualFold(typ, "dpop+jwt") {
+			return nil, fmt.Errorf(`proof "typ" must be "dpop+jwt"`)
+		}
+
+		rawJWK, ok := token.Header["jwk"]
+		if !ok {
+			return nil, fmt.Errorf(`proof is missing the "jwk" header`)
+		}
+		data, err := json.Marshal(rawJWK)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proof jwk: %w", err)
+		}
+		var jwk jose.JSONWebKey
+		if err := jwk.UnmarshalJSON(data); err != nil {
+			return nil, fmt.Errorf("invalid proof jwk: %w", err)
+		}
+		if !jwk.Valid() || !jwk.IsPublic() {
+			return nil, fmt.Errorf("proof jwk must be a valid public key")
+		}
+
+		digest, err := jwk.Thumbprint(crypto.SHA256)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute jwk thumbprint: %w", err)
+		}
+		thumbprint = base64.RawURLEncoding.EncodeToString(digest)
+		return jwk.Key, nil
+	}, jwt.WithValidMethods(v.algorithms), jwt.WithoutClaimsValidation())
+	if err != nil {
+		return "", fmt.Errorf("proof verification failed: %w", err)
+	}
+
+	if htm, _ := claims["htm"].(string); htm != info.method {
+		return "", fmt.Errorf("proof htm %q does not match request method %s", htm, info.method)
+	}
+	if htu, _ := claims["htu"].(string); !v.matchesRequestURL(info, htu) {
+		return "", fmt.Errorf("proof htu %q does not match request URL", htu)
+	}
+
+	iat, ok := claims["iat"].(float64)
+	if !ok {
+		return "", fmt.Errorf("proof is missing the iat claim")
+	}
+	issuedAt := time.Unix(int64(iat), 0)
+	now := v.now()
+	if now.Add(v.leeway).Before(issuedAt) {
+		return "", fmt.Errorf("proof issued in the future")
+	}
+	if now.After(issuedAt.Add(v.lifetime + v.leeway)) {
+		return "", fmt.Errorf("proof expired")
+	}
+
+	expectedATH := base64.RawURLEncoding.EncodeToString(sha256Sum([]byte(accessToken)))
+	if ath, _ := claims["ath"].(string); ath != expectedATH {
+		return "", fmt.Errorf("proof ath does not match the access token")
+	}
+
+	jti, _ := claims["jti"].(string)
+	if jti == "" {
+		return "", fmt.Errorf("proof is missing the jti claim")
+	}
+	if !v.replay.add(thumbprint+":"+jti, issuedAt.Add(v.lifetime+v.leeway), now) {
+		return "", fmt.Errorf("proof jti has already been used")
+	}
+
+	return thumbprint, nil
+}
+
+// matchesRequestURL compares the proof htu (ignoring query and fragment) with
+// the URL of the request, as seen directly or through the configured ServerURL
+func (v *dpopVerifier) matchesRequestURL(info *requestInfo, htu string) bool {
+	parsed, err := url.Parse(htu)
+	if err != nil || parsed.Host == "" {
+		return false
+	}
+	target := strings.ToLower(parsed.Scheme+"://"+parsed.Host) + parsed.EscapedPath()
+
+	for _, candidate := range requestURLs(info, v.serverURL) {
+		if target == candidate {
+			return true
+		}
+	}
+	return false
+}
+
+// checkDPoPBinding enforces the DPoP sender constraint. When DPoP is disabled,
+// DPoP-bound tokens are rejected because the proof of possession cannot be verified.
+func (s *Server) checkDPoPBinding(info *requestInfo, token string, user *User) error {
+	if s.dpop == nil {
+		if info != nil && info.authScheme == authSchemeDPoP {
+			return invalidTokenError("DPoP authorization scheme is not supported", nil)
+		}
+		if confirmationClaim(user, "jkt") != "" {
+			return invalidTokenError("DPoP-bound access tokens are not supported", nil)
+		}
+		return nil
+	}
+
+	return s.dpop.checkBinding(info, token, user)
+}
+
+// confirmationClaim returns a member of the token's "cnf" claim (RFC 7800)
+func confirmationClaim(user *User, member string) string {
+	cnf, ok := user.Claims["cnf"].(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	value, _ := cnf[member].(string)
+	return value
+}
+
+// sha256Sum returns the SHA-256 digest of data
+func sha256Sum(data []byte) []byte {
+	sum := sha256.Sum256(data)
+	return sum[:]
+}
+
+// dpopReplayCache remembers proof identifiers until the proof would expire anyway
+type dpopReplayCache struct {
+	mu        sync.Mutex
+	entries   map[string]time.Time
+	lastSweep time.Time
+}
+
+// add records key and reports whether it was unseen
+func (c *dpopReplayCache) add(key string, expiresAt, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if now.Sub(c.lastSweep) > time.Minute {
+		for k, exp := range c.entries {
+			if now.After(exp) {
+				delete(c.entries, k)
+			}
+		}
+		c.lastSweep = now
+	}
+
+	if exp, seen := c.entries[key]; seen && !now.After(exp) {
+		return false
+	}
+	c.entries[key] = expiresAt
+	return true
+}
@@ -0,0 +1,328 @@
+package oauth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// dpopTestKey is a client key pair used to sign DPoP proofs
+type dpopTestKey struct {
+	private    *ecdsa.PrivateKey
+	jwk        map[string]interface{}
+	thumbprint string
+}
+
+// newDPoPTestKey generates a P-256 proof key and its JWK thumbprint
+func newDPoPTestKey(t *testing.T) *dpopTestKey {
+	t.Helper()
+
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	public := jose.JSONWebKey{Key: &private.PublicKey}
+	data, err := public.MarshalJSON()
+	if err != nil {
+		t.Fatalf("Failed to marshal jwk: %v", err)
+	}
+	var jwk map[string]interface{}
+	if err := json.Unmarshal(data, &jwk); err != nil {
+		t.Fatalf("Failed to decode jwk: %v", err)
+	}
+	digest, err := public.Thumbprint(crypto.SHA256)
+	if err != nil {
+		t.Fatalf("Failed to compute thumbprint: %v", err)
+	}
+
+	return &dpopTestKey{
+		private:    private,
+		jwk:        jwk,
+		thumbprint: base64.RawURLEncoding.EncodeToString(digest),
+	}
+}
+
+// proof creates a DPoP proof for the request and access token
+func (k *dpopTestKey) proof(t *testing.T, method, htu, accessToken string, overrides jwt.MapClaims) string {
+	t.Helper()
+
+	claims := jwt.MapClaims{
+		"htm": method,
+		"htu": htu,
+		"iat": time.Now().Unix(),
+		"jti": base64.RawURLEncoding.EncodeToString([]byte(time.Now().String())),
+		"ath": base64.RawURLEncoding.EncodeToString(sha256Sum([]byte(accessToken))),
+	}
+	for key, value := range overrides {
+		claims[key] = value
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["typ"] = "dpop+jwt"
+	token.Header["jwk"] = k.jwk
+
+	proof, err := token.SignedString(k.private)
+	if err != nil {
+		t.Fatalf("Failed to sign proof: %v", err)
+	}
+	return proof
+}
+
+// signAccessToken issues an HMAC access token, bound to jkt when non-empty
+func signAccessToken(t *testing.T, cfg *Config, subject, jkt string) string {
+	t.Helper()
+
+	claims := jwt.MapClaims{
+		"sub": subject,
+		"aud": cfg.Audience,
+		"exp": time.Now().Add(time.Hour).Unix(),
+		"iat": time.Now().Unix(),
+	}
+	if jkt != "" {
+		claims["cnf"] = map[string]interface{}{"jkt": jkt}
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(cfg.JWTSecret)
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+	return token
+}
+
+// TestWrapHandler_DPoP tests DPoP proof validation and token binding
+func TestWrapHandler_DPoP(t *testing.T) {
+	server := newTestServer(t, nil, func(cfg *Config) { cfg.DPoPMode = DPoPModeAllowed })
+	cfg := server.config
+	key := newDPoPTestKey(t)
+	handler := server.WrapHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	serve := func(scheme, token string, proofs ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "http://mcp.example.com/mcp?session=1", nil)
+		req.Header.Set("Authorization", scheme+" "+token)
+		for _, proof := range proofs {
+			req.Header.Add("DPoP", proof)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("ValidProof", func(t *testing.T) {
+		token := signAccessToken(t, cfg, "valid", key.thumbprint)
+		proof := key.proof(t, "POST", "https://mcp.example.com/mcp", token, nil)
+		if rec := serve("DPoP", token, proof); rec.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+
+		// Replaying the same proof must fail
+		rec := serve("DPoP", token, proof)
+		if rec.Code != http.StatusUnauthorized {
+			t.Fatalf("Expected replayed proof to be rejected, got %d", rec.Code)
+		}
+		if !strings.Contains(rec.Header().Values("WWW-Authenticate")[1], `error="invalid_dpop_proof"`) {
+			t.Errorf("Expected invalid_dpop_proof challenge, got %v", rec.Header().Values("WWW-Authenticate"))
+		}
+	})
+
+	t.Run("BearerWithoutBinding", func(t *testing.T) {
+		token := signAccessToken(t, cfg, "bearer", "")
+		if rec := serve("Bearer", token); rec.Code != http.StatusOK {
+			t.Errorf("Expected unbound Bearer token to pass in allowed mode, got %d", rec.Code)
+		}
+	})
+
+	rejected := []struct {
+		name   string
+		scheme string
+		jkt    string
+		proof  func(token string) []string
+	}{
+		{
+			name: "BoundTokenAsBearer", scheme: "Bearer", jkt: key.thumbprint,
+			proof: func(token string) []string { return nil },
+		},
+		{
+			name: "MissingProof", scheme: "DPoP", jkt: key.thumbprint,
+			proof: func(token string) []string { return nil },
+		},
+		{
+			name: "MultipleProofs", scheme: "DPoP", jkt: key.thumbprint,
+			proof: func(token string) []string {
+				return []string{
+					key.proof(t, "POST", "https://mcp.example.com/mcp", token, nil),
+					key.proof(t, "POST", "https://mcp.example.com/mcp", token, jwt.MapClaims{"jti": "other"}),
+				}
+			},
+		},
+		{
+			name: "WrongMethod", scheme: "DPoP", jkt: key.thumbprint,
+			proof: func(token string) []string {
+				return []string{key.proof(t, "GET", "https://mcp.example.com/mcp", token, nil)}
+			},
+		},
+		{
+			name: "WrongURL", scheme: "DPoP", jkt: key.thumbprint,
+			proof: func(token string) []string {
+				return []string{key.proof(t, "POST", "https://evil.example.com/mcp", token, nil)}
+			},
+		},
+		{
+			name: "WrongAccessTokenHash", scheme: "DPoP", jkt: key.thumbprint,
+			proof: func(token string) []string {
+				return []string{key.proof(t, "POST", "https://mcp.example.com/mcp", token, jwt.MapClaims{"ath": "invalid"})}
+			},
+		},
+		{
+			name: "ExpiredProof", scheme: "DPoP", jkt: key.thumbprint,
+			proof: func(token string) []string {
+				iat := time.Now().Add(-10 * time.Minute).Unix()
+				return []string{key.proof(t, "POST", "https://mcp.example.com/mcp", token, jwt.MapClaims{"iat": iat})}
+			},
+		},
+		{
+			name: "ThumbprintMismatch", scheme: "DPoP", jkt: "another-key-thumbprint",
+			proof: func(token string) []string {
+				return []string{key.proof(t, "POST", "https://mcp.example.com/mcp", token, nil)}
+			},
+		},
+		{
+			name: "UnboundTokenWithProof", scheme: "DPoP", jkt: "",
+			proof: func(token string) []string {
+				return []string{key.proof(t, "POST", "https://mcp.example.com/mcp", token, nil)}
+			},
+		},
+	}
+
+	for _, tt := range rejected {
+		t.Run(tt.name, func(t *testing.T) {
+			token := signAccessToken(t, cfg, tt.name, tt.jkt)
+			rec := serve(tt.scheme, token, tt.proof(token)...)
+			if rec.Code != http.StatusUnauthorized {
+				t.Errorf("Expected 401, got %d", rec.Code)
+			}
+		})
+	}
+}
+
+// TestWrapHandler_DPoPRequired tests that required mode rejects Bearer tokens
+// and only advertises the DPoP challenge
+func TestWrapHandler_DPoPRequired(t *testing.T) {
+	server := newTestServer(t, nil, func(cfg *Config) { cfg.DPoPMode = DPoPModeRequired })
+	cfg := server.config
+	handler := server.WrapHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "https://mcp.example.com/mcp", nil)
+	req.Header.Set("Authorization", "Bearer "+signAccessToken(t, cfg, "user", ""))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected 401, got %d", rec.Code)
+	}
+	challenges := rec.Header().Values("WWW-Authenticate")
+	if len(challenges) != 2 || !strings.HasPrefix(challenges[0], "DPoP ") {
+		t.Fatalf("Expected DPoP challenge and resource metadata, got %v", challenges)
+	}
+	if !strings.Contains(challenges[0], `algs="ES256 RS256 PS256 EdDSA"`) {
+		t.Errorf("Expected default algs in challenge, got %s", challenges[0])
+	}
+}
+
+// TestWrapHandler_DPoPDisabled tests that bound tokens are rejected without DPoP support
+func TestWrapHandler_DPoPDisabled(t *testing.T) {
+	server := newTestServer(t, nil, nil)
+	cfg := server.config
+	handler := server.WrapHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "https://mcp.example.com/mcp", nil)
+	req.Header.Set("Authorization", "Bearer "+signAccessToken(t, cfg, "user", "some-thumbprint"))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected DPoP-bound token to be rejected, got %d", rec.Code)
+	}
+	for _, challenge := range rec.Header().Values("WWW-Authenticate") {
+		if strings.HasPrefix(challenge, "DPoP ") {
+			t.Errorf("Unexpected DPoP challenge when DPoP is disabled: %s", challenge)
+		}
+	}
+}
+
+// TestProtectedResourceMetadata_DPoP tests that DPoP support is advertised
+func TestProtectedResourceMetadata_DPoP(t *testing.T) {
+	cfg := &Config{
+		Mode:                  "native",
+		Provider:              "okta",
+		Issuer:                "https://company.okta.com",
+		Audience:              "api://test",
+		ServerURL:             "https://mcp.example.com",
+		DPoPMode:              DPoPModeRequired,
+		DPoPSigningAlgorithms: []string{"ES256"},
+	}
+	handler := CreateOAuth2Handler(cfg, "1.0.0", nil)
+
+	rec := httptest.NewRecorder()
+	handler.HandleProtectedResourceMetadata(rec, httptest.NewRequest(http.MethodGet, "/.well-known/oauth-protected-resource", nil))
+
+	var metadata map[string]interface{}
+	if err := json.NewDecoder(rec.Body).Decode(&metadata); err != nil {
+		t.Fatalf("Failed to decode metadata: %v", err)
+	}
+	algs, _ := metadata["dpop_signing_alg_values_supported"].([]interface{})
+	if len(algs) != 1 || algs[0] != "ES256" {
+		t.Errorf("Expected dpop_signing_alg_values_supported [ES256], got %v", metadata["dpop_signing_alg_values_supported"])
+	}
+	if metadata["dpop_bound_access_tokens_required"] != true {
+		t.Errorf("Expected dpop_bound_access_tokens_required true, got %v", metadata["dpop_bound_access_tokens_required"])
+	}
+}
+
+// TestConfigValidate_DPoP tests DPoP configuration validation
+func TestConfigValidate_DPoP(t *testing.T) {
+	tests := []struct {
+		name      string
+		mode      string
+		algs      []string
+		expectErr bool
+	}{
+		{name: "disabled"},
+		{name: "allowed", mode: DPoPModeAllowed},
+		{name: "required with algs", mode: DPoPModeRequired, algs: []string{"ES256", "EdDSA"}},
+		{name: "unknown mode", mode: "sometimes", expectErr: true},
+		{name: "symmetric alg", mode: DPoPModeAllowed, algs: []string{"HS256"}, expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Provider:              "hmac",
+				Audience:              "api://test",
+				JWTSecret:             []byte("secret"),
+				DPoPMode:              tt.mode,
+				DPoPSigningAlgorithms: tt.algs,
+			}
+			if err := cfg.Validate(); (err != nil) != tt.expectErr {
+				t.Errorf("Validate() error = %v, expectErr %v", err, tt.expectErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package oauth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"golang.org/x/oauth2"
+)
+
+// OAuthError is an RFC 6749 Section 5.2 error returned by the proxy endpoints
+// (/oauth/authorize, /oauth/callback, /oauth/token, /oauth/register, ...).
+// Errors from the upstream provider are relayed with their original code.
+type OAuthError struct {
+	Status      int    // HTTP status (default depends on Code)
+	Code        string // e.g. "invalid_request", "invalid_grant"
+	Description string // Human-readable description sent to the client
+	URI         string // Optional error_uri with more information
+	// Err is the underlying cause, logged but never sent to the client
+	Err error
+}
+
+// Error implements the error interface
+func (e *OAuthError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Description, e.Err)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Description)
+}
+
+// Unwrap returns the underlying cause
+func (e *OAuthError) Unwrap() error {
+	return e.Err
+}
+
+// status returns the HTTP status for the error response
+func (e *OAuthError) status() int {
+	if e.Status != 0 {
+		return e.Status
+	}
+	switch e.Code {
+	case "invalid_client":
+		return http.StatusUnauthorized
+	case "server_error":
+		return http.StatusInternalServerError
+	case "temporarily_unavailable":
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// oauthErrorResponse is the JSON body of an OAuth error response
+type oauthErrorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+	ErrorURI         string `json:"error_uri,omitempty"`
+}
+
+// writeOAuthError writes an RFC 6749 JSON error response
+func writeOAuthError(w http.ResponseWriter, status int, code, description string) {
+	writeOAuthErrorResponse(w, &OAuthError{Status: status, Code: code, Description: description})
+}
+
+// writeOAuthErrorResponse writes oauthErr as an RFC 6749 JSON error response
+func writeOAuthErrorResponse(w http.ResponseWriter, oauthErr *OAuthError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(oauthErr.status())
+	_ = json.NewEncoder(w).Encode(oauthErrorResponse{
+		Error:            oauthErr.Code,
+		ErrorDescription: oauthErr.Description,
+		ErrorURI:         oauthErr.URI,
+	})
+}
+
+// redirectOAuthError delivers an authorization error to the client's
+// validated redirect URI (RFC 6749 Section 4.1.2.1)
+func redirectOAuthError(w http.ResponseWriter, r *http.Request, redirectURI, state string, oauthErr *OAuthError) {
+	target, err := url.Parse(redirectURI)
+	if err != nil {
+		writeOAuthErrorResponse(w, oauthErr)
+		return
+	}
+
+	query := target.Query()
+	query.Set("error", oauthErr.Code)
+	if oauthErr.Description != "" {
+		query.Set("error_description", oauthErr.Description)
+	}
+	if oauthErr.URI != "" {
+		query.Set("error_uri", oauthErr.URI)
+	}
+	if state != "" {
+		query.Set("state", state)
+	}
+	target.RawQuery = query.Encode()
+	http.Redirect(w, r, target.String(), http.StatusFound)
+}
+
+// upstreamOAuthError converts an OAuth error response from the upstream
+// provider into an OAuthError, keeping the provider's error code
+func upstreamOAuthError(status int, body []byte) *OAuthError {
+	var upstream oauthErrorResponse
+	if err := json.Unmarshal(body, &upstream); err != nil || upstream.Error == "" {
+		return &OAuthError{Status: http.StatusBadGateway, Code: "server_error", Description: "Unexpected response from the upstream provider"}
+	}
+	return &OAuthError{
+		Status:      relayedStatus(status, upstream.Error),
+		Code:        upstream.Error,
+		Description: upstream.ErrorDescription,
+		URI:         upstream.ErrorURI,
+	}
+}
+
+// exchangeOAuthError converts a failed upstream token request into an
+// OAuthError. Provider errors are relayed; transport failures become server_error.
+func exchangeOAuthError(err error) *OAuthError {
+	var retrieveErr *oauth2.RetrieveError
+	if !errors.As(err, &retrieveErr) || retrieveErr.ErrorCode == "" {
+		return &OAuthError{Status: http.StatusBadGateway, Code: "server_error", Description: "Token exchange failed", Err: err}
+	}
+
+	status := 0
+	if retrieveErr.Response != nil {
+		status = retrieveErr.Response.StatusCode
+	}
+	return &OAuthError{
+		Status:      relayedStatus(status, retrieveErr.ErrorCode),
+		Code:        retrieveErr.ErrorCode,
+		Description: retrieveErr.ErrorDescription,
+		URI:         retrieveErr.ErrorURI,
+		Err:         err,
+	}
+}
+
+// relayedStatus keeps the upstream 4xx status of a relayed error; other
+// statuses are replaced by the status RFC 6749 uses for code
+func relayedStatus(status int, code string) int {
+	if status >= 400 && status < 500 {
+		return status
+	}
+	return (&OAuthError{Code: code}).status()
+}
+
+// relayUpstreamError forwards an OAuth error response from the upstream provider
+func relayUpstreamError(w http.ResponseWriter, status int, body []byte) {
+	writeOAuthErrorResponse(w, upstreamOAuthError(status, body))
+}
@@ -0,0 +1,226 @@
+package oauth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// redirectedErrorCode returns the error parameter of an authorization error redirect
+func redirectedErrorCode(rec *httptest.ResponseRecorder) string {
+	if rec.Code != http.StatusFound {
+		return ""
+	}
+	location, err := url.Parse(rec.Header().Get("Location"))
+	if err != nil {
+		return ""
+	}
+	return location.Query().Get("error")
+}
+
+// decodeOAuthError decodes a JSON error response, failing the test if it is not one
+func decodeOAuthError(t *testing.T, rec *httptest.ResponseRecorder) oauthErrorResponse {
+	t.Helper()
+
+	if contentType := rec.Header().Get("Content-Type"); contentType != "application/json" {
+		t.Fatalf("Expected JSON error response, got %q: %s", contentType, rec.Body.String())
+	}
+	var response oauthErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Invalid error response: %v", err)
+	}
+	return response
+}
+
+// TestHandleAuthorize_Errors tests JSON errors before and redirect errors after redirect_uri validation
+func TestHandleAuthorize_Errors(t *testing.T) {
+	handler := newPARTestHandler(false)
+	authorize := func(params url.Values) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.HandleAuthorize(rec, httptest.NewRequest(http.MethodGet, "/oauth/authorize?"+params.Encode(), nil))
+		return rec
+	}
+
+	// An untrusted redirect_uri is never redirected to
+	params := url.Values{"client_id": {"client"}, "redirect_uri": {"https://evil.example.com/callback"}, "state": {"xyz"}}
+	setTestCodeChallenge(params)
+	rec := authorize(params)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("Expected 400, got %d", rec.Code)
+	}
+	if response := decodeOAuthError(t, rec); response.Error != "invalid_request" {
+		t.Errorf("Expected invalid_request, got %+v", response)
+	}
+
+	// With a valid redirect_uri, errors are delivered to the client with its state
+	params.Set("redirect_uri", "https://client.example.com/callback")
+	params.Set("scope", "admin")
+	rec = authorize(params)
+	if redirectedErrorCode(rec) != "invalid_scope" {
+		t.Fatalf("Expected invalid_scope redirect, got %d: %s", rec.Code, rec.Header().Get("Location"))
+	}
+	location, _ := url.Parse(rec.Header().Get("Location"))
+	if location.Host != "client.example.com" || location.Query().Get("state") != "xyz" {
+		t.Errorf("Expected error redirect to the client with state, got %s", location)
+	}
+
+	params.Del("scope")
+	params.Del("code_challenge")
+	if rec := authorize(params); redirectedErrorCode(rec) != "invalid_request" {
+		t.Errorf("Expected missing PKCE challenge to be redirected as invalid_request, got %d", rec.Code)
+	}
+}
+
+// TestHandleToken_Errors tests token endpoint error codes and relayed upstream errors
+func TestHandleToken_Errors(t *testing.T) {
+	var upstreamStatus int
+	var upstreamBody string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(upstreamStatus)
+		_, _ = w.Write([]byte(upstreamBody))
+	}))
+	defer upstream.Close()
+
+	handler := NewOAuth2Handler(&OAuth2Config{
+		Mode:            "proxy",
+		Provider:        "hmac",
+		Issuer:          upstream.URL,
+		ClientID:        "client-id",
+		RedirectURIs:    "https://client.example.com/callback,https://client.example.com/alt",
+		MCPURL:          "https://mcp.example.com",
+		stateSigningKey: []byte("test-state-signing-key"),
+	}, nil)
+
+	token := func(form url.Values) *httptest.ResponseRecorder {
+		return postForm(handler.HandleToken, "/oauth/token", form)
+	}
+	exchange := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {"auth-code"},
+		"redirect_uri":  {"https://client.example.com/callback"},
+		"code_verifier": {testCodeVerifier},
+	}
+
+	tests := []struct {
+		name           string
+		form           url.Values
+		upstreamStatus int
+		upstreamBody   string
+		expectedStatus int
+		expectedError  string
+		expectedURI    string
+	}{
+		{
+			name:           "unsupported grant type",
+			form:           url.Values{"grant_type": {"password"}},
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "unsupported_grant_type",
+		},
+		{
+			name:           "missing code",
+			form:           url.Values{"grant_type": {"authorization_code"}},
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "invalid_request",
+		},
+		{
+			name:           "upstream invalid_grant",
+			form:           exchange,
+			upstreamStatus: http.StatusBadRequest,
+			upstreamBody:   `{"error":"invalid_grant","error_description":"code expired","error_uri":"https://idp.example.com/errors"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "invalid_grant",
+			expectedURI:    "https://idp.example.com/errors",
+		},
+		{
+			name:           "upstream invalid_client",
+			form:           exchange,
+			upstreamStatus: http.StatusUnauthorized,
+			upstreamBody:   `{"error":"invalid_client"}`,
+			expectedStatus: http.StatusUnauthorized,
+			expectedError:  "invalid_client",
+		},
+		{
+			name:           "upstream failure",
+			form:           exchange,
+			upstreamStatus: http.StatusInternalServerError,
+			upstreamBody:   `internal error`,
+			expectedStatus: http.StatusBadGateway,
+			expectedError:  "server_error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstreamStatus, upstreamBody = tt.upstreamStatus, tt.upstreamBody
+			rec := token(tt.form)
+			if rec.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, rec.Code, rec.Body.String())
+			}
+			response := decodeOAuthError(t, rec)
+			if response.Error != tt.expectedError || response.ErrorURI != tt.expectedURI {
+				t.Errorf("Expected error %q (uri %q), got %+v", tt.expectedError, tt.expectedURI, response)
+			}
+			if rec.Header().Get("Cache-Control") != "no-store" {
+				t.Error("Expected Cache-Control: no-store")
+			}
+		})
+	}
+}
+
+// TestHandleCallback_UpstreamError tests that provider errors are relayed to the client in fixed redirect mode
+func TestHandleCallback_UpstreamError(t *testing.T) {
+	handler := NewOAuth2Handler(&OAuth2Config{
+		Mode:            "proxy",
+		Provider:        "hmac",
+		Issuer:          "https://idp.example.com",
+		ClientID:        "client-id",
+		RedirectURIs:    "https://mcp.example.com/oauth/callback",
+		MCPURL:          "https://mcp.example.com",
+		stateSigningKey: []byte("test-state-signing-key"),
+	}, nil)
+
+	state, err := handler.signState(map[string]string{"state": "client-state", "redirect": "http://localhost:3000/callback"})
+	if err != nil {
+		t.Fatalf("Failed to sign state: %v", err)
+	}
+	callback := url.Values{"error": {"access_denied"}, "error_description": {"User denied access"}, "state": {state}}
+	rec := httptest.NewRecorder()
+	handler.HandleCallback(rec, httptest.NewRequest(http.MethodGet, "/oauth/callback?"+callback.Encode(), nil))
+
+	if redirectedErrorCode(rec) != "access_denied" {
+		t.Fatalf("Expected access_denied redirect, got %d: %s", rec.Code, rec.Body.String())
+	}
+	location := rec.Header().Get("Location")
+	if !strings.HasPrefix(location, "http://localhost:3000/callback?") || !strings.Contains(location, "state=client-state") {
+		t.Errorf("Expected error redirect to the client with its state, got %s", location)
+	}
+
+	// Without a valid state the error cannot be delivered to the client
+	callback.Set("state", "forged")
+	rec = httptest.NewRecorder()
+	handler.HandleCallback(rec, httptest.NewRequest(http.MethodGet, "/oauth/callback?"+callback.Encode(), nil))
+	if rec.Code != http.StatusBadRequest || decodeOAuthError(t, rec).Error != "invalid_request" {
+		t.Errorf("Expected invalid_request for a forged state, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+// TestHandleRegister_Errors tests RFC 7591 registration errors
+func TestHandleRegister_Errors(t *testing.T) {
+	handler := newPARTestHandler(false)
+
+	rec := httptest.NewRecorder()
+	handler.HandleRegister(rec, httptest.NewRequest(http.MethodPost, "/oauth/register", strings.NewReader("not json")))
+	if rec.Code != http.StatusBadRequest || decodeOAuthError(t, rec).Error != "invalid_client_metadata" {
+		t.Errorf("Expected invalid_client_metadata, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	handler.HandleRegister(rec, httptest.NewRequest(http.MethodGet, "/oauth/register", nil))
+	if rec.Code != http.StatusMethodNotAllowed || decodeOAuthError(t, rec).Error != "invalid_request" {
+		t.Errorf("Expected JSON 405, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
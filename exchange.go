@@ -0,0 +1,235 @@
+package oauth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// Token exchange grant and token types (RFC 8693, Azure on-behalf-of)
+const (
+	grantTypeTokenExchange = "urn:ietf:params:oauth:grant-type:token-exchange"
+	grantTypeJWTBearer     = "urn:ietf:params:oauth:grant-type:jwt-bearer"
+	tokenTypeAccessToken   = "urn:ietf:params:oauth:token-type:access_token"
+)
+
+// exchangeExpirySkew renews exchanged tokens shortly before they expire
+const exchangeExpirySkew = 30 * time.Second
+
+// defaultExchangeLifetime is assumed when the token endpoint omits expires_in
+const defaultExchangeLifetime = 5 * time.Minute
+
+// tokenExchanger exchanges the caller's access token for downstream tokens at
+// the token endpoint of the upstream provider that issued it
+type tokenExchanger struct {
+	onBehalfOf bool // Use Azure's on-behalf-of flow with the primary provider instead of RFC 8693
+	httpClient *http.Client
+
+	mu    sync.Mutex
+	cache map[string]*oauth2.Token
+}
+
+// newTokenExchanger creates an exchanger
+func newTokenExchanger(cfg *Config) *tokenExchanger {
+	return &tokenExchanger{
+		onBehalfOf: cfg.Provider == "azure",
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		cache:      make(map[string]*oauth2.Token),
+	}
+}
+
+// ExchangeToken exchanges the caller's access token (from ctx) for a token
+// issued to audience with the given scopes, using RFC 8693 token exchange or,
+// for the azure provider, the on-behalf-of flow. Results are cached per
+// subject, audience and scopes until shortly before they expire.
+//
+// Tokens are exchanged at the provider that issued them, with its client
+// credentials: Config.Issuer, or the matching Config.UpstreamProviders entry.
+// Tokens from other Config.Issuers cannot be exchanged. The on-behalf-of flow
+// is only used with the primary provider.
+//
+// The context must carry the caller's token (set by WrapHandler,
+// CreateHTTPContextFunc or WithOAuthToken). Requires Config.ClientID.
+//
+// Example:
+//
+//	token, err := oauthServer.ExchangeToken(ctx, "api://billing", []string{"billing.read"})
+//	if err != nil {
+//	    return nil, err
+//	}
+//	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+func (s *Server) ExchangeToken(ctx context.Context, audience string, scopes []string) (*oauth2.Token, error) {
+	if s.exchanger == nil || s.handler == nil {
+		return nil, fmt.Errorf("token exchange is not configured")
+	}
+
+	subjectToken, ok := GetOAuthToken(ctx)
+	if !ok || subjectToken == "" {
+		return nil, fmt.Errorf("token exchange requires the caller's access token in context")
+	}
+
+	subject := fmt.Sprintf("%x", sha256.Sum256([]byte(subjectToken)))
+	upstream := s.handler.upstream("")
+	if user, ok := GetUserFromContext(ctx); ok {
+		if sub, _ := user.Claims["sub"].(string); sub != "" || user.Subject != "" {
+			subject = vaultSubject(user)
+		}
+		if user.Issuer != "" {
+			upstream = s.handler.upstreamForIssuer(user.Issuer)
+			if upstream != nil && upstream.issuer != user.Issuer {
+				return nil, fmt.Errorf("token exchange is not supported for tokens from issuer %s", user.Issuer)
+			}
+		}
+	}
+	if upstream == nil {
+		return nil, fmt.Errorf("token exchange is not configured")
+	}
+	if upstream.config.ClientID == "" {
+		return nil, fmt.Errorf("token exchange requires ClientID")
+	}
+
+	onBehalfOf := s.exchanger.onBehalfOf && upstream == s.handler.upstream("")
+	return s.exchanger.exchange(ctx, upstream.config, onBehalfOf, subject, subjectToken, audience, scopes)
+}
+
+// ExchangeToken exchanges the caller's access token for a downstream token
+// using the Server that authenticated the request. Tool handlers can call it
+// directly; see Server.ExchangeToken.
+func ExchangeToken(ctx context.Context, audience string, scopes []string) (*oauth2.Token, error) {
+	server, ok := ctx.Value(serverContextKey).(*Server)
+	if !ok {
+		return nil, fmt.Errorf("token exchange requires an OAuth-authenticated context")
+	}
+	return server.ExchangeToken(ctx, audience, scopes)
+}
+
+// WithServer adds the authenticating Server to the context so that tool
+// handlers can call ExchangeToken(ctx, ...). WrapHandler and the tool
+// middleware call it automatically.
+func WithServer(ctx context.Context, s *Server) context.Context {
+	return context.WithValue(ctx, serverContextKey, s)
+}
+
+// exchange returns a cached token or performs the exchange with client
+func (e *tokenExchanger) exchange(ctx context.Context, client *oauth2.Config, onBehalfOf bool, subject, subjectToken, audience string, scopes []string) (*oauth2.Token, error) {
+	sortedScopes := append([]string{}, scopes...)
+	sort.Strings(sortedScopes)
+	key := strings.Join([]string{subject, audience, strings.Join(sortedScopes, " ")}, "\x00")
+
+	e.mu.Lock()
+	cached, ok := e.cache[key]
+	e.mu.Unlock()
+	if ok && time.Now().Add(exchangeExpirySkew).Before(cached.Expiry) {
+		return cached, nil
+	}
+
+	token, err := e.request(ctx, client, onBehalfOf, subjectToken, audience, scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	e.mu.Lock()
+	e.cache[key] = token
+	for k, t := range e.cache {
+		if time.Now().After(t.Expiry) {
+			delete(e.cache, k)
+		}
+	}
+	e.mu.Unlock()
+
+	return token, nil
+}
+
+// request calls the token endpoint of client
+func (e *tokenExchanger) request(ctx context.Context, client *oauth2.Config, onBehalfOf bool, subjectToken, audience string, scopes []string) (*oauth2.Token, error) {
+	form := url.Values{}
+	if onBehalfOf {
+		// Azure expresses the target API through the scope (".default" for all granted permissions)
+		scope := strings.Join(scopes, " ")
+		if scope == "" {
+			scope = strings.TrimSuffix(audience, "/") + "/.default"
+		}
+		form.Set("grant_type", grantTypeJWTBearer)
+		form.Set("assertion", subjectToken)
+		form.Set("requested_token_use", "on_behalf_of")
+		form.Set("scope", scope)
+		form.Set("client_id", client.ClientID)
+		form.Set("client_secret", client.ClientSecret)
+	} else {
+		form.Set("grant_type", grantTypeTokenExchange)
+		form.Set("subject_token", subjectToken)
+		form.Set("subject_token_type", tokenTypeAccessToken)
+		form.Set("requested_token_type", tokenTypeAccessToken)
+		form.Set("audience", audience)
+		if len(scopes) > 0 {
+			form.Set("scope", strings.Join(scopes, " "))
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, client.Endpoint.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create token exchange request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if !onBehalfOf {
+		req.SetBasicAuth(url.QueryEscape(client.ClientID), url.QueryEscape(client.ClientSecret))
+	}
+
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("token exchange request failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token exchange response: %w", err)
+	}
+
+	var result struct {
+		AccessToken     string `json:"access_token"`
+		TokenType       string `json:"token_type"`
+		RefreshToken    string `json:"refresh_token"`
+		ExpiresIn       int64  `json:"expires_in"`
+		IssuedTokenType string `json:"issued_token_type"`
+		Scope           string `json:"scope"`
+		Error           string `json:"error"`
+		ErrorDesc       string `json:"error_description"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("invalid token exchange response (status %d): %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK || result.Error != "" {
+		return nil, fmt.Errorf("token exchange failed (status %d): %s: %s", resp.StatusCode, result.Error, result.ErrorDesc)
+	}
+	if result.AccessToken == "" {
+		return nil, fmt.Errorf("token exchange response missing access_token")
+	}
+
+	lifetime := defaultExchangeLifetime
+	if result.ExpiresIn > 0 {
+		lifetime = time.Duration(result.ExpiresIn) * time.Second
+	}
+
+	token := &oauth2.Token{
+		AccessToken:  result.AccessToken,
+		TokenType:    result.TokenType,
+		RefreshToken: result.RefreshToken,
+		Expiry:       time.Now().Add(lifetime),
+	}
+	return token.WithExtra(map[string]interface{}{
+		"issued_token_type": result.IssuedTokenType,
+		"scope":             result.Scope,
+	}), nil
+}
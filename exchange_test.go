@@ -0,0 +1,185 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+// TestExchangeToken tests RFC 8693 token exchange and per-subject caching
+func TestExchangeToken(t *testing.T) {
+	var calls int32
+	var form url.Values
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		_ = r.ParseForm()
+		form = r.PostForm
+		if user, pass, ok := r.BasicAuth(); !ok || user != "mcp-server" || pass != "mcp-secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token":      "downstream-token-for-" + form.Get("audience"),
+			"token_type":        "Bearer",
+			"expires_in":        3600,
+			"issued_token_type": tokenTypeAccessToken,
+		})
+	}, func(cfg *Config) { cfg.Mode = "native" })
+
+	ctx := WithOAuthToken(context.Background(), "caller-token")
+	ctx = WithUser(ctx, &User{Subject: "user-1", Issuer: server.config.Issuer})
+	ctx = WithServer(ctx, server)
+
+	token, err := ExchangeToken(ctx, "api://billing", []string{"billing.read"})
+	if err != nil {
+		t.Fatalf("ExchangeToken failed: %v", err)
+	}
+	if token.AccessToken != "downstream-token-for-api://billing" {
+		t.Errorf("Unexpected access token: %s", token.AccessToken)
+	}
+
+	expected := map[string]string{
+		"grant_type":         grantTypeTokenExchange,
+		"subject_token":      "caller-token",
+		"subject_token_type": tokenTypeAccessToken,
+		"audience":           "api://billing",
+		"scope":              "billing.read",
+	}
+	for key, value := range expected {
+		if form.Get(key) != value {
+			t.Errorf("Expected %s=%q, got %q", key, value, form.Get(key))
+		}
+	}
+
+	// Same subject and audience is served from cache, even with a new caller token
+	ctx = WithOAuthToken(ctx, "refreshed-caller-token")
+	if _, err := server.ExchangeToken(ctx, "api://billing", []string{"billing.read"}); err != nil {
+		t.Fatalf("ExchangeToken failed: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected cached result, upstream called %d times", calls)
+	}
+
+	// A different audience triggers a new exchange
+	if _, err := server.ExchangeToken(ctx, "api://inventory", nil); err != nil {
+		t.Fatalf("ExchangeToken failed: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("Expected new exchange for a different audience, upstream called %d times", calls)
+	}
+
+	// Users are told apart by the raw sub claim, not the mapped Subject
+	ctx = WithUser(ctx, &User{Subject: "user-1", Issuer: server.config.Issuer, Claims: map[string]interface{}{"sub": "user-2"}})
+	if _, err := server.ExchangeToken(ctx, "api://billing", []string{"billing.read"}); err != nil {
+		t.Fatalf("ExchangeToken failed: %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("Expected new exchange for a different sub claim, upstream called %d times", calls)
+	}
+}
+
+// TestExchangeToken_OnBehalfOf tests the Azure on-behalf-of request format
+func TestExchangeToken_OnBehalfOf(t *testing.T) {
+	var form url.Values
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		form = r.PostForm
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "obo-token",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+		})
+	}, func(cfg *Config) { cfg.Mode = "native" })
+	server.exchanger.onBehalfOf = true
+
+	ctx := WithOAuthToken(context.Background(), "caller-token")
+	token, err := server.ExchangeToken(ctx, "api://downstream", nil)
+	if err != nil {
+		t.Fatalf("ExchangeToken failed: %v", err)
+	}
+	if token.AccessToken != "obo-token" {
+		t.Errorf("Unexpected access token: %s", token.AccessToken)
+	}
+
+	expected := map[string]string{
+		"grant_type":          grantTypeJWTBearer,
+		"assertion":           "caller-token",
+		"requested_token_use": "on_behalf_of",
+		"scope":               "api://downstream/.default",
+		"client_id":           "mcp-server",
+		"client_secret":       "mcp-secret",
+	}
+	for key, value := range expected {
+		if form.Get(key) != value {
+			t.Errorf("Expected %s=%q, got %q", key, value, form.Get(key))
+		}
+	}
+}
+
+// TestExchangeToken_Errors tests failure cases
+func TestExchangeToken_Errors(t *testing.T) {
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_target", "error_description": "unknown audience"})
+	}, func(cfg *Config) { cfg.Mode = "native" })
+
+	if _, err := ExchangeToken(context.Background(), "api://billing", nil); err == nil {
+		t.Error("Expected error without an authenticated context")
+	}
+	if _, err := server.ExchangeToken(context.Background(), "api://billing", nil); err == nil {
+		t.Error("Expected error without a caller token")
+	}
+
+	ctx := WithOAuthToken(context.Background(), "caller-token")
+	if _, err := server.ExchangeToken(ctx, "api://unknown", nil); err == nil {
+		t.Error("Expected upstream error to be returned")
+	}
+}
+
+// TestExchangeToken_UpstreamProviders tests that tokens are exchanged at the provider that issued them
+func TestExchangeToken_UpstreamProviders(t *testing.T) {
+	var oktaTokens, googleTokens int32
+	okta := newTestIdP(t, "okta-exchanged", &oktaTokens)
+	google := newTestIdP(t, "google-exchanged", &googleTokens)
+
+	server := newTestServer(t, nil, func(cfg *Config) {
+		cfg.Mode = "proxy"
+		cfg.Provider = "okta"
+		cfg.Issuer = okta.URL
+		cfg.RedirectURIs = "https://mcp.example.com/oauth/callback"
+		cfg.UpstreamProviders = []UpstreamProvider{{Name: "google", Issuer: google.URL, ClientID: "google-client"}}
+	})
+
+	exchange := func(issuer string) (*oauth2.Token, error) {
+		ctx := WithOAuthToken(context.Background(), "caller-token")
+		ctx = WithUser(ctx, &User{Subject: "user-1", Issuer: issuer, Claims: map[string]interface{}{"sub": "user-1"}})
+		return server.ExchangeToken(ctx, "api://billing", nil)
+	}
+
+	token, err := exchange(google.URL)
+	if err != nil {
+		t.Fatalf("ExchangeToken failed: %v", err)
+	}
+	if token.AccessToken != "google-exchanged" || googleTokens != 1 || oktaTokens != 0 {
+		t.Errorf("Expected the exchange at google, got %s (okta %d, google %d requests)", token.AccessToken, oktaTokens, googleTokens)
+	}
+
+	// The same subject at another provider is a different user
+	token, err = exchange(okta.URL)
+	if err != nil {
+		t.Fatalf("ExchangeToken failed: %v", err)
+	}
+	if token.AccessToken != "okta-exchanged" || oktaTokens != 1 {
+		t.Errorf("Expected the exchange at okta, got %s (okta %d requests)", token.AccessToken, oktaTokens)
+	}
+
+	if _, err := exchange("https://other.example.com"); err == nil {
+		t.Error("Expected tokens of an issuer without an upstream provider to be rejected")
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"html/template"
 	"io"
 	"log"
 	"net/http"
@@ -19,6 +20,7 @@ import (
 	"time"
 
 	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/tuannvm/oauth-mcp-proxy/provider"
 	"golang.org/x/oauth2"
 )
 
@@ -26,7 +28,13 @@ import (
 type OAuth2Handler struct {
 	config       *OAuth2Config
 	oauth2Config *oauth2.Config
+	upstreams    []*upstreamIdP // oauth2Config first, then Config.UpstreamProviders
 	logger       Logger
+	par          *parStore
+	pkce         *pkceStore
+	consent      *consentManager
+	discovery    *upstreamMetadata // Issuer's discovery document (native mode)
+	httpClient   *http.Client      // Requests to the upstream token and device endpoints
 }
 
 // GetConfig returns the OAuth2 configuration
@@ -55,9 +63,60 @@ type OAuth2Config struct {
 	// MCPURL is the full URL of the MCP server, used for the resource endpoint in the OAuth 2.0 Protected Resource Metadata endpoint
 	MCPURL string
 
+	// BasePath prefixes the endpoint paths; Paths overrides them (empty fields use the defaults)
+	BasePath string
+	Paths    Paths
+
+	// MetadataOverrides customizes the metadata documents
+	MetadataOverrides MetadataOverrides
+
+	// UpstreamMetadata and UpstreamMetadataRefresh configure the native mode discovery document mirror
+	UpstreamMetadata        string
+	UpstreamMetadataRefresh time.Duration
+
+	// Resources lists additional resource identifiers (RFC 8707) accepted in the resource parameter
+	Resources []string
+
+	// Scopes are requested upstream; ScopesSupported are advertised in metadata
+	Scopes          []string
+	ScopesSupported []string
+
 	// Server version
 	Version string
 
+	// AllowedAlgorithms lists the accepted token signing algorithms
+	AllowedAlgorithms []string
+
+	// DPoPMode and DPoPSigningAlgorithms are advertised in the protected resource metadata
+	DPoPMode              string
+	DPoPSigningAlgorithms []string
+
+	// CertificateBoundTokens is advertised in the protected resource metadata
+	CertificateBoundTokens bool
+
+	// PKCEMethods lists the accepted code challenge methods (default: S256)
+	PKCEMethods []string
+
+	// RequirePushedAuthorizationRequests rejects authorization requests without a request_uri
+	RequirePushedAuthorizationRequests bool
+
+	// ClientRedirectURIs are the client redirect URI patterns accepted in fixed redirect mode (default: loopback)
+	ClientRedirectURIs []string
+
+	// Consent enables the consent page; ConsentStore remembers approvals
+	Consent      bool
+	ConsentStore ConsentStore
+
+	// Templates replaces the built-in HTML pages by name (nil uses the defaults)
+	Templates *template.Template
+
+	// ProviderName and UpstreamProviders configure additional upstream identity providers
+	ProviderName      string
+	UpstreamProviders []UpstreamProvider
+
+	// TokenVault stores upstream tokens after a successful token exchange
+	TokenVault *TokenVault
+
 	// State signing key for integrity protection
 	stateSigningKey []byte
 }
@@ -68,36 +127,13 @@ func NewOAuth2Handler(cfg *OAuth2Config, logger Logger) *OAuth2Handler {
 		logger = &defaultLogger{}
 	}
 
-	var endpoint oauth2.Endpoint
-
-	// Use OIDC discovery for supported providers, fallback to hardcoded for others
-	switch cfg.Provider {
-	case "okta", "google", "azure":
-		// Use OIDC discovery to get correct endpoints
-		if discoveredEndpoint, err := discoverOIDCEndpoints(cfg.Issuer); err != nil {
-			logger.Error("OIDC discovery failed for %s provider. Using Okta-style fallback endpoints which may not work for all providers: %v", cfg.Provider, err)
-			// Fallback to Okta-style endpoints as they're most common
-			endpoint = oauth2.Endpoint{
-				AuthURL:  cfg.Issuer + "/oauth2/v1/authorize",
-				TokenURL: cfg.Issuer + "/oauth2/v1/token",
-			}
-		} else {
-			endpoint = discoveredEndpoint
-		}
-	default:
-		// For HMAC and unknown providers, use hardcoded endpoints
-		endpoint = oauth2.Endpoint{
-			AuthURL:  cfg.Issuer + "/oauth2/v1/authorize",
-			TokenURL: cfg.Issuer + "/oauth2/v1/token",
-		}
-	}
-
+	endpoint, endSessionURL := discoverEndpoint(cfg.Provider, cfg.Issuer, logger)
 	oauth2Config := &oauth2.Config{
 		ClientID:     cfg.ClientID,
 		ClientSecret: cfg.ClientSecret,
 		Endpoint:     endpoint,
-		Scopes:       []string{"openid", "profile", "email"},
 	}
+	oauth2Config.Scopes, _ = resolveScopes(cfg.Scopes, cfg.ScopesSupported)
 
 	// Log client configuration type for debugging
 	if cfg.ClientSecret == "" {
@@ -123,12 +159,40 @@ func NewOAuth2Handler(cfg *OAuth2Config, logger Logger) *OAuth2Handler {
 	return &OAuth2Handler{
 		config:       cfg,
 		oauth2Config: oauth2Config,
+		upstreams:    newUpstreams(cfg, oauth2Config, endSessionURL, logger),
 		logger:       logger,
+		par:          newPARStore(),
+		pkce:         newPKCEStore(),
+		consent:      newConsentManager(cfg),
+		discovery:    newUpstreamMetadata(cfg, logger),
+		httpClient:   &http.Client{Timeout: 10 * time.Second},
 	}
 }
 
-// discoverOIDCEndpoints uses OIDC discovery to get the correct authorization and token endpoints
-func discoverOIDCEndpoints(issuer string) (oauth2.Endpoint, error) {
+// discoverEndpoint returns the authorization and token endpoints of an
+// upstream provider and its end_session_endpoint (empty if not known), using
+// OIDC discovery for OIDC providers
+func discoverEndpoint(providerType, issuer string, logger Logger) (oauth2.Endpoint, string) {
+	// Use OIDC discovery for supported providers, fallback to hardcoded for others
+	switch providerType {
+	case "okta", "google", "azure", "oidc":
+		// Use OIDC discovery to get correct endpoints
+		discoveredEndpoint, endSessionURL, err := discoverOIDCEndpoints(issuer)
+		if err == nil {
+			return discoveredEndpoint, endSessionURL
+		}
+		logger.Error("OIDC discovery failed for %s provider. Using Okta-style fallback endpoints which may not work for all providers: %v", providerType, err)
+	}
+	// For HMAC and unknown providers (and as fallback), use Okta-style endpoints as they're most common
+	return oauth2.Endpoint{
+		AuthURL:  issuer + "/oauth2/v1/authorize",
+		TokenURL: issuer + "/oauth2/v1/token",
+	}, ""
+}
+
+// discoverOIDCEndpoints uses OIDC discovery to get the correct authorization
+// and token endpoints, and the end_session_endpoint if the provider has one
+func discoverOIDCEndpoints(issuer string) (oauth2.Endpoint, string, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
@@ -153,11 +217,19 @@ func discoverOIDCEndpoints(issuer string) (oauth2.Endpoint, error) {
 		issuer,
 	)
 	if err != nil {
-		return oauth2.Endpoint{}, fmt.Errorf("failed to discover OIDC provider: %w", err)
+		return oauth2.Endpoint{}, "", fmt.Errorf("failed to discover OIDC provider: %w", err)
 	}
 
-	// Return the discovered endpoint
-	return provider.Endpoint(), nil
+	// OpenID Connect RP-Initiated Logout 1.0
+	var logout struct {
+		EndSessionEndpoint string `json:"end_session_endpoint"`
+	}
+	if err := provider.Claims(&logout); err != nil {
+		return oauth2.Endpoint{}, "", fmt.Errorf("failed to decode OIDC discovery document: %w", err)
+	}
+
+	// Return the discovered endpoints
+	return provider.Endpoint(), logout.EndSessionEndpoint, nil
 }
 
 // NewOAuth2ConfigFromConfig creates OAuth2 config from generic Config
@@ -177,22 +249,63 @@ func NewOAuth2ConfigFromConfig(cfg *Config, version string) *OAuth2Config {
 		mcpURL = getEnv("MCP_URL", fmt.Sprintf("%s://%s:%s", scheme, mcpHost, mcpPort))
 	}
 
+	// Templates were checked by Config.Validate
+	templates, _ := loadTemplates(cfg)
+
 	return &OAuth2Config{
-		Enabled:         true,
-		Mode:            cfg.Mode,
-		Provider:        cfg.Provider,
-		RedirectURIs:    cfg.RedirectURIs,
-		Issuer:          cfg.Issuer,
-		Audience:        cfg.Audience,
-		ClientID:        cfg.ClientID,
-		ClientSecret:    cfg.ClientSecret,
-		MCPHost:         mcpHost,
-		MCPPort:         mcpPort,
-		MCPURL:          mcpURL,
-		Scheme:          scheme,
-		Version:         version,
-		stateSigningKey: cfg.JWTSecret,
+		Enabled:           true,
+		Mode:              cfg.Mode,
+		Provider:          cfg.Provider,
+		RedirectURIs:      cfg.RedirectURIs,
+		Issuer:            cfg.Issuer,
+		Audience:          cfg.Audience,
+		ClientID:          cfg.ClientID,
+		ClientSecret:      cfg.ClientSecret,
+		MCPHost:           mcpHost,
+		MCPPort:           mcpPort,
+		MCPURL:            mcpURL,
+		BasePath:          cfg.BasePath,
+		Paths:             cfg.Paths.withDefaults(),
+		MetadataOverrides: cfg.MetadataOverrides,
+		Scheme:            scheme,
+		Version:           version,
+		stateSigningKey:   cfg.JWTSecret,
+		Resources:         cfg.Resources,
+		Scopes:            cfg.Scopes,
+		ScopesSupported:   cfg.ScopesSupported,
+
+		AllowedAlgorithms:     cfg.AllowedAlgorithms,
+		DPoPMode:              cfg.DPoPMode,
+		DPoPSigningAlgorithms: dpopAlgorithms(cfg),
+
+		CertificateBoundTokens: cfg.CertificateBoundTokens,
+
+		UpstreamMetadata:        cfg.UpstreamMetadata,
+		UpstreamMetadataRefresh: cfg.UpstreamMetadataRefresh,
+
+		PKCEMethods:                        cfg.PKCEMethods,
+		RequirePushedAuthorizationRequests: cfg.RequirePushedAuthorizationRequests,
+		ClientRedirectURIs:                 cfg.ClientRedirectURIs,
+		Consent:                            cfg.Consent,
+		ConsentStore:                       cfg.ConsentStore,
+		Templates:                          templates,
+		ProviderName:                       cfg.ProviderName,
+		UpstreamProviders:                  cfg.UpstreamProviders,
+		TokenVault:                         cfg.TokenVault,
+	}
+}
+
+// validateResources checks requested resource indicators against MCPURL and the configured resources
+func (h *OAuth2Handler) validateResources(resources []string) error {
+	return validateRequestedResources(resources, resourceIdentifiers(h.config.MCPURL, h.config.Resources))
+}
+
+// signingAlgorithms returns the configured signing algorithms or the provider defaults
+func (h *OAuth2Handler) signingAlgorithms() []string {
+	if len(h.config.AllowedAlgorithms) > 0 {
+		return h.config.AllowedAlgorithms
 	}
+	return provider.DefaultAlgorithms(h.config.Provider)
 }
 
 // HandleJWKS handles the JWKS endpoint for proxy mode
@@ -264,20 +377,27 @@ func (h *OAuth2Handler) HandleJWKS(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// HandleAuthorize handles OAuth2 authorization requests with PKCE
+// HandleAuthorize handles OAuth2 authorization requests with PKCE.
+// Errors are returned as JSON until the redirect_uri has been validated, and
+// are then delivered to the client's redirect URI (RFC 6749 Section 4.1.2.1).
 func (h *OAuth2Handler) HandleAuthorize(w http.ResponseWriter, r *http.Request) {
 	// Defense in depth: Check OAuth mode
 	if h.config.Mode == "native" {
-		http.Error(w, "OAuth proxy disabled in native mode", http.StatusNotFound)
+		h.writeErrorPage(w, r, http.StatusNotFound, "invalid_request", "OAuth proxy disabled in native mode")
 		return
 	}
 	if r.Method != "GET" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		h.writeErrorPage(w, r, http.StatusMethodNotAllowed, "invalid_request", "Method not allowed")
 		return
 	}
 
-	// Extract query parameters
-	query := r.URL.Query()
+	// Extract query parameters, or the pushed parameters a request_uri refers to (RFC 9126)
+	query, err := h.resolveAuthorizationRequest(r.URL.Query())
+	if err != nil {
+		h.logger.Warn("SECURITY: Invalid authorization request from %s: %v", r.RemoteAddr, err)
+		h.writeErrorPage(w, r, http.StatusBadRequest, "invalid_request", fmt.Sprintf("Invalid authorization request: %v", err))
+		return
+	}
 
 	// PKCE parameters from client
 	codeChallenge := query.Get("code_challenge")
@@ -285,13 +405,15 @@ func (h *OAuth2Handler) HandleAuthorize(w http.ResponseWriter, r *http.Request)
 	clientRedirectURI := query.Get("redirect_uri")
 	state := query.Get("state")
 	clientID := query.Get("client_id")
+	resources := query["resource"]
+	scope := query.Get("scope")
 
-	h.logger.Info("OAuth2: Authorization request - client_id: %s, redirect_uri: %s, code_challenge: %s",
-		clientID, clientRedirectURI, truncateString(codeChallenge, 10))
+	h.logger.Info("OAuth2: Authorization request - client_id: %s, redirect_uri: %s, code_challenge: %s, resource: %v, scope: %s",
+		clientID, clientRedirectURI, truncateString(codeChallenge, 10), resources, scope)
 
 	// Determine redirect URI strategy based on configuration
 	var redirectURI string
-	hasFixedRedirect := h.config.RedirectURIs != "" && !strings.Contains(h.config.RedirectURIs, ",")
+	hasFixedRedirect := h.hasFixedRedirect()
 
 	if hasFixedRedirect {
 		// Fixed redirect mode: Use server's redirect URI to OAuth provider, proxy back to client
@@ -301,52 +423,24 @@ func (h *OAuth2Handler) HandleAuthorize(w http.ResponseWriter, r *http.Request)
 		// Validate client redirect URI format and security
 		if clientRedirectURI == "" {
 			h.logger.Warn("SECURITY: Missing client redirect URI")
-			http.Error(w, "Missing redirect_uri", http.StatusBadRequest)
-			return
-		}
-
-		parsedURI, err := url.Parse(clientRedirectURI)
-		if err != nil {
-			h.logger.Warn("SECURITY: Invalid client redirect URI format: %s", clientRedirectURI)
-			http.Error(w, "Invalid redirect_uri format", http.StatusBadRequest)
-			return
-		}
-
-		// Additional security checks for client redirect URI
-		if parsedURI.Scheme != "http" && parsedURI.Scheme != "https" {
-			h.logger.Warn("SECURITY: Invalid redirect URI scheme: %s (must be http or https)", parsedURI.Scheme)
-			http.Error(w, "Invalid redirect_uri scheme", http.StatusBadRequest)
-			return
-		}
-
-		// Enforce HTTPS for non-localhost URIs
-		if parsedURI.Scheme == "http" && !isLocalhostURI(clientRedirectURI) {
-			h.logger.Warn("SECURITY: HTTP redirect URI not allowed for non-localhost: %s", clientRedirectURI)
-			http.Error(w, "HTTPS required for non-localhost redirect_uri", http.StatusBadRequest)
+			h.writeErrorPage(w, r, http.StatusBadRequest, "invalid_request", "Missing redirect_uri")
 			return
 		}
 
-		// Prevent fragment in redirect URI (OAuth 2.0 spec)
-		if parsedURI.Fragment != "" {
-			h.logger.Warn("SECURITY: Redirect URI contains fragment: %s", clientRedirectURI)
-			http.Error(w, "redirect_uri must not contain fragment", http.StatusBadRequest)
+		// Security: Only allow loopback URIs (RFC 8252) or configured ClientRedirectURIs
+		// This prevents open redirect attacks while still supporting native clients
+		if err := h.validateClientRedirectURI(clientRedirectURI); err != nil {
+			h.logger.Warn("SECURITY: Rejected client redirect URI %s from %s: %v", clientRedirectURI, r.RemoteAddr, err)
+			h.writeErrorPage(w, r, http.StatusBadRequest, "invalid_request", fmt.Sprintf("Invalid redirect_uri: %v", err))
 			return
 		}
 
-		// Security: For fixed redirect mode, only allow localhost or loopback addresses
-		// This prevents open redirect attacks while still supporting development tools
-		if !isLocalhostURI(clientRedirectURI) {
-			h.logger.Warn("SECURITY: Fixed redirect mode only allows localhost URIs, rejecting: %s from %s", clientRedirectURI, r.RemoteAddr)
-			http.Error(w, "Fixed redirect mode only allows localhost redirect URIs for security. Use allowlist mode for production.", http.StatusBadRequest)
-			return
-		}
-
-		h.logger.Info("OAuth2: Validated localhost redirect URI for proxy: %s", clientRedirectURI)
+		h.logger.Info("OAuth2: Validated client redirect URI for proxy: %s", clientRedirectURI)
 	} else if h.config.RedirectURIs != "" {
 		// Allowlist mode: Client's URI must be in allowlist, used directly (no proxy)
 		if !h.isValidRedirectURI(clientRedirectURI) {
 			h.logger.Warn("SECURITY: Redirect URI not in allowlist: %s from %s", clientRedirectURI, r.RemoteAddr)
-			http.Error(w, "Invalid redirect_uri", http.StatusBadRequest)
+			h.writeErrorPage(w, r, http.StatusBadRequest, "invalid_request", "Invalid redirect_uri")
 			return
 		}
 		redirectURI = clientRedirectURI
@@ -354,27 +448,136 @@ func (h *OAuth2Handler) HandleAuthorize(w http.ResponseWriter, r *http.Request)
 	} else {
 		// No configuration: Reject for security
 		h.logger.Warn("SECURITY: No redirect URIs configured, rejecting: %s from %s", clientRedirectURI, r.RemoteAddr)
-		http.Error(w, "Invalid redirect_uri", http.StatusBadRequest)
+		h.writeErrorPage(w, r, http.StatusBadRequest, "invalid_request", "Invalid redirect_uri")
+		return
+	}
+
+	// The redirect URI is trusted from here on: deliver errors to the client
+	authorizeError := func(code, description string) {
+		redirectOAuthError(w, r, clientRedirectURI, state, &OAuthError{Code: code, Description: description})
+	}
+
+	// Validate step-up parameters forwarded upstream (RFC 9470)
+	acrValues := query.Get("acr_values")
+	maxAge := query.Get("max_age")
+	prompt := query.Get("prompt")
+	if err := validateStepUpParams(maxAge, prompt); err != nil {
+		h.logger.Warn("SECURITY: Invalid step-up parameter: %v", err)
+		authorizeError("invalid_request", "Invalid request")
+		return
+	}
+
+	// Validate requested scopes against the supported scopes
+	if err := h.validateRequestedScope(scope); err != nil {
+		h.logger.Warn("SECURITY: Invalid scope parameter: %v", err)
+		authorizeError("invalid_scope", "Invalid scope")
 		return
 	}
 
-	// Update OAuth2 config with redirect URI
-	h.oauth2Config.RedirectURL = redirectURI
+	// Validate resource indicators (RFC 8707)
+	if err := h.validateResources(resources); err != nil {
+		h.logger.Warn("SECURITY: Invalid resource parameter: %v", err)
+		authorizeError("invalid_target", "Invalid resource parameter")
+		return
+	}
+
+	// Enforce the PKCE policy (RFC 7636)
+	codeChallengeMethod, err = h.validateCodeChallenge(codeChallenge, codeChallengeMethod)
+	if err != nil {
+		h.logger.Warn("SECURITY: Invalid PKCE parameters: %v", err)
+		authorizeError("invalid_request", fmt.Sprintf("Invalid request: %v", err))
+		return
+	}
+
+	// Choose the upstream identity provider from the idp parameter, or let the user choose
+	idp := query.Get("idp")
+	if idp == "" && len(h.upstreams) > 1 {
+		h.showProviderSelection(w, r, query, clientRedirectURI, state)
+		return
+	}
+	upstream := h.upstream(idp)
+	if upstream == nil {
+		h.logger.Warn("SECURITY: Unknown idp parameter: %s", idp)
+		authorizeError("invalid_request", "Unknown idp")
+		return
+	}
+
+	authRequest := &authorizationRequest{
+		upstream:            upstream,
+		clientID:            clientID,
+		clientRedirectURI:   clientRedirectURI,
+		redirectURI:         redirectURI,
+		fixedRedirect:       hasFixedRedirect,
+		state:               state,
+		scope:               scope,
+		resources:           resources,
+		codeChallenge:       codeChallenge,
+		codeChallengeMethod: codeChallengeMethod,
+		acrValues:           acrValues,
+		maxAge:              maxAge,
+		prompt:              prompt,
+	}
+
+	// Ask the user first unless they already consented to this client
+	if h.consent != nil && !h.consent.hasConsent(r, consentClientKey(clientID, clientRedirectURI), h.consentScopes(authRequest), prompt) {
+		h.showConsentPage(w, r, authRequest)
+		return
+	}
+
+	h.redirectToProvider(w, r, authRequest)
+}
+
+// authorizationRequest is a validated /oauth/authorize request
+type authorizationRequest struct {
+	upstream          *upstreamIdP
+	clientID          string
+	clientRedirectURI string // Client's redirect URI
+	redirectURI       string // Redirect URI sent to the provider
+	fixedRedirect     bool
+	state             string
+	scope             string
+	resources         []string
+
+	codeChallenge       string
+	codeChallengeMethod string
+
+	acrValues string
+	maxAge    string
+	prompt    string
+}
+
+// redirectToProvider redirects the user agent to the upstream authorization endpoint
+func (h *OAuth2Handler) redirectToProvider(w http.ResponseWriter, r *http.Request, req *authorizationRequest) {
+	authorizeError := func(code, description string) {
+		redirectOAuthError(w, r, req.clientRedirectURI, req.state, &OAuthError{Code: code, Description: description})
+	}
+
+	// Remember the client for the success page
+	if h.consent != nil {
+		h.consent.startAuthorization(r, req.clientID, consentClientKey(req.clientID, req.clientRedirectURI))
+	}
+
+	// Copy the provider's OAuth2 config with the redirect URI of this request
+	oauth2Config := *req.upstream.config
+	oauth2Config.RedirectURL = req.redirectURI
 
 	// For fixed redirect mode, create signed state with client redirect URI
-	actualState := state
-	if hasFixedRedirect {
+	actualState := req.state
+	if req.fixedRedirect {
 		// Create state data with redirect URI
 		stateData := map[string]string{
-			"state":    state,
-			"redirect": clientRedirectURI,
+			"state":                 req.state,
+			"redirect":              req.clientRedirectURI,
+			"code_challenge":        req.codeChallenge,
+			"code_challenge_method": req.codeChallengeMethod,
+			"idp":                   req.upstream.name,
 		}
 
 		// Sign state for integrity protection
 		signedState, err := h.signState(stateData)
 		if err != nil {
 			h.logger.Error("OAuth2: Failed to sign state: %v", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			authorizeError("server_error", "Internal server error")
 			return
 		}
 
@@ -382,40 +585,56 @@ func (h *OAuth2Handler) HandleAuthorize(w http.ResponseWriter, r *http.Request)
 		h.logger.Info("OAuth2: Signed state for proxy callback (length: %d)", len(signedState))
 	}
 
-	// Create authorization URL
-	authURL := h.oauth2Config.AuthCodeURL(actualState, oauth2.AccessTypeOffline)
+	// Create authorization URL, forwarding the client's PKCE challenge and scope (defaults to Config.Scopes)
+	authOptions := []oauth2.AuthCodeOption{
+		oauth2.AccessTypeOffline,
+		oauth2.SetAuthURLParam("code_challenge", req.codeChallenge),
+		oauth2.SetAuthURLParam("code_challenge_method", req.codeChallengeMethod),
+	}
+	if strings.TrimSpace(req.scope) != "" {
+		authOptions = append(authOptions, oauth2.SetAuthURLParam("scope", strings.Join(strings.Fields(req.scope), " ")))
+	}
+	for name, value := range map[string]string{"acr_values": req.acrValues, "max_age": req.maxAge, "prompt": req.prompt} {
+		if value != "" {
+			authOptions = append(authOptions, oauth2.SetAuthURLParam(name, value))
+		}
+	}
+	authURL := oauth2Config.AuthCodeURL(actualState, authOptions...)
 
-	// Add PKCE parameters to the URL if provided
-	if codeChallenge != "" {
+	// Add resource indicators to the URL if provided
+	if len(req.resources) > 0 {
 		parsedURL, err := url.Parse(authURL)
 		if err != nil {
 			h.logger.Error("OAuth2: Failed to parse auth URL: %v", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			authorizeError("server_error", "Internal server error")
 			return
 		}
 
 		query := parsedURL.Query()
-		query.Set("code_challenge", codeChallenge)
-		query.Set("code_challenge_method", codeChallengeMethod)
+		for _, resource := range req.resources {
+			query.Add("resource", resource)
+		}
 
 		parsedURL.RawQuery = query.Encode()
 		authURL = parsedURL.String()
 	}
 
-	h.logger.Info("OAuth2: Redirecting to authorization URL: %s", authURL)
+	// Only the endpoint is logged: the URL carries state, PKCE and resource parameters
+	h.logger.Info("OAuth2: Redirecting to %s authorization endpoint: %s", req.upstream.name, oauth2Config.Endpoint.AuthURL)
 	http.Redirect(w, r, authURL, http.StatusTemporaryRedirect)
 }
 
-// HandleCallback handles OAuth2 callback
+// HandleCallback handles OAuth2 callback. In fixed redirect mode, authorization
+// errors from the provider are relayed to the client's redirect URI.
 func (h *OAuth2Handler) HandleCallback(w http.ResponseWriter, r *http.Request) {
 	// Defense in depth: Check OAuth mode
 	if h.config.Mode == "native" {
-		http.Error(w, "OAuth proxy disabled in native mode", http.StatusNotFound)
+		h.writeErrorPage(w, r, http.StatusNotFound, "invalid_request", "OAuth proxy disabled in native mode")
 		return
 	}
 
 	if r.Method != "GET" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		h.writeErrorPage(w, r, http.StatusMethodNotAllowed, "invalid_request", "Method not allowed")
 		return
 	}
 
@@ -427,27 +646,28 @@ func (h *OAuth2Handler) HandleCallback(w http.ResponseWriter, r *http.Request) {
 	h.logger.Info("OAuth2: Callback received - code: %s, state: %s, error: %s",
 		truncateString(code, 10), state, errorParam)
 
-	// Handle OAuth errors
+	// Upstream authorization error, relayed with the provider's error code
+	var upstreamErr *OAuthError
 	if errorParam != "" {
-		errorDesc := r.URL.Query().Get("error_description")
-		h.logger.Error("OAuth2: Authorization error: %s - %s", errorParam, errorDesc)
-		http.Error(w, fmt.Sprintf("Authorization failed: %s", errorDesc), http.StatusBadRequest)
-		return
-	}
-
-	if code == "" {
+		upstreamErr = &OAuthError{
+			Code:        errorParam,
+			Description: r.URL.Query().Get("error_description"),
+			URI:         r.URL.Query().Get("error_uri"),
+		}
+		h.logger.Error("OAuth2: Authorization error: %s - %s", upstreamErr.Code, upstreamErr.Description)
+	} else if code == "" {
 		h.logger.Error("OAuth2: No authorization code received")
-		http.Error(w, "No authorization code received", http.StatusBadRequest)
+		h.writeErrorPage(w, r, http.StatusBadRequest, "invalid_request", "No authorization code received")
 		return
 	}
 
 	// If using fixed redirect URI, handle proxy callback
-	if h.config.RedirectURIs != "" && !strings.Contains(h.config.RedirectURIs, ",") {
+	if h.hasFixedRedirect() {
 		// Verify and decode signed state parameter
 		stateData, err := h.verifyState(state)
 		if err != nil {
 			h.logger.Warn("SECURITY: State verification failed: %v", err)
-			http.Error(w, "Invalid state parameter", http.StatusBadRequest)
+			h.writeErrorPage(w, r, http.StatusBadRequest, "invalid_request", "Invalid state parameter")
 			return
 		}
 
@@ -457,35 +677,69 @@ func (h *OAuth2Handler) HandleCallback(w http.ResponseWriter, r *http.Request) {
 
 		if hasState && hasRedirect {
 			// Re-validate redirect URI for defense in depth
-			// Even though state is HMAC-signed, validate the redirect URI is localhost
-			if !isLocalhostURI(originalRedirectURI) {
-				h.logger.Warn("SECURITY: Callback redirect URI is not localhost (possible key compromise): %s", originalRedirectURI)
-				http.Error(w, "Invalid redirect URI in state", http.StatusBadRequest)
+			// Even though state is HMAC-signed, validate the redirect URI is still allowed
+			if err := h.validateClientRedirectURI(originalRedirectURI); err != nil {
+				h.logger.Warn("SECURITY: Callback redirect URI is not allowed (possible key compromise): %s: %v", originalRedirectURI, err)
+				h.writeErrorPage(w, r, http.StatusBadRequest, "invalid_request", "Invalid redirect URI in state")
 				return
 			}
 
-			h.logger.Info("OAuth2: State verified, proxying callback to localhost client: %s", originalRedirectURI)
+			if upstreamErr != nil {
+				h.logger.Info("OAuth2: State verified, relaying authorization error to client: %s", originalRedirectURI)
+				redirectOAuthError(w, r, originalRedirectURI, originalState, upstreamErr)
+				return
+			}
+
+			h.logger.Info("OAuth2: State verified, proxying callback to client: %s", originalRedirectURI)
 
-			// Build proxy callback URL
-			proxyURL := fmt.Sprintf("%s?code=%s&state=%s", originalRedirectURI, code, originalState)
-			http.Redirect(w, r, proxyURL, http.StatusFound)
+			// Bind the code to the client's PKCE challenge for /oauth/token
+			if h.pkce != nil {
+				h.pkce.add(code, &pkceTransaction{
+					challenge:   stateData["code_challenge"],
+					method:      stateData["code_challenge_method"],
+					redirectURI: originalRedirectURI,
+					upstream:    stateData["idp"],
+				})
+			}
+
+			// Remember which browser session approved the consent page for /oauth/token
+			if h.consent != nil {
+				h.consent.bindCode(r, code)
+			}
+
+			// Build proxy callback URL, keeping any query of a registered redirect URI
+			proxyURL, err := url.Parse(originalRedirectURI)
+			if err != nil {
+				h.writeErrorPage(w, r, http.StatusBadRequest, "invalid_request", "Invalid redirect URI in state")
+				return
+			}
+			proxyQuery := proxyURL.Query()
+			proxyQuery.Set("code", code)
+			proxyQuery.Set("state", originalState)
+			proxyURL.RawQuery = proxyQuery.Encode()
+			http.Redirect(w, r, proxyURL.String(), http.StatusFound)
 			return
 		}
 
 		h.logger.Error("OAuth2: State missing required fields")
-		http.Error(w, "Invalid state format", http.StatusBadRequest)
+		h.writeErrorPage(w, r, http.StatusBadRequest, "invalid_request", "Invalid state format")
+		return
+	}
+
+	if upstreamErr != nil {
+		h.writeErrorPageResponse(w, r, upstreamErr)
 		return
 	}
 
 	// For non-fixed redirect mode or as fallback, show success page
-	h.showSuccessPage(w, code, state)
+	h.showSuccessPage(w, r, code, state)
 }
 
 // HandleToken handles OAuth2 token exchange
 func (h *OAuth2Handler) HandleToken(w http.ResponseWriter, r *http.Request) {
 	// Defense in depth: Check OAuth mode
 	if h.config.Mode == "native" {
-		http.Error(w, "OAuth proxy disabled in native mode", http.StatusNotFound)
+		writeOAuthError(w, http.StatusNotFound, "invalid_request", "OAuth proxy disabled in native mode")
 		return
 	}
 
@@ -501,7 +755,7 @@ func (h *OAuth2Handler) HandleToken(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if r.Method != "POST" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeOAuthError(w, http.StatusMethodNotAllowed, "invalid_request", "Method not allowed")
 		return
 	}
 
@@ -510,7 +764,7 @@ func (h *OAuth2Handler) HandleToken(w http.ResponseWriter, r *http.Request) {
 	// Parse form data
 	if err := r.ParseForm(); err != nil {
 		h.logger.Error("OAuth2: Failed to parse form: %v", err)
-		http.Error(w, "Invalid request", http.StatusBadRequest)
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "Invalid request")
 		return
 	}
 
@@ -520,57 +774,133 @@ func (h *OAuth2Handler) HandleToken(w http.ResponseWriter, r *http.Request) {
 	clientRedirectURI := r.FormValue("redirect_uri")
 	clientID := r.FormValue("client_id")
 	codeVerifier := r.FormValue("code_verifier")
+	resources := r.Form["resource"]
 
-	h.logger.Info("OAuth2: Token request - grant_type: %s, client_id: %s, redirect_uri: %s, code: %s",
-		grantType, clientID, clientRedirectURI, truncateString(code, 10))
+	h.logger.Info("OAuth2: Token request - grant_type: %s, client_id: %s, redirect_uri: %s, code: %s, resource: %v",
+		grantType, clientID, clientRedirectURI, truncateString(code, 10), resources)
+
+	// Device authorization grant (RFC 8628)
+	if grantType == grantTypeDeviceCode {
+		h.handleDeviceCodeToken(w, r)
+		return
+	}
 
 	// Validate parameters
-	if code == "" {
-		h.logger.Error("OAuth2: Missing authorization code")
-		http.Error(w, "Missing authorization code", http.StatusBadRequest)
+	if grantType == "" {
+		h.logger.Error("OAuth2: Missing grant type")
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "Missing grant_type")
 		return
 	}
 
 	if grantType != "authorization_code" {
 		h.logger.Error("OAuth2: Unsupported grant type: %s", grantType)
-		http.Error(w, "Unsupported grant type", http.StatusBadRequest)
+		writeOAuthError(w, http.StatusBadRequest, "unsupported_grant_type", "Unsupported grant type")
+		return
+	}
+
+	if code == "" {
+		h.logger.Error("OAuth2: Missing authorization code")
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "Missing authorization code")
+		return
+	}
+
+	// Validate resource indicators (RFC 8707)
+	if err := h.validateResources(resources); err != nil {
+		h.logger.Warn("SECURITY: Invalid resource parameter: %v", err)
+		writeOAuthError(w, http.StatusBadRequest, "invalid_target", "Invalid resource parameter")
+		return
+	}
+
+	// Verify the PKCE code_verifier before redeeming the code upstream
+	if codeVerifier == "" {
+		h.logger.Warn("SECURITY: Missing code_verifier")
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "Missing code_verifier")
+		return
+	}
+	hasFixedRedirect := h.hasFixedRedirect()
+	transaction, err := h.verifyPKCE(code, codeVerifier, clientRedirectURI, hasFixedRedirect)
+	if err != nil {
+		h.logger.Warn("SECURITY: PKCE verification failed: %v", err)
+		writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "Invalid code_verifier")
+		return
+	}
+
+	// Redeem the code at the provider that issued it
+	upstreamName := ""
+	if transaction != nil {
+		upstreamName = transaction.upstream
+	}
+	upstream := h.upstream(upstreamName)
+	if upstream == nil {
+		h.logger.Warn("SECURITY: Authorization code from unknown provider: %s", upstreamName)
+		writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "Invalid authorization code")
 		return
 	}
 
 	// Set redirect URI for token exchange
 	redirectURI := clientRedirectURI
-	if h.config.RedirectURIs != "" && !strings.Contains(h.config.RedirectURIs, ",") {
+	if hasFixedRedirect {
 		redirectURI = strings.TrimSpace(h.config.RedirectURIs)
 		h.logger.Info("OAuth2: Token exchange using fixed redirect URI: %s", redirectURI)
 	}
 
-	h.oauth2Config.RedirectURL = redirectURI
+	oauth2Config := *upstream.config
+	oauth2Config.RedirectURL = redirectURI
 
 	// For PKCE, we need to manually add the code_verifier to the token exchange
 	// Since oauth2 library doesn't support PKCE directly, we'll use a custom approach
-	ctx := context.Background()
-
-	// Create custom HTTP client for token exchange with PKCE
-	if codeVerifier != "" {
-		// Create a custom client that adds code_verifier to the token request
+	client := h.upstreamClient()
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, client)
+
+	// Create custom HTTP client for token exchange with PKCE and resource indicators
+	if codeVerifier != "" || len(resources) > 0 {
+		base := client.Transport
+		if base == nil {
+			base = http.DefaultTransport
+		}
+		// Create a custom client that adds code_verifier and resource to the token request
 		customClient := &http.Client{
-			Transport: &pkceTransport{
-				base:         http.DefaultTransport,
+			Timeout: client.Timeout,
+			Transport: &upstreamTokenTransport{
+				base:         base,
 				codeVerifier: codeVerifier,
+				resources:    resources,
 			},
 		}
 		ctx = context.WithValue(ctx, oauth2.HTTPClient, customClient)
 	}
 
 	// Exchange code for tokens
-	token, err := h.oauth2Config.Exchange(ctx, code)
+	token, err := oauth2Config.Exchange(ctx, code)
 	if err != nil {
 		h.logger.Error("OAuth2: Token exchange failed: %v", err)
-		http.Error(w, "Token exchange failed", http.StatusInternalServerError)
+		writeOAuthErrorResponse(w, exchangeOAuthError(err))
 		return
 	}
 
-	h.logger.Info("OAuth2: Token exchange successful")
+	h.logger.Info("OAuth2: Token exchange with %s successful", upstream.name)
+
+	// Remember consent given in the browser for the user who redeemed the code
+	if h.consent != nil {
+		if err := h.consent.complete(r.Context(), code, upstreamSubjectKey(upstream, token)); err != nil {
+			h.logger.Error("OAuth2: %v", err)
+		}
+	}
+
+	h.writeTokenResponse(w, upstream, token)
+}
+
+// writeTokenResponse stores the token from the upstream provider in the vault
+// (if configured) and returns it to the client
+func (h *OAuth2Handler) writeTokenResponse(w http.ResponseWriter, upstream *upstreamIdP, token *oauth2.Token) {
+	// Keep the upstream tokens for tool handlers (see GetUpstreamToken)
+	if h.config.TokenVault != nil {
+		if subject := upstreamSubjectKey(upstream, token); subject == "" {
+			h.logger.Warn("OAuth2: Upstream token has no subject, not storing it in the token vault")
+		} else if err := h.config.TokenVault.store(subject, upstream.name, token); err != nil {
+			h.logger.Error("OAuth2: Failed to store upstream token: %v", err)
+		}
+	}
 
 	// Build response
 	response := map[string]interface{}{
@@ -605,29 +935,18 @@ func (h *OAuth2Handler) HandleToken(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// showSuccessPage displays a success page after OAuth completion
-func (h *OAuth2Handler) showSuccessPage(w http.ResponseWriter, code, state string) {
+// showSuccessPage displays a success page after OAuth completion, with the
+// client and signed-in user of the consent page session when known
+func (h *OAuth2Handler) showSuccessPage(w http.ResponseWriter, r *http.Request, code, state string) {
 	// Log authorization details server-side (truncated for security)
 	h.logger.Info("OAuth2: Authorization successful - code: %s, state: %s",
 		truncateString(code, 10), truncateString(state, 10))
 
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	w.Header().Set("Cache-Control", "no-store")
-	w.Header().Set("X-Content-Type-Options", "nosniff")
-	w.WriteHeader(http.StatusOK)
-	_, _ = fmt.Fprintf(w, `<!DOCTYPE html>
-		<html lang="en">
-		<head>
-			<meta charset="utf-8">
-			<meta name="viewport" content="width=device-width, initial-scale=1">
-			<title>OAuth2 Success</title>
-		</head>
-		<body>
-			<h2>Authentication Successful!</h2>
-			<p>You have been successfully authenticated.</p>
-			<p>You can now close this window and return to your application.</p>
-		</body>
-		</html>`)
+	var data PageData
+	if h.consent != nil {
+		data = h.consent.sessionPageData(r)
+	}
+	h.renderPage(w, http.StatusOK, successPageTemplate, data)
 }
 
 // truncateString safely truncates a string for logging
@@ -638,20 +957,30 @@ func truncateString(s string, maxLen int) string {
 	return s[:maxLen] + "..."
 }
 
-// pkceTransport adds PKCE code_verifier to token exchange requests
-type pkceTransport struct {
+// upstreamClient returns the HTTP client for requests to the upstream provider
+func (h *OAuth2Handler) upstreamClient() *http.Client {
+	if h.httpClient == nil {
+		return http.DefaultClient
+	}
+	return h.httpClient
+}
+
+// upstreamTokenTransport adds the PKCE code_verifier and the resource
+// indicators (RFC 8707) of the client's request to token exchange requests
+type upstreamTokenTransport struct {
 	base         http.RoundTripper
 	codeVerifier string
+	resources    []string
 }
 
 // RoundTrip implements the RoundTripper interface
-func (p *pkceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+func (p *upstreamTokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 	// Only modify POST requests to token endpoint
 	if req.Method == "POST" && strings.Contains(req.URL.Path, "/token") {
 		// Read the existing body
 		defer func() {
 			if closeErr := req.Body.Close(); closeErr != nil {
-				// Note: pkceTransport doesn't have access to h.logger, using standard log
+				// Note: upstreamTokenTransport doesn't have access to h.logger, using standard log
 				log.Printf("Warning: failed to close request body: %v", closeErr)
 			}
 		}()
@@ -671,7 +1000,12 @@ func (p *pkceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 			values.Set("code_verifier", p.codeVerifier)
 		}
 
-		// Create new body with code_verifier
+		// Forward resource indicators (RFC 8707)
+		if len(values["resource"]) == 0 && len(p.resources) > 0 {
+			values["resource"] = p.resources
+		}
+
+		// Create new body with code_verifier and resource
 		newBody := strings.NewReader(values.Encode())
 		req.Body = io.NopCloser(newBody)
 		req.ContentLength = int64(len(values.Encode()))
@@ -690,19 +1024,8 @@ func getEnv(key, def string) string {
 
 // signState signs state data with HMAC-SHA256 for integrity protection
 func (h *OAuth2Handler) signState(stateData map[string]string) (string, error) {
-	// Create deterministic string for signing
-	dataToSign := ""
-	if state, ok := stateData["state"]; ok {
-		dataToSign += "state=" + state + "&"
-	}
-	if redirect, ok := stateData["redirect"]; ok {
-		dataToSign += "redirect=" + redirect
-	}
-
-	// Create HMAC signature
-	mac := hmac.New(sha256.New, h.config.stateSigningKey)
-	mac.Write([]byte(dataToSign))
-	signature := hex.EncodeToString(mac.Sum(nil))
+	// Create HMAC signature over all fields
+	signature := h.stateSignature(stateData)
 
 	// Add signature to state data
 	stateData["sig"] = signature
@@ -715,6 +1038,21 @@ func (h *OAuth2Handler) signState(stateData map[string]string) (string, error) {
 	return base64.URLEncoding.EncodeToString(signedData), nil
 }
 
+// stateSignature computes the HMAC-SHA256 of all state fields (except "sig")
+// in a deterministic, unambiguous encoding
+func (h *OAuth2Handler) stateSignature(stateData map[string]string) string {
+	fields := url.Values{}
+	for key, value := range stateData {
+		if key != "sig" {
+			fields.Set(key, value)
+		}
+	}
+
+	mac := hmac.New(sha256.New, h.config.stateSigningKey)
+	mac.Write([]byte(fields.Encode())) // Encode sorts by key
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
 // verifyState verifies and decodes HMAC-signed state parameter
 func (h *OAuth2Handler) verifyState(encodedState string) (map[string]string, error) {
 	// Base64 decode
@@ -737,17 +1075,7 @@ func (h *OAuth2Handler) verifyState(encodedState string) (map[string]string, err
 	delete(stateData, "sig") // Remove for verification
 
 	// Recalculate signature using same deterministic approach
-	dataToSign := ""
-	if state, ok := stateData["state"]; ok {
-		dataToSign += "state=" + state + "&"
-	}
-	if redirect, ok := stateData["redirect"]; ok {
-		dataToSign += "redirect=" + redirect
-	}
-
-	mac := hmac.New(sha256.New, h.config.stateSigningKey)
-	mac.Write([]byte(dataToSign))
-	expectedSig := hex.EncodeToString(mac.Sum(nil))
+	expectedSig := h.stateSignature(stateData)
 
 	// Verify signature using constant-time comparison
 	if !hmac.Equal([]byte(receivedSig), []byte(expectedSig)) {
@@ -776,7 +1104,7 @@ func (h *OAuth2Handler) isValidRedirectURI(uri string) bool {
 		return false
 	}
 
-	// Parse allowlist
+	// Parse allowlist; entries may use loopback, path prefix and wildcard host patterns
 	allowedURIs := strings.Split(h.config.RedirectURIs, ",")
 	for _, allowed := range allowedURIs {
 		allowed = strings.TrimSpace(allowed)
@@ -785,7 +1113,7 @@ func (h *OAuth2Handler) isValidRedirectURI(uri string) bool {
 		}
 	}
 
-	return false
+	return matchRedirectURI(allowedURIs, uri)
 }
 
 // validateOAuthParams performs basic input validation to prevent abuse
@@ -0,0 +1,268 @@
+package oauth
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// HandleLogout handles /oauth/logout (proxy mode): it signs the user out of
+// the proxy and, when the upstream provider advertises an end_session_endpoint,
+// out of the provider (OpenID Connect RP-Initiated Logout 1.0).
+//
+// The user is identified by a valid access token in the Authorization header
+// or by the consent page session cookie. Their cached token validations,
+// vault tokens and consent session are removed. A user identified only by
+// the cookie must first confirm on a page whose form is posted with a CSRF
+// token, so that other sites cannot sign users out (OpenID Connect
+// RP-Initiated Logout 1.0 Section 6). Requests without either still clear
+// the session cookie and continue the logout.
+//
+// Parameters (query or form):
+//   - post_logout_redirect_uri: where to send the browser afterwards; must be
+//     an allowed client redirect URI
+//   - state: returned to post_logout_redirect_uri unchanged
+//   - id_token_hint: forwarded to the upstream provider
+//
+// Without post_logout_redirect_uri, browsers get the logout page and other
+// clients 204 No Content.
+func (s *Server) HandleLogout(w http.ResponseWriter, r *http.Request) {
+	h := s.handler
+	if h.config.Mode == "native" {
+		h.writeErrorPage(w, r, http.StatusNotFound, "invalid_request", "Logout endpoint disabled in native mode")
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		writeOAuthError(w, http.StatusMethodNotAllowed, "invalid_request", "Method not allowed")
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		h.writeErrorPage(w, r, http.StatusBadRequest, "invalid_request", "Invalid request")
+		return
+	}
+
+	// The upstream provider sends the browser back here with our signed state
+	if stateData, err := h.verifyState(r.Form.Get("state")); err == nil && stateData["logout"] == "1" {
+		s.finishLogout(w, r, stateData["redirect"], stateData["state"], "")
+		return
+	}
+
+	redirectURI := r.Form.Get("post_logout_redirect_uri")
+	if redirectURI != "" && !h.allowsRedirectURI(redirectURI) {
+		h.logger.Warn("SECURITY: Rejected logout with post_logout_redirect_uri %s", redirectURI)
+		h.writeErrorPage(w, r, http.StatusBadRequest, "invalid_request", "Invalid post_logout_redirect_uri")
+		return
+	}
+
+	// Identify the user from the access token or the browser session
+	var user *User
+	if scheme, token := parseAuthorizationHeader(r.Header.Get("Authorization")); token != "" {
+		validated, err := s.ValidateTokenCached(withRequestInfo(r.Context(), newRequestInfo(r, scheme)), token)
+		if err != nil {
+			s.writeAuthChallenge(w, asAuthError(err))
+			return
+		}
+		user = validated
+	}
+
+	// Ask a browser identified only by its session cookie to confirm
+	if user == nil && h.consent != nil {
+		csrfToken, sessionSubject, err := h.consent.logoutForm(r)
+		if err != nil {
+			h.logger.Error("OAuth2: Failed to create logout form: %v", err)
+			h.writeErrorPage(w, r, http.StatusInternalServerError, "server_error", "Internal server error")
+			return
+		}
+		if csrfToken != "" && (r.Method != http.MethodPost || subtle.ConstantTimeCompare([]byte(r.PostForm.Get("csrf_token")), []byte(csrfToken)) != 1) {
+			s.showLogoutConfirmation(w, r, csrfToken, sessionSubject)
+			return
+		}
+	}
+
+	subject := ""
+	if h.consent != nil {
+		subject = h.consent.endSession(r)
+	}
+	displayName := keySubject(subject)
+	if user != nil {
+		subject, displayName = vaultSubject(user), user.Username
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     consentCookieName,
+		Value:    "",
+		Path:     h.cookiePath(),
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(h.config.MCPURL, "https://"),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	// Revoke local state. The upstream is chosen before the vault record is deleted.
+	upstream := h.upstream("")
+	idTokenHint := r.Form.Get("id_token_hint")
+	if user != nil {
+		upstream = h.upstreamForIssuer(user.Issuer)
+	}
+	if subject != "" {
+		s.cache.deleteSubject(subject)
+		if s.exchanger != nil {
+			s.exchanger.deleteSubject(subject)
+		}
+		if vault := h.config.TokenVault; vault != nil {
+			if record, err := vault.get(subject); err == nil {
+				if named := h.upstream(record.Upstream); named != nil {
+					upstream = named
+				}
+				if idTokenHint == "" {
+					idTokenHint = record.IDToken
+				}
+			}
+			vault.Delete(subject)
+		}
+		h.logger.Info("OAuth2: Signed out subject %s", subject)
+	}
+
+	if upstream == nil || upstream.endSessionURL == "" {
+		s.finishLogout(w, r, redirectURI, r.Form.Get("state"), displayName)
+		return
+	}
+
+	endSession, err := url.Parse(upstream.endSessionURL)
+	if err != nil {
+		h.logger.Error("OAuth2: Invalid end_session_endpoint %s: %v", upstream.endSessionURL, err)
+		s.finishLogout(w, r, redirectURI, r.Form.Get("state"), displayName)
+		return
+	}
+	signedState, err := h.signState(map[string]string{
+		"logout":   "1",
+		"redirect": redirectURI,
+		"state":    r.Form.Get("state"),
+	})
+	if err != nil {
+		h.logger.Error("OAuth2: Failed to sign logout state: %v", err)
+		h.writeErrorPage(w, r, http.StatusInternalServerError, "server_error", "Internal server error")
+		return
+	}
+
+	query := endSession.Query()
+	query.Set("client_id", upstream.config.ClientID)
+	query.Set("post_logout_redirect_uri", s.GetLogoutURL())
+	query.Set("state", signedState)
+	if idTokenHint != "" {
+		query.Set("id_token_hint", idTokenHint)
+	}
+	endSession.RawQuery = query.Encode()
+
+	h.logger.Info("OAuth2: Redirecting logout to upstream provider %s", upstream.name)
+	h.addSecurityHeaders(w)
+	http.Redirect(w, r, endSession.String(), http.StatusFound)
+}
+
+// showLogoutConfirmation renders the form that confirms a logout requested
+// with only the session cookie. The request parameters are kept in the form's
+// action URL.
+func (s *Server) showLogoutConfirmation(w http.ResponseWriter, r *http.Request, csrfToken, subject string) {
+	params := url.Values{}
+	for _, name := range []string{"post_logout_redirect_uri", "state", "id_token_hint"} {
+		if value := r.Form.Get(name); value != "" {
+			params.Set(name, value)
+		}
+	}
+	action := s.GetLogoutURL()
+	if len(params) > 0 {
+		action += "?" + params.Encode()
+	}
+
+	s.handler.renderPage(w, http.StatusOK, logoutConfirmPageTemplate, PageData{
+		User:      keySubject(subject),
+		Action:    action,
+		CSRFToken: csrfToken,
+	})
+}
+
+// finishLogout sends the browser to post_logout_redirect_uri, or shows the logout page
+func (s *Server) finishLogout(w http.ResponseWriter, r *http.Request, redirectURI, state, user string) {
+	h := s.handler
+	if redirectURI != "" {
+		target, err := url.Parse(redirectURI)
+		if err != nil {
+			h.writeErrorPage(w, r, http.StatusBadRequest, "invalid_request", "Invalid post_logout_redirect_uri")
+			return
+		}
+		if state != "" {
+			query := target.Query()
+			query.Set("state", state)
+			target.RawQuery = query.Encode()
+		}
+		h.addSecurityHeaders(w)
+		http.Redirect(w, r, target.String(), http.StatusFound)
+		return
+	}
+
+	if !acceptsHTML(r) {
+		h.addSecurityHeaders(w)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	h.renderPage(w, http.StatusOK, logoutPageTemplate, PageData{User: user})
+}
+
+// deleteSubject removes the cached validations of all tokens issued to subject
+func (tc *TokenCache) deleteSubject(subject string) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	for tokenHash, cached := range tc.cache {
+		if vaultSubject(cached.User) == subject {
+			delete(tc.cache, tokenHash)
+		}
+	}
+}
+
+// deleteSubject removes the tokens exchanged for subject
+func (e *tokenExchanger) deleteSubject(subject string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for key := range e.cache {
+		if strings.HasPrefix(key, subject+"\x00") {
+			delete(e.cache, key)
+		}
+	}
+}
+
+// logoutForm returns the CSRF token of the logout confirmation form of the
+// request's browser session and the subject that signed in with it. The token
+// is empty without a session.
+func (c *consentManager) logoutForm(r *http.Request) (string, string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, session := c.session(r)
+	if session == nil {
+		return "", "", nil
+	}
+	if session.logoutToken == "" {
+		token, err := randomToken()
+		if err != nil {
+			return "", "", err
+		}
+		session.logoutToken = token
+	}
+	return session.logoutToken, session.subject, nil
+}
+
+// endSession removes the browser session of the request's cookie and returns
+// the subject that signed in with it, if known
+func (c *consentManager) endSession(r *http.Request) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	sessionID, session := c.session(r)
+	if session == nil {
+		return ""
+	}
+	delete(c.sessions, sessionID)
+	return session.subject
+}
@@ -0,0 +1,204 @@
+package oauth
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var logoutActionPattern = regexp.MustCompile(`<form method="post" action="([^"]+)"`)
+
+// logout sends a request to /oauth/logout
+func logout(server *Server, params url.Values, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/oauth/logout?"+params.Encode(), nil)
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	rec := httptest.NewRecorder()
+	server.HandleLogout(rec, req)
+	return rec
+}
+
+// TestLogout tests revoking local state and logging out at the upstream provider
+func TestLogout(t *testing.T) {
+	var accessToken string
+	server, vault := newVaultTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"access_token": accessToken, "token_type": "Bearer", "expires_in": 3600, "id_token": "id-token-1"})
+	})
+	accessToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"iss": server.config.Issuer,
+		"sub": "user-1",
+		"aud": "api://test",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}).SignedString(server.config.JWTSecret)
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+	server.handler.upstreams[0].endSessionURL = "https://idp.example.com/logout?tenant=1"
+
+	form := url.Values{"grant_type": {"authorization_code"}, "code": {"auth-code"}, "code_verifier": {testCodeVerifier}}
+	if rec := postForm(server.handler.HandleToken, "/oauth/token", form); rec.Code != http.StatusOK {
+		t.Fatalf("Token exchange failed: %d %s", rec.Code, rec.Body.String())
+	}
+	user, err := server.ValidateTokenCached(context.Background(), accessToken)
+	if err != nil {
+		t.Fatalf("Token validation failed: %v", err)
+	}
+	ctx := WithUser(WithOAuthToken(context.Background(), accessToken), user)
+	if _, err := server.ExchangeToken(ctx, "api://billing", nil); err != nil {
+		t.Fatalf("Token exchange failed: %v", err)
+	}
+
+	params := url.Values{"post_logout_redirect_uri": {"https://client.example.com/callback"}, "state": {"client-state"}}
+	rec := logout(server, params, http.Header{"Authorization": {"Bearer " + accessToken}})
+	if rec.Code != http.StatusFound {
+		t.Fatalf("Expected redirect to the provider, got %d: %s", rec.Code, rec.Body.String())
+	}
+	location, _ := url.Parse(rec.Header().Get("Location"))
+	query := location.Query()
+	if location.Host != "idp.example.com" || query.Get("tenant") != "1" || query.Get("client_id") != "mcp-server" || query.Get("id_token_hint") != "id-token-1" {
+		t.Errorf("Unexpected end session redirect: %s", location)
+	}
+	if query.Get("post_logout_redirect_uri") != "https://mcp.example.com/oauth/logout" {
+		t.Errorf("Expected the provider to return to /oauth/logout, got %q", query.Get("post_logout_redirect_uri"))
+	}
+
+	// The vault record, cached validation and exchanged tokens are gone
+	if _, err := vault.get(subjectKey(server.config.Issuer, "user-1")); err == nil {
+		t.Error("Expected vault record to be deleted")
+	}
+	if _, cached := server.cache.getCachedToken(fmt.Sprintf("%x", sha256.Sum256([]byte(accessToken)))); cached {
+		t.Error("Expected cached validation to be deleted")
+	}
+	if len(server.exchanger.cache) != 0 {
+		t.Error("Expected exchanged tokens to be deleted")
+	}
+
+	// The provider returns to /oauth/logout, which continues to the client
+	rec = logout(server, url.Values{"state": {query.Get("state")}}, nil)
+	if location := rec.Header().Get("Location"); rec.Code != http.StatusFound || location != "https://client.example.com/callback?state=client-state" {
+		t.Errorf("Expected redirect to the client, got %d: %s", rec.Code, location)
+	}
+}
+
+// TestLogout_Confirmation tests that a logout identified only by the session cookie must be confirmed with the form
+func TestLogout_Confirmation(t *testing.T) {
+	vault, err := NewTokenVault(bytes.Repeat([]byte("k"), 32))
+	if err != nil {
+		t.Fatalf("Failed to create vault: %v", err)
+	}
+	server := newTestServer(t, http.NotFound, func(cfg *Config) {
+		cfg.RedirectURIs = "https://client.example.com/callback,https://client.example.com/alt"
+		cfg.Consent = true
+		cfg.TokenVault = vault
+	})
+	subject := subjectKey(server.config.Issuer, "user-1")
+	server.handler.consent.sessions["session-1"] = &consentSession{subject: subject, approvals: map[string][]string{}, expiresAt: time.Now().Add(time.Hour)}
+	if err := vault.put(subject, &vaultRecord{AccessToken: "upstream-token"}); err != nil {
+		t.Fatalf("put failed: %v", err)
+	}
+	cookie := &http.Cookie{Name: consentCookieName, Value: "session-1"}
+
+	// A cross-site GET only shows the confirmation page
+	params := url.Values{"post_logout_redirect_uri": {"https://client.example.com/callback"}, "state": {"xyz"}}
+	req := httptest.NewRequest(http.MethodGet, "/oauth/logout?"+params.Encode(), nil)
+	req.AddCookie(cookie)
+	rec := httptest.NewRecorder()
+	server.HandleLogout(rec, req)
+	csrfToken := csrfTokenPattern.FindStringSubmatch(rec.Body.String())
+	action := logoutActionPattern.FindStringSubmatch(rec.Body.String())
+	if rec.Code != http.StatusOK || csrfToken == nil || action == nil || !strings.Contains(rec.Body.String(), "user-1") {
+		t.Fatalf("Expected confirmation page, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if _, err := vault.get(subject); err != nil {
+		t.Error("Expected vault record to be kept until the logout is confirmed")
+	}
+
+	post := func(csrfToken string) *httptest.ResponseRecorder {
+		form := url.Values{"csrf_token": {csrfToken}}
+		req := httptest.NewRequest(http.MethodPost, html.UnescapeString(action[1]), strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(cookie)
+		rec := httptest.NewRecorder()
+		server.HandleLogout(rec, req)
+		return rec
+	}
+
+	if rec := post("forged"); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "Sign out?") {
+		t.Errorf("Expected confirmation page for an invalid CSRF token, got %d", rec.Code)
+	}
+	if _, err := vault.get(subject); err != nil {
+		t.Error("Expected vault record to be kept after an invalid CSRF token")
+	}
+
+	// The confirmed form signs the user out and continues to the client
+	rec = post(csrfToken[1])
+	if location := rec.Header().Get("Location"); rec.Code != http.StatusFound || location != "https://client.example.com/callback?state=xyz" {
+		t.Errorf("Expected redirect to the client, got %d: %s", rec.Code, location)
+	}
+	if _, err := vault.get(subject); err == nil {
+		t.Error("Expected vault record to be deleted")
+	}
+	if _, ok := server.handler.consent.sessions["session-1"]; ok {
+		t.Error("Expected session to be ended")
+	}
+}
+
+// TestLogout_Validation tests logout without an upstream end_session_endpoint and invalid requests
+func TestLogout_Validation(t *testing.T) {
+	server, _ := newVaultTestServer(t, http.NotFound)
+
+	rec := logout(server, url.Values{"post_logout_redirect_uri": {"https://evil.example.com/"}}, nil)
+	if rec.Code != http.StatusBadRequest || decodeOAuthError(t, rec).Error != "invalid_request" {
+		t.Errorf("Expected unregistered post_logout_redirect_uri to be rejected, got %d", rec.Code)
+	}
+	if rec := logout(server, nil, http.Header{"Authorization": {"Bearer invalid"}}); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected invalid access token to be rejected, got %d", rec.Code)
+	}
+
+	// Without an upstream logout the proxy finishes the logout itself
+	rec = logout(server, url.Values{"post_logout_redirect_uri": {"https://client.example.com/alt"}, "state": {"xyz"}}, nil)
+	if location := rec.Header().Get("Location"); rec.Code != http.StatusFound || location != "https://client.example.com/alt?state=xyz" {
+		t.Errorf("Expected redirect to the client, got %d: %s", rec.Code, location)
+	}
+	rec = logout(server, nil, http.Header{"Accept": {"text/html"}})
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "Signed out") {
+		t.Errorf("Expected logout page, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if cookies := rec.Result().Cookies(); len(cookies) != 1 || cookies[0].Name != consentCookieName || cookies[0].MaxAge >= 0 {
+		t.Errorf("Expected session cookie to be cleared, got %v", cookies)
+	}
+	if rec := logout(server, nil, nil); rec.Code != http.StatusNoContent {
+		t.Errorf("Expected 204 for non-browser clients, got %d", rec.Code)
+	}
+
+	// Discovery advertises the endpoint
+	rec = httptest.NewRecorder()
+	server.handler.HandleOIDCDiscovery(rec, httptest.NewRequest(http.MethodGet, "/.well-known/openid-configuration", nil))
+	var metadata map[string]interface{}
+	_ = json.NewDecoder(rec.Body).Decode(&metadata)
+	if metadata["end_session_endpoint"] != "https://mcp.example.com/oauth/logout" {
+		t.Errorf("Expected end_session_endpoint in discovery, got %v", metadata["end_session_endpoint"])
+	}
+
+	// Upstream end_session_endpoints are discovered
+	var tokens int32
+	idp := newTestIdP(t, "token", &tokens)
+	handler := NewOAuth2Handler(&OAuth2Config{Mode: "proxy", Provider: "oidc", Issuer: idp.URL, ClientID: "client", MCPURL: "https://mcp.example.com"}, nil)
+	if handler.upstream("").endSessionURL != idp.URL+"/logout" {
+		t.Errorf("Expected discovered end_session_endpoint, got %q", handler.upstream("").endSessionURL)
+	}
+}
@@ -2,6 +2,7 @@ package mark3labs
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/mark3labs/mcp-go/mcp"
@@ -15,8 +16,10 @@ import (
 // The middleware:
 //  1. Extracts OAuth token from context (set by CreateHTTPContextFunc)
 //  2. Validates token using Server.ValidateTokenCached (with 5-minute cache)
-//  3. Adds User to context via oauth.WithUser
-//  4. Passes request to tool handler with authenticated context
+//  3. Enforces the tool's step-up requirement, returning an MCP tool error
+//     with the RFC 9470 acr_values/max_age parameters when it is not met
+//  4. Adds User to context via oauth.WithUser
+//  5. Passes request to tool handler with authenticated context
 //
 // Use oauth.GetUserFromContext(ctx) in tool handlers to access authenticated user.
 func NewMiddleware(s *oauth.Server) func(server.ToolHandlerFunc) server.ToolHandlerFunc {
@@ -32,7 +35,18 @@ func NewMiddleware(s *oauth.Server) func(server.ToolHandlerFunc) server.ToolHand
 				return nil, err
 			}
 
+			if err := s.CheckStepUp(user, req.Params.Name); err != nil {
+				var authErr *oauth.AuthError
+				if !errors.As(err, &authErr) {
+					return nil, err
+				}
+				result := mcp.NewToolResultStructured(authErr.StructuredContent(), authErr.Description)
+				result.IsError = true
+				return result, nil
+			}
+
 			ctx = oauth.WithUser(ctx, user)
+			ctx = oauth.WithServer(ctx, s)
 
 			return next(ctx, req)
 		}
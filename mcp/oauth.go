@@ -39,7 +39,8 @@ import (
 // - LogStartup() - Log OAuth endpoint information
 // - Discovery URL helpers (GetCallbackURL, GetMetadataURL, etc.)
 //
-// The HTTP handler validates OAuth tokens before delegating to the MCP server.
+// The HTTP handler validates OAuth tokens (including DPoP proofs when
+// cfg.DPoPMode is set) via oauthServer.WrapHandler before delegating to the MCP server.
 // Tool handlers can access the authenticated user via oauth.GetUserFromContext(ctx).
 func WithOAuth(mux *http.ServeMux, cfg *oauth.Config, mcpServer *mcp.Server) (*oauth.Server, http.Handler, error) {
 	oauthServer, err := oauth.NewServer(cfg)
@@ -53,27 +54,7 @@ func WithOAuth(mux *http.ServeMux, cfg *oauth.Config, mcpServer *mcp.Server) (*o
 		return mcpServer
 	}, nil)
 
-	wrappedHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" || len(authHeader) < 7 || authHeader[:7] != "Bearer " {
-			http.Error(w, "Missing or invalid Authorization header", http.StatusUnauthorized)
-			return
-		}
-
-		token := authHeader[7:]
-
-		user, err := oauthServer.ValidateTokenCached(r.Context(), token)
-		if err != nil {
-			http.Error(w, fmt.Sprintf("Authentication failed: %v", err), http.StatusUnauthorized)
-			return
-		}
-
-		ctx := oauth.WithOAuthToken(r.Context(), token)
-		ctx = oauth.WithUser(ctx, user)
-		r = r.WithContext(ctx)
-
-		mcpHandler.ServeHTTP(w, r)
-	})
+	wrappedHandler := oauthServer.WrapHandler(mcpHandler)
 
 	return oauthServer, wrappedHandler, nil
 }
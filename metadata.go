@@ -29,8 +29,10 @@ func (h *OAuth2Handler) HandleMetadata(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Create provider-specific metadata
-	metadata := map[string]interface{}{
+	// Standard fields come from the authorization server metadata; the legacy
+	// fields are added as extension parameters
+	metadata := h.GetAuthorizationServerMetadata()
+	legacy := map[string]interface{}{
 		"oauth_enabled":          true,
 		"authentication_methods": []string{"bearer_token"},
 		"token_types":            []string{"JWT"},
@@ -39,35 +41,28 @@ func (h *OAuth2Handler) HandleMetadata(w http.ResponseWriter, r *http.Request) {
 		"mcp_version":            "1.0.0",
 		"server_version":         h.config.Version,
 		"provider":               h.config.Provider,
-
-		// Add OIDC discovery fields for MCP client compatibility
-		"issuer":                   h.config.MCPURL,
-		"authorization_endpoint":   fmt.Sprintf("%s/oauth/authorize", h.config.MCPURL),
-		"token_endpoint":           fmt.Sprintf("%s/oauth/token", h.config.MCPURL),
-		"registration_endpoint":    fmt.Sprintf("%s/oauth/register", h.config.MCPURL),
-		"response_types_supported": []string{"code"},
-		"response_modes_supported": []string{"query"},
-		"grant_types_supported":    []string{"authorization_code"},
 	}
 
 	// Add provider-specific metadata
 	switch h.config.Provider {
 	case "hmac":
-		metadata["validation_method"] = "hmac_sha256"
-		metadata["signature_algorithm"] = "HS256"
-		metadata["requires_secret"] = true
+		legacy["validation_method"] = "hmac_sha256"
+		legacy["signature_algorithm"] = h.signingAlgorithms()[0]
+		legacy["signature_algorithms"] = h.signingAlgorithms()
+		legacy["requires_secret"] = true
 	case "okta", "google", "azure":
-		metadata["validation_method"] = "oidc_jwks"
-		metadata["signature_algorithm"] = "RS256"
-		metadata["requires_secret"] = false
-		if h.config.Issuer != "" {
-			metadata["issuer"] = h.config.Issuer
-			metadata["jwks_uri"] = h.config.Issuer + "/.well-known/jwks.json"
-		}
+		legacy["validation_method"] = "oidc_jwks"
+		legacy["signature_algorithm"] = h.signingAlgorithms()[0]
+		legacy["signature_algorithms"] = h.signingAlgorithms()
+		legacy["requires_secret"] = false
 		if h.config.Audience != "" {
-			metadata["audience"] = h.config.Audience
+			legacy["audience"] = h.config.Audience
 		}
 	}
+	for key, value := range metadata.Extra {
+		legacy[key] = value
+	}
+	metadata.Extra = legacy
 
 	// Encode and send response
 	w.WriteHeader(http.StatusOK)
@@ -98,8 +93,18 @@ func (h *OAuth2Handler) HandleAuthorizationServerMetadata(w http.ResponseWriter,
 		return
 	}
 
+	// Native mode can send clients to the issuer's own document
+	if h.redirectsMetadata() {
+		http.Redirect(w, r, h.discovery.documentURL(), http.StatusFound)
+		return
+	}
+
 	// Return OAuth 2.0 Authorization Server Metadata (RFC 8414)
-	metadata := h.GetAuthorizationServerMetadata()
+	metadata, err := h.authorizationServerDocument()
+	if err != nil {
+		writeOAuthError(w, http.StatusBadGateway, "temporarily_unavailable", "Authorization server metadata is unavailable")
+		return
+	}
 
 	// Encode and send response
 	w.WriteHeader(http.StatusOK)
@@ -120,25 +125,7 @@ func (h *OAuth2Handler) HandleProtectedResourceMetadata(w http.ResponseWriter, r
 	}
 
 	// Return OAuth 2.0 Protected Resource Metadata (RFC 9728)
-	// Point to authorization server based on mode
-	var authServer string
-	if h.config.Mode == "proxy" {
-		// Proxy mode: MCP server acts as authorization server
-		authServer = h.config.MCPURL
-	} else {
-		// Native mode: Point directly to OAuth provider
-		authServer = h.config.Issuer
-	}
-
-	metadata := map[string]interface{}{
-		"resource":                              h.config.MCPURL,
-		"authorization_servers":                 []string{authServer},
-		"bearer_methods_supported":              []string{"header"},
-		"resource_signing_alg_values_supported": []string{"RS256"},
-		"resource_documentation":                fmt.Sprintf("%s/docs", h.config.MCPURL),
-		"resource_policy_uri":                   fmt.Sprintf("%s/policy", h.config.MCPURL),
-		"resource_tos_uri":                      fmt.Sprintf("%s/tos", h.config.MCPURL),
-	}
+	metadata := h.GetProtectedResourceMetadata()
 
 	// Encode and send response
 	w.WriteHeader(http.StatusOK)
@@ -162,7 +149,7 @@ func (h *OAuth2Handler) HandleRegister(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if r.Method != "POST" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeOAuthError(w, http.StatusMethodNotAllowed, "invalid_request", "Method not allowed")
 		return
 	}
 
@@ -170,7 +157,7 @@ func (h *OAuth2Handler) HandleRegister(w http.ResponseWriter, r *http.Request) {
 	var regRequest map[string]interface{}
 	if err := json.NewDecoder(r.Body).Decode(&regRequest); err != nil {
 		h.logger.Error("OAuth2: Failed to parse registration request: %v", err)
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeOAuthError(w, http.StatusBadRequest, "invalid_client_metadata", "Invalid request body")
 		return
 	}
 
@@ -190,10 +177,21 @@ func (h *OAuth2Handler) HandleRegister(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Allow clients to register their own redirect URIs (needed for mcp-remote)
-	if redirectUris, ok := regRequest["redirect_uris"]; ok {
+	if rawURIs, ok := regRequest["redirect_uris"]; ok {
+		redirectUris, err := h.validateRegisteredRedirectURIs(rawURIs)
+		if err != nil {
+			h.logger.Warn("SECURITY: Rejected client registration: %v", err)
+			writeOAuthError(w, http.StatusBadRequest, "invalid_redirect_uri", err.Error())
+			return
+		}
 		response["redirect_uris"] = redirectUris
 		h.logger.Info("OAuth2: Registration allowing client redirect URIs: %v", redirectUris)
-	} else if h.config.RedirectURIs != "" && !strings.Contains(h.config.RedirectURIs, ",") {
+
+		// Show the registered name on the consent page
+		if name, ok := regRequest["client_name"].(string); ok && h.consent != nil {
+			h.consent.registerClient(h.config.ClientID, redirectUris, name)
+		}
+	} else if h.hasFixedRedirect() {
 		// Fallback to fixed redirect URI if no client URIs provided (single URI only)
 		trimmedURI := strings.TrimSpace(h.config.RedirectURIs)
 		response["redirect_uris"] = []string{trimmedURI}
@@ -208,10 +206,33 @@ func (h *OAuth2Handler) HandleRegister(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// validateRegisteredRedirectURIs checks the redirect_uris of a registration
+// request (RFC 7591) with the same rules as /oauth/authorize
+func (h *OAuth2Handler) validateRegisteredRedirectURIs(raw interface{}) ([]string, error) {
+	values, ok := raw.([]interface{})
+	if !ok || len(values) == 0 {
+		return nil, fmt.Errorf("redirect_uris must be a non-empty array of strings")
+	}
+
+	redirectURIs := make([]string, 0, len(values))
+	for _, value := range values {
+		uri, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("redirect_uris must be a non-empty array of strings")
+		}
+		// Without configured redirect URIs there is nothing to check against (native mode)
+		if h.config.RedirectURIs != "" && !h.allowsRedirectURI(uri) {
+			return nil, fmt.Errorf("redirect_uri %q is not allowed", uri)
+		}
+		redirectURIs = append(redirectURIs, uri)
+	}
+	return redirectURIs, nil
+}
+
 // HandleCallbackRedirect handles the /callback redirect for Claude Code compatibility
 func (h *OAuth2Handler) HandleCallbackRedirect(w http.ResponseWriter, r *http.Request) {
 	// Preserve all query parameters when redirecting
-	redirectURL := "/oauth/callback"
+	redirectURL := h.config.BasePath + h.paths().Callback
 	if r.URL.RawQuery != "" {
 		redirectURL += "?" + r.URL.RawQuery
 	}
@@ -230,36 +251,18 @@ func (h *OAuth2Handler) HandleOIDCDiscovery(w http.ResponseWriter, r *http.Reque
 
 	h.logger.Info("OAuth2: OIDC discovery request from %s", r.RemoteAddr)
 
-	// Return OIDC Discovery metadata with existing /oauth/ endpoints
-	metadata := map[string]interface{}{
-		"issuer":                                h.config.MCPURL,
-		"authorization_endpoint":                fmt.Sprintf("%s/oauth/authorize", h.config.MCPURL),
-		"token_endpoint":                        fmt.Sprintf("%s/oauth/token", h.config.MCPURL),
-		"registration_endpoint":                 fmt.Sprintf("%s/oauth/register", h.config.MCPURL),
-		"response_types_supported":              []string{"code"},
-		"response_modes_supported":              []string{"query"},
-		"grant_types_supported":                 []string{"authorization_code"},
-		"token_endpoint_auth_methods_supported": []string{"none"},
-		"code_challenge_methods_supported":      []string{"plain", "S256"},
-		"subject_types_supported":               []string{"public"},
-		"scopes_supported":                      []string{"openid", "profile", "email"},
-	}
-
-	// Add provider-specific fields
-	if h.config.Audience != "" {
-		metadata["audience"] = h.config.Audience
+	// Native mode can send clients to the issuer's own document
+	if h.redirectsMetadata() {
+		http.Redirect(w, r, h.discovery.documentURL(), http.StatusFound)
+		return
 	}
 
-	// Add provider-specific signing algorithm information
-	switch h.config.Provider {
-	case "hmac":
-		metadata["id_token_signing_alg_values_supported"] = []string{"HS256"}
-	case "okta", "google", "azure":
-		metadata["id_token_signing_alg_values_supported"] = []string{"RS256"}
-		metadata["jwks_uri"] = fmt.Sprintf("%s/.well-known/jwks.json", h.config.MCPURL)
+	metadata, err := h.openIDProviderDocument()
+	if err != nil {
+		writeOAuthError(w, http.StatusBadGateway, "temporarily_unavailable", "OpenID Provider configuration is unavailable")
+		return
 	}
-
-	h.logger.Info("OAuth2: Returning OIDC discovery metadata for issuer: %s", h.config.MCPURL)
+	h.logger.Info("OAuth2: Returning OIDC discovery metadata for issuer: %s", metadata.Issuer)
 
 	w.WriteHeader(http.StatusOK)
 	if err := json.NewEncoder(w).Encode(metadata); err != nil {
@@ -268,55 +271,179 @@ func (h *OAuth2Handler) HandleOIDCDiscovery(w http.ResponseWriter, r *http.Reque
 	}
 }
 
-// GetAuthorizationServerMetadata returns the OAuth 2.0 Authorization Server Metadata
-// with conditional responses based on OAuth mode
-func (h *OAuth2Handler) GetAuthorizationServerMetadata() map[string]interface{} {
-	var metadata map[string]interface{}
+// GetAuthorizationServerMetadata returns the OAuth 2.0 Authorization Server
+// Metadata (RFC 8414) for the OAuth mode, with Config.MetadataOverrides
+// applied. In native mode this is the issuer's discovery document; if it
+// cannot be fetched, the document only has the issuer.
+func (h *OAuth2Handler) GetAuthorizationServerMetadata() *AuthorizationServerMetadata {
+	metadata, err := h.authorizationServerDocument()
+	if err != nil {
+		h.logger.Warn("OAuth2: Authorization server metadata is incomplete: %v", err)
+	}
+	return metadata
+}
+
+// GetOpenIDProviderMetadata returns the OpenID Provider configuration: the
+// authorization server metadata with the OpenID Connect Discovery parameters
+func (h *OAuth2Handler) GetOpenIDProviderMetadata() *OpenIDProviderMetadata {
+	metadata, err := h.openIDProviderDocument()
+	if err != nil {
+		h.logger.Warn("OAuth2: OpenID Provider configuration is incomplete: %v", err)
+	}
+	return metadata
+}
+
+// authorizationServerDocument returns the authorization server metadata with
+// the override applied, and the error if the upstream document is unavailable
+func (h *OAuth2Handler) authorizationServerDocument() (*AuthorizationServerMetadata, error) {
+	metadata, err := h.authorizationServerMetadata()
+	if override := h.config.MetadataOverrides.AuthorizationServer; override != nil {
+		override(metadata)
+	}
+	return metadata, err
+}
 
-	if h.config.Mode == "native" {
-		// Native mode: Point to OAuth provider directly
-		metadata = map[string]interface{}{
-			"issuer":                                h.config.Issuer, // OAuth provider issuer
-			"response_types_supported":              []string{"code"},
-			"response_modes_supported":              []string{"query"},
-			"grant_types_supported":                 []string{"authorization_code"},
-			"token_endpoint_auth_methods_supported": []string{"none"},
-			"code_challenge_methods_supported":      []string{"plain", "S256"},
-			"scopes_supported":                      []string{"openid", "profile", "email"},
+// openIDProviderDocument returns the OpenID Provider configuration with the
+// overrides applied, and the error if the upstream document is unavailable
+func (h *OAuth2Handler) openIDProviderDocument() (*OpenIDProviderMetadata, error) {
+	asMetadata, err := h.authorizationServerDocument()
+	metadata := OpenIDProviderMetadata(*asMetadata)
+
+	// Parameters of a mirrored upstream document are kept
+	if len(metadata.SubjectTypesSupported) == 0 {
+		metadata.SubjectTypesSupported = []string{"public"}
+	}
+	if len(metadata.IDTokenSigningAlgValuesSupported) == 0 {
+		// Advertise the algorithms accepted by the token validator
+		metadata.IDTokenSigningAlgValuesSupported = cloneStrings(h.signingAlgorithms())
+	}
+
+	// RP-Initiated Logout is handled by the proxy
+	if h.config.Mode != "native" {
+		metadata.EndSessionEndpoint = h.endpointURL(h.paths().Logout)
+	}
+
+	// Non-standard audience parameter, kept for existing MCP clients
+	if h.config.Audience != "" {
+		extra := map[string]interface{}{"audience": h.config.Audience}
+		for key, value := range metadata.Extra {
+			extra[key] = value
 		}
+		metadata.Extra = extra
+	}
+
+	if override := h.config.MetadataOverrides.OpenIDConfiguration; override != nil {
+		override(&metadata)
+	}
+	return &metadata, err
+}
 
-		// Add provider-specific endpoints
-		switch h.config.Provider {
-		case "okta":
-			metadata["authorization_endpoint"] = fmt.Sprintf("%s/oauth2/v1/authorize", h.config.Issuer)
-			metadata["token_endpoint"] = fmt.Sprintf("%s/oauth2/v1/token", h.config.Issuer)
-			metadata["registration_endpoint"] = fmt.Sprintf("%s/oauth2/v1/clients", h.config.Issuer)
-			metadata["jwks_uri"] = fmt.Sprintf("%s/oauth2/v1/keys", h.config.Issuer)
-		case "google":
-			metadata["authorization_endpoint"] = "https://accounts.google.com/o/oauth2/v2/auth"
-			metadata["token_endpoint"] = "https://oauth2.googleapis.com/token"
-			metadata["jwks_uri"] = "https://www.googleapis.com/oauth2/v3/certs"
-		case "azure":
-			metadata["authorization_endpoint"] = fmt.Sprintf("%s/oauth2/v2.0/authorize", h.config.Issuer)
-			metadata["token_endpoint"] = fmt.Sprintf("%s/oauth2/v2.0/token", h.config.Issuer)
-			metadata["jwks_uri"] = fmt.Sprintf("%s/discovery/v2.0/keys", h.config.Issuer)
+// GetProtectedResourceMetadata returns the OAuth 2.0 Protected Resource
+// Metadata (RFC 9728) of the MCP server, with Config.MetadataOverrides applied
+func (h *OAuth2Handler) GetProtectedResourceMetadata() *ProtectedResourceMetadata {
+	metadata := &ProtectedResourceMetadata{
+		Resource:                          h.config.MCPURL,
+		AuthorizationServers:              []string{h.authorizationServerIssuer()},
+		BearerMethodsSupported:            []string{"header"},
+		ResourceSigningAlgValuesSupported: cloneStrings(h.signingAlgorithms()),
+		ScopesSupported:                   cloneStrings(h.scopesSupported()),
+		ResourceDocumentation:             fmt.Sprintf("%s/docs", h.config.MCPURL),
+		ResourcePolicyURI:                 fmt.Sprintf("%s/policy", h.config.MCPURL),
+		ResourceTosURI:                    fmt.Sprintf("%s/tos", h.config.MCPURL),
+	}
+
+	// Advertise DPoP support (RFC 9728 Section 2)
+	if h.config.DPoPMode != "" {
+		metadata.DPoPSigningAlgValuesSupported = cloneStrings(h.config.DPoPSigningAlgorithms)
+		metadata.DPoPBoundAccessTokensRequired = h.config.DPoPMode == DPoPModeRequired
+	}
+
+	// Advertise mutual-TLS certificate-bound tokens (RFC 8705 Section 3.3)
+	metadata.TLSClientCertificateBoundAccessTokens = h.config.CertificateBoundTokens
+
+	if override := h.config.MetadataOverrides.ProtectedResource; override != nil {
+		override(metadata)
+	}
+	return metadata
+}
+
+// authorizationServerMetadata builds the authorization server metadata the
+// other documents are derived from
+func (h *OAuth2Handler) authorizationServerMetadata() (*AuthorizationServerMetadata, error) {
+	if h.discovery != nil {
+		// Native mode: mirror the issuer's discovery document
+		metadata, err := h.discovery.get()
+		if err != nil {
+			return &AuthorizationServerMetadata{Issuer: h.config.Issuer, ResponseTypesSupported: []string{"code"}}, err
 		}
-	} else {
-		// Proxy mode: Point to MCP server endpoints
-		metadata = map[string]interface{}{
-			"issuer":                                h.config.MCPURL,
-			"authorization_endpoint":                fmt.Sprintf("%s/oauth/authorize", h.config.MCPURL),
-			"token_endpoint":                        fmt.Sprintf("%s/oauth/token", h.config.MCPURL),
-			"registration_endpoint":                 fmt.Sprintf("%s/oauth/register", h.config.MCPURL),
-			"jwks_uri":                              fmt.Sprintf("%s/.well-known/jwks.json", h.config.MCPURL),
-			"response_types_supported":              []string{"code"},
-			"response_modes_supported":              []string{"query"},
-			"grant_types_supported":                 []string{"authorization_code"},
-			"token_endpoint_auth_methods_supported": []string{"none"},
-			"code_challenge_methods_supported":      []string{"plain", "S256"},
-			"scopes_supported":                      []string{"openid", "profile", "email"},
+		// MCP clients refuse authorization servers that do not advertise PKCE
+		if len(metadata.CodeChallengeMethodsSupported) == 0 {
+			metadata.CodeChallengeMethodsSupported = cloneStrings(h.pkceMethods())
 		}
+		return metadata, nil
 	}
 
-	return metadata
+	metadata := &AuthorizationServerMetadata{
+		ResponseTypesSupported:            []string{"code"},
+		ResponseModesSupported:            []string{"query"},
+		GrantTypesSupported:               []string{"authorization_code"},
+		TokenEndpointAuthMethodsSupported: []string{"none"},
+		CodeChallengeMethodsSupported:     cloneStrings(h.pkceMethods()),
+		ScopesSupported:                   cloneStrings(h.scopesSupported()),
+	}
+
+	if h.config.Mode == "native" {
+		// Native mode without an upstream authorization server (HMAC)
+		metadata.Issuer = h.config.Issuer
+		return metadata, nil
+	}
+
+	// Proxy mode: Point to MCP server endpoints
+	paths := h.paths()
+	metadata.Issuer = h.baseURL()
+	metadata.AuthorizationEndpoint = h.endpointURL(paths.Authorize)
+	metadata.TokenEndpoint = h.endpointURL(paths.Token)
+	metadata.RegistrationEndpoint = h.endpointURL(paths.Register)
+
+	// The JWKS endpoint proxies the keys of the built-in providers
+	switch h.config.Provider {
+	case "hmac", "okta", "google", "azure":
+		metadata.JWKSURI = h.endpointURL(paths.JWKS)
+	}
+
+	// Pushed authorization requests (RFC 9126)
+	metadata.PushedAuthorizationRequestEndpoint = h.endpointURL(paths.PushedAuthorizationRequest)
+	metadata.RequirePushedAuthorizationRequests = h.config.RequirePushedAuthorizationRequests
+
+	// Device authorization grant (RFC 8628), if the upstream provider supports it
+	if h.supportsDeviceAuthorization() {
+		metadata.DeviceAuthorizationEndpoint = h.endpointURL(paths.DeviceAuthorization)
+		metadata.GrantTypesSupported = append(metadata.GrantTypesSupported, grantTypeDeviceCode)
+	}
+	return metadata, nil
+}
+
+// authorizationServerIssuer returns the issuer advertised in the
+// authorization server metadata. In native mode this is the configured issuer,
+// which a mirrored document must match, so no fetch is needed.
+func (h *OAuth2Handler) authorizationServerIssuer() string {
+	if h.config.Mode == "native" {
+		return h.config.Issuer
+	}
+	metadata, _ := h.authorizationServerDocument()
+	return metadata.Issuer
+}
+
+// redirectsMetadata reports whether metadata requests are redirected to the
+// issuer's discovery document (native mode with UpstreamMetadata "redirect")
+func (h *OAuth2Handler) redirectsMetadata() bool {
+	return h.discovery != nil && h.config.UpstreamMetadata == UpstreamMetadataRedirect
+}
+
+// cloneStrings returns a copy of values, so overrides cannot change the configuration
+func cloneStrings(values []string) []string {
+	if values == nil {
+		return nil
+	}
+	return append([]string{}, values...)
 }
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"testing"
 )
 
@@ -20,7 +21,7 @@ func TestGetAuthorizationServerMetadata(t *testing.T) {
 			name:        "Native mode with Okta",
 			mode:        "native",
 			provider:    "okta",
-			issuer:      "https://dev.okta.com",
+			issuer:      "https://dev.okta.com/oauth2/default",
 			mcpURL:      "https://mcp.example.com",
 			checkFields: []string{"issuer", "authorization_endpoint", "token_endpoint", "jwks_uri"},
 		},
@@ -51,19 +52,28 @@ func TestGetAuthorizationServerMetadata(t *testing.T) {
 				MCPURL:   tt.mcpURL,
 			}
 			handler := &OAuth2Handler{config: config, logger: &defaultLogger{}}
+			if tt.mode == "native" {
+				withDiscoveryFixture(t, handler)
+			}
 
 			metadata := handler.GetAuthorizationServerMetadata()
 
 			// Check that required fields are present
+			fields := map[string]string{
+				"issuer":                 metadata.Issuer,
+				"authorization_endpoint": metadata.AuthorizationEndpoint,
+				"token_endpoint":         metadata.TokenEndpoint,
+				"jwks_uri":               metadata.JWKSURI,
+			}
 			for _, field := range tt.checkFields {
-				if _, exists := metadata[field]; !exists {
+				if fields[field] == "" {
 					t.Errorf("Missing required field: %s", field)
 				}
 			}
 
 			// Verify mode-specific behavior
-			issuer := metadata["issuer"].(string)
-			authEndpoint := metadata["authorization_endpoint"].(string)
+			issuer := metadata.Issuer
+			authEndpoint := metadata.AuthorizationEndpoint
 
 			if tt.mode == "native" {
 				// Native mode should point to OAuth provider
@@ -71,7 +81,8 @@ func TestGetAuthorizationServerMetadata(t *testing.T) {
 					t.Errorf("Native mode issuer = %s, expected %s", issuer, tt.issuer)
 				}
 				if tt.provider == "okta" {
-					expectedAuth := tt.issuer + "/oauth2/v1/authorize"
+					// The custom authorization server's endpoints, from its discovery document
+					expectedAuth := tt.issuer + "/v1/authorize"
 					if authEndpoint != expectedAuth {
 						t.Errorf("Native mode auth endpoint = %s, expected %s", authEndpoint, expectedAuth)
 					}
@@ -94,10 +105,10 @@ func TestHandleAuthorizationServerMetadata(t *testing.T) {
 	config := &OAuth2Config{
 		Mode:     "native",
 		Provider: "okta",
-		Issuer:   "https://dev.okta.com",
+		Issuer:   "https://dev.okta.com/oauth2/default",
 		MCPURL:   "https://mcp.example.com",
 	}
-	handler := &OAuth2Handler{config: config, logger: &defaultLogger{}}
+	handler := withDiscoveryFixture(t, &OAuth2Handler{config: config, logger: &defaultLogger{}})
 
 	tests := []struct {
 		name           string
@@ -145,6 +156,7 @@ func TestHandleAuthorizationServerMetadata(t *testing.T) {
 
 func TestHandleProtectedResourceMetadata(t *testing.T) {
 	config := &OAuth2Config{
+		Mode:   "native",
 		Issuer: "https://dev.okta.com",
 		MCPURL: "https://mcp.example.com",
 	}
@@ -229,3 +241,107 @@ func TestHandleOIDCDiscovery(t *testing.T) {
 		t.Errorf("OIDC audience = %s, expected %s", audience, config.Audience)
 	}
 }
+
+func TestMetadataSigningAlgorithms(t *testing.T) {
+	tests := []struct {
+		name       string
+		provider   string
+		algorithms []string
+		expected   []interface{}
+	}{
+		{"HMAC default", "hmac", nil, []interface{}{"HS256"}},
+		{"HMAC explicit", "hmac", []string{"HS384", "HS512"}, []interface{}{"HS384", "HS512"}},
+		{"OIDC default", "okta", nil, []interface{}{"RS256", "ES256"}},
+		{"OIDC explicit", "azure", []string{"PS256", "EdDSA"}, []interface{}{"PS256", "EdDSA"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := &OAuth2Handler{
+				config: &OAuth2Config{
+					Mode:              "proxy",
+					Provider:          tt.provider,
+					MCPURL:            "https://mcp.example.com",
+					AllowedAlgorithms: tt.algorithms,
+				},
+				logger: &defaultLogger{},
+			}
+
+			checks := []struct {
+				handler http.HandlerFunc
+				field   string
+			}{
+				{handler.HandleOIDCDiscovery, "id_token_signing_alg_values_supported"},
+				{handler.HandleProtectedResourceMetadata, "resource_signing_alg_values_supported"},
+			}
+
+			for _, check := range checks {
+				recorder := httptest.NewRecorder()
+				check.handler(recorder, httptest.NewRequest("GET", "/", nil))
+
+				var metadata map[string]interface{}
+				if err := json.Unmarshal(recorder.Body.Bytes(), &metadata); err != nil {
+					t.Fatalf("Failed to parse JSON response: %v", err)
+				}
+				if !reflect.DeepEqual(metadata[check.field], tt.expected) {
+					t.Errorf("%s = %v, expected %v", check.field, metadata[check.field], tt.expected)
+				}
+			}
+		})
+	}
+}
+
+func TestMetadataScopesSupported(t *testing.T) {
+	tests := []struct {
+		name      string
+		scopes    []string
+		supported []string
+		expected  []interface{}
+	}{
+		{"Default", nil, nil, []interface{}{"openid", "profile", "email"}},
+		{"Scopes only", []string{"openid", "mcp:tools.read"}, nil, []interface{}{"openid", "mcp:tools.read"}},
+		{"Explicit supported", []string{"mcp:tools.read"}, []string{"mcp:tools.read", "mcp:admin"}, []interface{}{"mcp:tools.read", "mcp:admin"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := &OAuth2Handler{
+				config: &OAuth2Config{
+					Mode:            "proxy",
+					Provider:        "okta",
+					MCPURL:          "https://mcp.example.com",
+					Scopes:          tt.scopes,
+					ScopesSupported: tt.supported,
+				},
+				logger: &defaultLogger{},
+			}
+
+			if got := handler.GetAuthorizationServerMetadata().ScopesSupported; !reflect.DeepEqual(toInterfaces(got), tt.expected) {
+				t.Errorf("authorization server scopes_supported = %v, expected %v", got, tt.expected)
+			}
+
+			for _, handle := range []http.HandlerFunc{handler.HandleOIDCDiscovery, handler.HandleProtectedResourceMetadata} {
+				recorder := httptest.NewRecorder()
+				handle(recorder, httptest.NewRequest("GET", "/", nil))
+
+				var metadata map[string]interface{}
+				if err := json.Unmarshal(recorder.Body.Bytes(), &metadata); err != nil {
+					t.Fatalf("Failed to parse JSON response: %v", err)
+				}
+				if !reflect.DeepEqual(metadata["scopes_supported"], tt.expected) {
+					t.Errorf("scopes_supported = %v, expected %v", metadata["scopes_supported"], tt.expected)
+				}
+			}
+		})
+	}
+}
+
+// toInterfaces converts a []string to the form produced by JSON decoding
+func toInterfaces(value interface{}) []interface{} {
+	values, _ := value.([]string)
+	result := make([]interface{}, len(values))
+	for i, v := range values {
+		result[i] = v
+	}
+	return result
+}
@@ -0,0 +1,217 @@
+package oauth
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// AuthorizationServerMetadata is an OAuth 2.0 Authorization Server Metadata
+// document (RFC 8414), served at /.well-known/oauth-authorization-server.
+// It includes the parameters registered by later RFCs and, as allowed by
+// RFC 8414 Section 2, those of OpenID Connect Discovery 1.0, which are only
+// set in the OpenID Provider configuration (see OpenIDProviderMetadata).
+type AuthorizationServerMetadata struct {
+	// RFC 8414 Section 2
+	Issuer                                     string   `json:"issuer"`
+	AuthorizationEndpoint                      string   `json:"authorization_endpoint,omitempty"`
+	TokenEndpoint                              string   `json:"token_endpoint,omitempty"`
+	JWKSURI                                    string   `json:"jwks_uri,omitempty"`
+	RegistrationEndpoint                       string   `json:"registration_endpoint,omitempty"`
+	ScopesSupported                            []string `json:"scopes_supported,omitempty"`
+	ResponseTypesSupported                     []string `json:"response_types_supported"`
+	ResponseModesSupported                     []string `json:"response_modes_supported,omitempty"`
+	GrantTypesSupported                        []string `json:"grant_types_supported,omitempty"`
+	TokenEndpointAuthMethodsSupported          []string `json:"token_endpoint_auth_methods_supported,omitempty"`
+	TokenEndpointAuthSigningAlgValuesSupported []string `json:"token_endpoint_auth_signing_alg_values_supported,omitempty"`
+	ServiceDocumentation                       string   `json:"service_documentation,omitempty"`
+	UILocalesSupported                         []string `json:"ui_locales_supported,omitempty"`
+	OPPolicyURI                                string   `json:"op_policy_uri,omitempty"`
+	OPTosURI                                   string   `json:"op_tos_uri,omitempty"`
+	RevocationEndpoint                         string   `json:"revocation_endpoint,omitempty"`
+	IntrospectionEndpoint                      string   `json:"introspection_endpoint,omitempty"`
+	CodeChallengeMethodsSupported              []string `json:"code_challenge_methods_supported,omitempty"`
+
+	// Pushed authorization requests (RFC 9126)
+	PushedAuthorizationRequestEndpoint string `json:"pushed_authorization_request_endpoint,omitempty"`
+	RequirePushedAuthorizationRequests bool   `json:"require_pushed_authorization_requests,omitempty"`
+	// Device authorization grant (RFC 8628)
+	DeviceAuthorizationEndpoint string `json:"device_authorization_endpoint,omitempty"`
+	// DPoP (RFC 9449)
+	DPoPSigningAlgValuesSupported []string `json:"dpop_signing_alg_values_supported,omitempty"`
+	// Mutual-TLS certificate-bound access tokens (RFC 8705)
+	TLSClientCertificateBoundAccessTokens bool `json:"tls_client_certificate_bound_access_tokens,omitempty"`
+	// Authorization server issuer identification (RFC 9207)
+	AuthorizationResponseISSParameterSupported bool `json:"authorization_response_iss_parameter_supported,omitempty"`
+
+	// OpenID Connect Discovery 1.0 Section 3
+	UserinfoEndpoint                 string   `json:"userinfo_endpoint,omitempty"`
+	SubjectTypesSupported            []string `json:"subject_types_supported,omitempty"`
+	IDTokenSigningAlgValuesSupported []string `json:"id_token_signing_alg_values_supported,omitempty"`
+	ClaimsSupported                  []string `json:"claims_supported,omitempty"`
+	// OpenID Connect RP-Initiated Logout 1.0
+	EndSessionEndpoint string `json:"end_session_endpoint,omitempty"`
+
+	// Extra holds extension parameters. Keys of parameters already in the
+	// document are ignored.
+	Extra map[string]interface{} `json:"-"`
+}
+
+// MarshalJSON encodes the document with its extension parameters
+func (m AuthorizationServerMetadata) MarshalJSON() ([]byte, error) {
+	type standard AuthorizationServerMetadata
+	return marshalWithExtra(standard(m), m.Extra)
+}
+
+// UnmarshalJSON decodes the document, keeping unknown parameters in Extra
+func (m *AuthorizationServerMetadata) UnmarshalJSON(data []byte) error {
+	type standard AuthorizationServerMetadata
+	var document standard
+	if err := json.Unmarshal(data, &document); err != nil {
+		return err
+	}
+	extra, err := unmarshalExtra(data, reflect.TypeOf(document))
+	if err != nil {
+		return err
+	}
+	*m = AuthorizationServerMetadata(document)
+	m.Extra = extra
+	return nil
+}
+
+// OpenIDProviderMetadata is an OpenID Provider configuration (OpenID Connect
+// Discovery 1.0), served at /.well-known/openid-configuration. It is the
+// authorization server metadata with the OpenID Connect parameters set.
+type OpenIDProviderMetadata AuthorizationServerMetadata
+
+// MarshalJSON encodes the document with its extension parameters
+func (m OpenIDProviderMetadata) MarshalJSON() ([]byte, error) {
+	return AuthorizationServerMetadata(m).MarshalJSON()
+}
+
+// UnmarshalJSON decodes the document, keeping unknown parameters in Extra
+func (m *OpenIDProviderMetadata) UnmarshalJSON(data []byte) error {
+	return (*AuthorizationServerMetadata)(m).UnmarshalJSON(data)
+}
+
+// ProtectedResourceMetadata is an OAuth 2.0 Protected Resource Metadata
+// document (RFC 9728), served at /.well-known/oauth-protected-resource.
+type ProtectedResourceMetadata struct {
+	// RFC 9728 Section 2
+	Resource                              string   `json:"resource"`
+	AuthorizationServers                  []string `json:"authorization_servers,omitempty"`
+	JWKSURI                               string   `json:"jwks_uri,omitempty"`
+	ScopesSupported                       []string `json:"scopes_supported,omitempty"`
+	BearerMethodsSupported                []string `json:"bearer_methods_supported,omitempty"`
+	ResourceSigningAlgValuesSupported     []string `json:"resource_signing_alg_values_supported,omitempty"`
+	ResourceName                          string   `json:"resource_name,omitempty"`
+	ResourceDocumentation                 string   `json:"resource_documentation,omitempty"`
+	ResourcePolicyURI                     string   `json:"resource_policy_uri,omitempty"`
+	ResourceTosURI                        string   `json:"resource_tos_uri,omitempty"`
+	TLSClientCertificateBoundAccessTokens bool     `json:"tls_client_certificate_bound_access_tokens,omitempty"`
+	AuthorizationDetailsTypesSupported    []string `json:"authorization_details_types_supported,omitempty"`
+	DPoPSigningAlgValuesSupported         []string `json:"dpop_signing_alg_values_supported,omitempty"`
+	DPoPBoundAccessTokensRequired         bool     `json:"dpop_bound_access_tokens_required,omitempty"`
+
+	// Extra holds extension parameters. Keys of parameters already in the
+	// document are ignored.
+	Extra map[string]interface{} `json:"-"`
+}
+
+// MarshalJSON encodes the document with its extension parameters
+func (m ProtectedResourceMetadata) MarshalJSON() ([]byte, error) {
+	type standard ProtectedResourceMetadata
+	return marshalWithExtra(standard(m), m.Extra)
+}
+
+// UnmarshalJSON decodes the document, keeping unknown parameters in Extra
+func (m *ProtectedResourceMetadata) UnmarshalJSON(data []byte) error {
+	type standard ProtectedResourceMetadata
+	var document standard
+	if err := json.Unmarshal(data, &document); err != nil {
+		return err
+	}
+	extra, err := unmarshalExtra(data, reflect.TypeOf(document))
+	if err != nil {
+		return err
+	}
+	*m = ProtectedResourceMetadata(document)
+	m.Extra = extra
+	return nil
+}
+
+// MetadataOverrides customizes the metadata documents (see
+// Config.MetadataOverrides). Each function is called with the generated
+// document on every request and may change any parameter or add extension
+// parameters to Extra. Changes made by AuthorizationServer also apply to the
+// OpenID Provider configuration, which is derived from it.
+type MetadataOverrides struct {
+	AuthorizationServer func(*AuthorizationServerMetadata)
+	OpenIDConfiguration func(*OpenIDProviderMetadata)
+	ProtectedResource   func(*ProtectedResourceMetadata)
+}
+
+// marshalWithExtra encodes document followed by the extra parameters that it
+// does not already contain, in key order
+func marshalWithExtra(document interface{}, extra map[string]interface{}) ([]byte, error) {
+	data, err := json.Marshal(document)
+	if err != nil || len(extra) == 0 {
+		return data, err
+	}
+
+	var standard map[string]json.RawMessage
+	if err := json.Unmarshal(data, &standard); err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(extra))
+	for key := range extra {
+		if _, exists := standard[key]; !exists {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return data, nil
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	buf.Write(data[:len(data)-1])
+	for i, key := range keys {
+		if i > 0 || len(standard) > 0 {
+			buf.WriteByte(',')
+		}
+		name, _ := json.Marshal(key)
+		value, err := json.Marshal(extra[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// unmarshalExtra returns the parameters of data that are not fields of
+// document, undecoded so they are served unchanged
+func unmarshalExtra(data []byte, document reflect.Type) (map[string]interface{}, error) {
+	var parameters map[string]json.RawMessage
+	if err := json.Unmarshal(data, &parameters); err != nil {
+		return nil, err
+	}
+	for i := 0; i < document.NumField(); i++ {
+		name, _, _ := strings.Cut(document.Field(i).Tag.Get("json"), ",")
+		delete(parameters, name)
+	}
+	if len(parameters) == 0 {
+		return nil, nil
+	}
+	extra := make(map[string]interface{}, len(parameters))
+	for key, value := range parameters {
+		extra[key] = value
+	}
+	return extra, nil
+}
@@ -0,0 +1,156 @@
+package oauth
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden files in testdata")
+
+// TestMetadataGolden compares the metadata documents of each mode and provider with testdata/metadata
+func TestMetadataGolden(t *testing.T) {
+	issuers := map[string]string{
+		"hmac":   "https://mcp.example.com",
+		"okta":   "https://dev.okta.com/oauth2/default",
+		"google": "https://accounts.google.com",
+		"azure":  "https://sts.windows.net/tenant-id/",
+	}
+
+	for _, mode := range []string{"native", "proxy"} {
+		for _, provider := range []string{"hmac", "okta", "google", "azure"} {
+			name := mode + "_" + provider
+			t.Run(name, func(t *testing.T) {
+				handler := &OAuth2Handler{
+					config: &OAuth2Config{
+						Mode:     mode,
+						Provider: provider,
+						Issuer:   issuers[provider],
+						Audience: "api://mcp",
+						ClientID: "mcp-client",
+						MCPURL:   "https://mcp.example.com",
+						Version:  "1.0.0",
+					},
+					logger: &defaultLogger{},
+				}
+				// Native mode mirrors the issuer's discovery document
+				if mode == "native" && provider != "hmac" {
+					withDiscoveryFixture(t, handler)
+				}
+
+				documents := map[string]json.RawMessage{}
+				for path, handle := range map[string]http.HandlerFunc{
+					authorizationServerMetadataPath: handler.HandleAuthorizationServerMetadata,
+					oidcDiscoveryPath:               handler.HandleOIDCDiscovery,
+					protectedResourceMetadataPath:   handler.HandleProtectedResourceMetadata,
+				} {
+					rec := httptest.NewRecorder()
+					handle(rec, httptest.NewRequest(http.MethodGet, path, nil))
+					if rec.Code != http.StatusOK {
+						t.Fatalf("%s: status %d", path, rec.Code)
+					}
+					documents[path] = json.RawMessage(bytes.TrimSpace(rec.Body.Bytes()))
+				}
+				got, err := json.MarshalIndent(documents, "", "  ")
+				if err != nil {
+					t.Fatalf("Failed to encode documents: %v", err)
+				}
+				got = append(got, '\n')
+
+				golden := filepath.Join("testdata", "metadata", name+".json")
+				if *updateGolden {
+					if err := os.WriteFile(golden, got, 0o644); err != nil {
+						t.Fatalf("Failed to update golden file: %v", err)
+					}
+				}
+				want, err := os.ReadFile(golden)
+				if err != nil {
+					t.Fatalf("Failed to read golden file (run with -update to create it): %v", err)
+				}
+				if !bytes.Equal(got, want) {
+					t.Errorf("Metadata differs from %s (run with -update to accept):\n%s", golden, got)
+				}
+			})
+		}
+	}
+}
+
+// TestMetadataOverrides tests application overrides and extension parameters
+func TestMetadataOverrides(t *testing.T) {
+	server, err := NewServer(&Config{
+		Mode:         "proxy",
+		Provider:     "hmac",
+		Audience:     "api://test",
+		ClientID:     "client-id",
+		ServerURL:    "https://mcp.example.com",
+		RedirectURIs: "https://mcp.example.com/oauth/callback",
+		JWTSecret:    []byte("test-secret-key-must-be-32-bytes-long!"),
+		MetadataOverrides: MetadataOverrides{
+			AuthorizationServer: func(m *AuthorizationServerMetadata) {
+				m.ServiceDocumentation = "https://docs.example.com"
+				m.Extra = map[string]interface{}{"mcp_extension": true, "issuer": "https://evil.example.com"}
+			},
+			OpenIDConfiguration: func(m *OpenIDProviderMetadata) {
+				m.ClaimsSupported = []string{"sub", "email"}
+			},
+			ProtectedResource: func(m *ProtectedResourceMetadata) {
+				m.ResourceName = "Example MCP Server"
+				m.ScopesSupported[0] = "changed"
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	h := server.handler
+
+	decode := func(handle http.HandlerFunc) map[string]interface{} {
+		rec := httptest.NewRecorder()
+		handle(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		var metadata map[string]interface{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &metadata); err != nil {
+			t.Fatalf("Failed to parse JSON response: %v", err)
+		}
+		return metadata
+	}
+
+	metadata := decode(h.HandleAuthorizationServerMetadata)
+	if metadata["service_documentation"] != "https://docs.example.com" || metadata["mcp_extension"] != true {
+		t.Errorf("Expected overridden authorization server metadata, got %v", metadata)
+	}
+	if metadata["issuer"] != "https://mcp.example.com" {
+		t.Errorf("Expected extension parameters not to replace standard ones, got issuer %v", metadata["issuer"])
+	}
+	if _, ok := metadata["claims_supported"]; ok {
+		t.Error("Expected OpenID Connect parameters only in the OpenID Provider configuration")
+	}
+
+	// The OpenID Provider configuration is derived from the authorization server metadata
+	metadata = decode(h.HandleOIDCDiscovery)
+	if metadata["service_documentation"] != "https://docs.example.com" || metadata["mcp_extension"] != true || metadata["audience"] != "api://test" {
+		t.Errorf("Expected authorization server overrides in the OpenID configuration, got %v", metadata)
+	}
+	if claims, _ := metadata["claims_supported"].([]interface{}); len(claims) != 2 {
+		t.Errorf("Expected overridden claims_supported, got %v", metadata["claims_supported"])
+	}
+
+	metadata = decode(h.HandleProtectedResourceMetadata)
+	if metadata["resource_name"] != "Example MCP Server" {
+		t.Errorf("Expected overridden resource_name, got %v", metadata["resource_name"])
+	}
+	if scopes := h.scopesSupported(); scopes[0] == "changed" {
+		t.Error("Expected overrides not to change the configured scopes")
+	}
+
+	// The legacy endpoint keeps its fields alongside the standard ones
+	h.config.Enabled = true
+	metadata = decode(h.HandleMetadata)
+	if metadata["oauth_enabled"] != true || metadata["mcp_extension"] != true || metadata["token_endpoint"] != "https://mcp.example.com/oauth/token" {
+		t.Errorf("Unexpected legacy metadata: %v", metadata)
+	}
+}
@@ -2,12 +2,9 @@ package oauth
 
 import (
 	"context"
-	"crypto/sha256"
 	"fmt"
 	"log"
 	"net/http"
-	"strings"
-	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
@@ -19,8 +16,8 @@ import (
 //
 // The middleware:
 //  1. Extracts OAuth token from context (set by CreateHTTPContextFunc)
-//  2. Checks token cache (5-minute TTL)
-//  3. Validates token using configured provider if not cached
+//  2. Validates token via ValidateTokenCached (5-minute cache, DPoP proofs)
+//  3. Enforces the tool's step-up requirement (Config.StepUp)
 //  4. Adds User to context via userContextKey
 //  5. Passes request to tool handler with authenticated context
 //
@@ -38,41 +35,38 @@ func (s *Server) Middleware() func(server.ToolHandlerFunc) server.ToolHandlerFun
 				return nil, fmt.Errorf("authentication required: missing OAuth token")
 			}
 
-			// Create token hash for caching
-			tokenHash := fmt.Sprintf("%x", sha256.Sum256([]byte(tokenString)))
-
-			// Check cache first
-			if cached, exists := s.cache.getCachedToken(tokenHash); exists {
-				s.logger.Info("Using cached authentication for tool: %s (user: %s)", req.Params.Name, cached.User.Username)
-				ctx = context.WithValue(ctx, userContextKey, cached.User)
-				return next(ctx, req)
-			}
-
-			// Log token hash for debugging (prevents sensitive data exposure)
-			tokenHashFull := fmt.Sprintf("%x", sha256.Sum256([]byte(tokenString)))
-			tokenHashPreview := tokenHashFull[:16] + "..."
-			s.logger.Info("Validating token for tool %s (hash: %s)", req.Params.Name, tokenHashPreview)
-
-			// Validate token using configured provider (with request context for timeout/cancellation)
-			user, err := s.validator.ValidateToken(ctx, tokenString)
+			// Validate token (cached, including DPoP proof verification when enabled)
+			user, err := s.ValidateTokenCached(ctx, tokenString)
 			if err != nil {
 				s.logger.Error("Token validation failed for tool %s: %v", req.Params.Name, err)
-				return nil, fmt.Errorf("authentication failed: %w", err)
+				return nil, err
 			}
 
-			// Cache the validation result (expire in 5 minutes)
-			expiresAt := time.Now().Add(5 * time.Minute)
-			s.cache.setCachedToken(tokenHash, user, expiresAt)
+			// Enforce step-up requirements for this tool (RFC 9470)
+			if err := s.CheckStepUp(user, req.Params.Name); err != nil {
+				s.logger.Info("Step-up authentication required for tool %s: %v", req.Params.Name, err)
+				return stepUpToolResult(err), nil
+			}
 
 			// Add user to context for downstream handlers
 			ctx = context.WithValue(ctx, userContextKey, user)
-			s.logger.Info("Authenticated user %s for tool: %s (cached for 5 minutes)", user.Username, req.Params.Name)
+			ctx = WithServer(ctx, s)
+			s.logger.Info("Authenticated user %s for tool: %s", user.Username, req.Params.Name)
 
 			return next(ctx, req)
 		}
 	}
 }
 
+// stepUpToolResult converts a step-up failure into an MCP tool error whose
+// structured content carries the RFC 9470 challenge parameters
+func stepUpToolResult(err error) *mcp.CallToolResult {
+	authErr := asAuthError(err)
+	result := mcp.NewToolResultStructured(authErr.StructuredContent(), authErr.Description)
+	result.IsError = true
+	return result
+}
+
 // OAuthMiddleware creates an authentication middleware (legacy function for compatibility).
 //
 // Deprecated: Use WithOAuth() for new code. This function creates a temporary
@@ -115,17 +109,17 @@ func OAuthMiddleware(validator provider.TokenValidator, enabled bool) func(serve
 //	    mcpserver.WithHTTPContextFunc(oauth.CreateHTTPContextFunc()),
 //	)
 //
-// This extracts "Bearer <token>" from Authorization header and adds it to context
-// via WithOAuthToken(). The OAuth middleware then retrieves it via GetOAuthToken().
+// This extracts "Bearer <token>" (or "DPoP <token>") from Authorization header
+// and adds it to context via WithOAuthToken(). The OAuth middleware then
+// retrieves it via GetOAuthToken(). The request method, URL and DPoP proof are
+// kept in the context so the middleware can verify DPoP-bound tokens.
 func CreateHTTPContextFunc() func(context.Context, *http.Request) context.Context {
 	return func(ctx context.Context, r *http.Request) context.Context {
-		// Extract Bearer token from Authorization header
+		// Extract Bearer or DPoP token from Authorization header
 		authHeader := r.Header.Get("Authorization")
-		if strings.HasPrefix(authHeader, "Bearer ") {
-			token := strings.TrimPrefix(authHeader, "Bearer ")
-			// Clean any whitespace
-			token = strings.TrimSpace(token)
+		if scheme, token := parseAuthorizationHeader(authHeader); token != "" {
 			ctx = WithOAuthToken(ctx, token)
+			ctx = withRequestInfo(ctx, newRequestInfo(r, scheme))
 			log.Printf("OAuth: Token extracted from request (length: %d)", len(token))
 		} else if authHeader != "" {
 			preview := authHeader
@@ -0,0 +1,59 @@
+package oauth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+)
+
+// authorizeRequest applies the request-specific checks to a validated token:
+// sender constraints and the resource the token was issued for
+func (s *Server) authorizeRequest(ctx context.Context, token string, user *User) error {
+	if err := s.checkTokenBinding(ctx, token, user); err != nil {
+		return err
+	}
+	return s.checkResourceAudience(ctx, user)
+}
+
+// checkTokenBinding enforces sender constraints (DPoP proofs, RFC 9449, and
+// certificate-bound tokens, RFC 8705) on a validated token for the current request
+func (s *Server) checkTokenBinding(ctx context.Context, token string, user *User) error {
+	if tokenBindingVerified(ctx, token) {
+		return nil
+	}
+
+	info, _ := getRequestInfo(ctx)
+	if err := s.checkDPoPBinding(info, token, user); err != nil {
+		return err
+	}
+	if s.config != nil && s.config.CertificateBoundTokens {
+		return checkCertificateBinding(info, user)
+	}
+	return nil
+}
+
+// checkCertificateBinding verifies that the token's "cnf.x5t#S256" claim matches
+// the SHA-256 thumbprint of the TLS client certificate (RFC 8705 Section 3)
+func checkCertificateBinding(info *requestInfo, user *User) error {
+	bound := confirmationClaim(user, "x5t#S256")
+	if bound == "" {
+		return invalidTokenError("Access token is not certificate-bound", nil)
+	}
+
+	if info == nil || info.tls == nil || len(info.tls.PeerCertificates) == 0 {
+		return invalidTokenError("Client certificate required for certificate-bound access token", nil)
+	}
+
+	thumbprint := certificateThumbprint(info.tls.PeerCertificates[0].Raw)
+	if subtle.ConstantTimeCompare([]byte(bound), []byte(thumbprint)) != 1 {
+		return invalidTokenError("Client certificate does not match the access token binding", nil)
+	}
+	return nil
+}
+
+// certificateThumbprint returns the base64url-encoded SHA-256 hash of a DER certificate
+func certificateThumbprint(der []byte) string {
+	sum := sha256.Sum256(der)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
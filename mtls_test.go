@@ -0,0 +1,154 @@
+package oauth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// newClientCertificate generates a self-signed client certificate
+func newClientCertificate(t *testing.T, commonName string) tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// signCertificateBoundToken issues an HMAC access token bound to x5t when non-empty
+func signCertificateBoundToken(t *testing.T, cfg *Config, x5t string) string {
+	t.Helper()
+
+	claims := jwt.MapClaims{
+		"sub": "agent",
+		"aud": cfg.Audience,
+		"exp": time.Now().Add(time.Hour).Unix(),
+		"iat": time.Now().Unix(),
+	}
+	if x5t != "" {
+		claims["cnf"] = map[string]interface{}{"x5t#S256": x5t}
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(cfg.JWTSecret)
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+	return token
+}
+
+// TestWrapHandler_CertificateBoundTokens tests RFC 8705 token binding over real TLS connections
+func TestWrapHandler_CertificateBoundTokens(t *testing.T) {
+	cfg := &Config{
+		Provider:               "hmac",
+		Audience:               "api://test",
+		JWTSecret:              []byte("test-secret-key-for-mtls-validation"),
+		CertificateBoundTokens: true,
+	}
+	server, err := NewServer(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+
+	ts := httptest.NewUnstartedServer(server.WrapHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})))
+	ts.TLS = &tls.Config{ClientAuth: tls.RequestClientCert}
+	ts.StartTLS()
+	defer ts.Close()
+
+	clientCert := newClientCertificate(t, "agent")
+	otherCert := newClientCertificate(t, "other")
+	thumbprint := certificateThumbprint(clientCert.Certificate[0])
+
+	request := func(t *testing.T, cert *tls.Certificate, token string) int {
+		t.Helper()
+
+		client := ts.Client()
+		transport := client.Transport.(*http.Transport).Clone()
+		if cert != nil {
+			transport.TLSClientConfig.Certificates = []tls.Certificate{*cert}
+		}
+		client.Transport = transport
+
+		req, _ := http.NewRequest(http.MethodPost, ts.URL+"/mcp", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		return resp.StatusCode
+	}
+
+	tests := []struct {
+		name     string
+		cert     *tls.Certificate
+		x5t      string
+		expected int
+	}{
+		{name: "MatchingCertificate", cert: &clientCert, x5t: thumbprint, expected: http.StatusOK},
+		{name: "MismatchedCertificate", cert: &otherCert, x5t: thumbprint, expected: http.StatusUnauthorized},
+		{name: "NoClientCertificate", x5t: thumbprint, expected: http.StatusUnauthorized},
+		{name: "UnboundToken", cert: &clientCert, expected: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status := request(t, tt.cert, signCertificateBoundToken(t, cfg, tt.x5t)); status != tt.expected {
+				t.Errorf("Expected status %d, got %d", tt.expected, status)
+			}
+		})
+	}
+}
+
+// TestProtectedResourceMetadata_CertificateBoundTokens tests the RFC 8705 metadata parameter
+func TestProtectedResourceMetadata_CertificateBoundTokens(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		handler := CreateOAuth2Handler(&Config{
+			Mode:                   "native",
+			Provider:               "okta",
+			Issuer:                 "https://company.okta.com",
+			Audience:               "api://test",
+			ServerURL:              "https://mcp.example.com",
+			CertificateBoundTokens: enabled,
+		}, "1.0.0", nil)
+
+		rec := httptest.NewRecorder()
+		handler.HandleProtectedResourceMetadata(rec, httptest.NewRequest(http.MethodGet, "/.well-known/oauth-protected-resource", nil))
+
+		var metadata map[string]interface{}
+		if err := json.NewDecoder(rec.Body).Decode(&metadata); err != nil {
+			t.Fatalf("Failed to decode metadata: %v", err)
+		}
+		if _, present := metadata["tls_client_certificate_bound_access_tokens"]; present != enabled {
+			t.Errorf("CertificateBoundTokens=%v: unexpected metadata %v", enabled, metadata["tls_client_certificate_bound_access_tokens"])
+		}
+	}
+}
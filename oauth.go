@@ -3,9 +3,10 @@ package oauth
 import (
 	"context"
 	"crypto/sha256"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"slices"
 	"time"
 
 	mcpserver "github.com/mark3labs/mcp-go/server"
@@ -24,6 +25,9 @@ type Server struct {
 	cache     *TokenCache
 	handler   *OAuth2Handler
 	logger    Logger
+	dpop      *dpopVerifier
+	exchanger *tokenExchanger
+	userInfo  *userInfoClient
 }
 
 // NewServer creates a new OAuth server with the given configuration.
@@ -72,11 +76,14 @@ func NewServer(cfg *Config) (*Server, error) {
 		cache:     cache,
 		handler:   handler,
 		logger:    logger,
+		dpop:      newDPoPVerifier(cfg),
+		exchanger: newTokenExchanger(cfg),
+		userInfo:  newUserInfoClient(cfg, logger),
 	}, nil
 }
 
 // RegisterHandlers registers OAuth HTTP endpoints on the provided mux.
-// Endpoints registered:
+// Endpoints registered (default paths, under Config.BasePath; see Config.Paths):
 //   - /.well-known/oauth-authorization-server - OAuth 2.0 metadata (RFC 8414)
 //   - /.well-known/oauth-protected-resource - Resource metadata
 //   - /.well-known/jwks.json - JWKS keys
@@ -84,19 +91,44 @@ func NewServer(cfg *Config) (*Server, error) {
 //   - /oauth/authorize - Authorization endpoint (proxy mode)
 //   - /oauth/callback - Callback handler (proxy mode)
 //   - /oauth/token - Token exchange (proxy mode)
+//   - /oauth/par - Pushed authorization requests (proxy mode, RFC 9126)
+//   - /oauth/device_authorization - Device authorization (proxy mode, RFC 8628)
 //   - /oauth/register - Dynamic client registration
+//   - /oauth/consent - Consent page form (proxy mode, if Config.Consent is set)
+//   - /oauth/logout - Logout, including upstream logout (proxy mode)
+//
+// When the issuer or resource URL has a path (e.g. BasePath "/api/mcp"), the
+// metadata documents are also registered at the path-suffixed well-known
+// locations of RFC 8414 and RFC 9728, e.g.
+// /.well-known/oauth-authorization-server/api/mcp.
 //
 // Note: WithOAuth() calls this automatically. Only call directly if using
-// NewServer() for advanced use cases.
+// NewServer() for advanced use cases. For other routers, use Handler or Routes.
 func (s *Server) RegisterHandlers(mux *http.ServeMux) {
-	mux.HandleFunc("/.well-known/oauth-authorization-server", s.handler.HandleAuthorizationServerMetadata)
-	mux.HandleFunc("/.well-known/oauth-protected-resource", s.handler.HandleProtectedResourceMetadata)
-	mux.HandleFunc("/.well-known/jwks.json", s.handler.HandleJWKS)
-	mux.HandleFunc("/oauth/authorize", s.handler.HandleAuthorize)
-	mux.HandleFunc("/oauth/callback", s.handler.HandleCallback)
-	mux.HandleFunc("/oauth/token", s.handler.HandleToken)
-	mux.HandleFunc("/oauth/register", s.handler.HandleRegister)
-	mux.HandleFunc("/.well-known/openid-configuration", s.handler.HandleOIDCDiscovery)
+	var registered []string
+	for _, route := range s.Routes() {
+		if slices.Contains(registered, route.Path) {
+			continue
+		}
+		registered = append(registered, route.Path)
+		mux.HandleFunc(route.Path, route.Handler)
+	}
+}
+
+// Handler returns an http.Handler serving all OAuth endpoints (see
+// RegisterHandlers) and 404 for other paths. Mount it on any router, e.g.
+// with chi:
+//
+//	oauthHandler := oauthServer.Handler()
+//	r.Mount("/.well-known", oauthHandler)
+//	r.Mount("/oauth", oauthHandler)
+//
+// Paths are matched in full, so the router must pass the request path
+// unchanged (chi's Mount does; http.StripPrefix does not).
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	s.RegisterHandlers(mux)
+	return mux
 }
 
 // ValidateTokenCached validates a token with caching support.
@@ -105,8 +137,15 @@ func (s *Server) RegisterHandlers(mux *http.ServeMux) {
 // The method:
 //  1. Checks token cache (5-minute TTL)
 //  2. Validates token using configured provider if not cached
-//  3. Caches validation result for future requests
-//  4. Returns authenticated User or error
+//  3. Fills in missing profile claims from the UserInfo endpoint (if
+//     Config.UserInfoEnrichment is enabled)
+//  4. Caches validation result for future requests
+//  5. Verifies the token's sender constraint (DPoP proof, client certificate)
+//     and that its audience covers the requested resource
+//  6. Returns authenticated User or error
+//
+// Rejections caused by the request rather than the token itself are returned
+// as *AuthError.
 //
 // This method is used internally by both WrapHandler and adapter middleware.
 func (s *Server) ValidateTokenCached(ctx context.Context, token string) (*User, error) {
@@ -114,6 +153,10 @@ func (s *Server) ValidateTokenCached(ctx context.Context, token string) (*User,
 
 	if cached, exists := s.cache.getCachedToken(tokenHash); exists {
 		s.logger.Info("Using cached authentication (hash: %s...)", tokenHash[:16])
+		if err := s.authorizeRequest(ctx, token, cached.User); err != nil {
+			s.logger.Error("Token rejected for request: %v", err)
+			return nil, err
+		}
 		return cached.User, nil
 	}
 
@@ -125,46 +168,74 @@ func (s *Server) ValidateTokenCached(ctx context.Context, token string) (*User,
 		return nil, fmt.Errorf("authentication failed: %w", err)
 	}
 
-	expiresAt := time.Now().Add(5 * time.Minute)
+	user = s.enrichUser(ctx, token, user)
+
+	expiresAt := cacheExpiry(user)
 	s.cache.setCachedToken(tokenHash, user, expiresAt)
 
-	s.logger.Info("Authenticated user %s (cached for 5 minutes)", user.Username)
+	if err := s.authorizeRequest(ctx, token, user); err != nil {
+		s.logger.Error("Token rejected for request: %v", err)
+		return nil, err
+	}
+
+	s.logger.Info("Authenticated user %s (cached until %s)", user.Username, expiresAt.Format(time.RFC3339))
 	return user, nil
 }
 
 // GetAuthorizationServerMetadataURL returns the OAuth 2.0 authorization server metadata URL
 func (s *Server) GetAuthorizationServerMetadataURL() string {
-	return fmt.Sprintf("%s/.well-known/oauth-authorization-server", s.config.ServerURL)
+	return s.handler.endpointURL(authorizationServerMetadataPath)
 }
 
 // GetProtectedResourceMetadataURL returns the protected resource metadata URL
 func (s *Server) GetProtectedResourceMetadataURL() string {
-	return fmt.Sprintf("%s/.well-known/oauth-protected-resource", s.config.ServerURL)
+	return s.handler.endpointURL(protectedResourceMetadataPath)
 }
 
 // GetOIDCDiscoveryURL returns the OIDC discovery URL
 func (s *Server) GetOIDCDiscoveryURL() string {
-	return fmt.Sprintf("%s/.well-known/openid-configuration", s.config.ServerURL)
+	return s.handler.endpointURL(oidcDiscoveryPath)
 }
 
 // GetCallbackURL returns the OAuth callback URL
 func (s *Server) GetCallbackURL() string {
-	return fmt.Sprintf("%s/oauth/callback", s.config.ServerURL)
+	return s.handler.endpointURL(s.handler.paths().Callback)
 }
 
 // GetAuthorizeURL returns the OAuth authorization URL
 func (s *Server) GetAuthorizeURL() string {
-	return fmt.Sprintf("%s/oauth/authorize", s.config.ServerURL)
+	return s.handler.endpointURL(s.handler.paths().Authorize)
 }
 
 // GetTokenURL returns the OAuth token URL
 func (s *Server) GetTokenURL() string {
-	return fmt.Sprintf("%s/oauth/token", s.config.ServerURL)
+	return s.handler.endpointURL(s.handler.paths().Token)
 }
 
 // GetRegisterURL returns the dynamic client registration URL
 func (s *Server) GetRegisterURL() string {
-	return fmt.Sprintf("%s/oauth/register", s.config.ServerURL)
+	return s.handler.endpointURL(s.handler.paths().Register)
+}
+
+// GetPushedAuthorizationRequestURL returns the pushed authorization request URL (RFC 9126)
+func (s *Server) GetPushedAuthorizationRequestURL() string {
+	return s.handler.endpointURL(s.handler.paths().PushedAuthorizationRequest)
+}
+
+// GetDeviceAuthorizationURL returns the device authorization URL (RFC 8628)
+func (s *Server) GetDeviceAuthorizationURL() string {
+	return s.handler.endpointURL(s.handler.paths().DeviceAuthorization)
+}
+
+// GetConsentURL returns the consent form endpoint URL (proxy mode)
+func (s *Server) GetConsentURL() string {
+	return s.handler.endpointURL(s.handler.paths().Consent)
+}
+
+// GetLogoutURL returns the logout URL (proxy mode). Register it as post-logout
+// redirect URI at the upstream provider.
+func (s *Server) GetLogoutURL() string {
+	return s.handler.endpointURL(s.handler.paths().Logout)
 }
 
 // Endpoint represents an OAuth endpoint with its path and description
@@ -187,7 +258,15 @@ func (s *Server) GetAllEndpoints() []Endpoint {
 			Endpoint{Path: s.GetCallbackURL(), Description: "OAuth callback"},
 			Endpoint{Path: s.GetTokenURL(), Description: "Token endpoint"},
 			Endpoint{Path: s.GetRegisterURL(), Description: "Client registration"},
+			Endpoint{Path: s.GetPushedAuthorizationRequestURL(), Description: "Pushed authorization requests"},
+			Endpoint{Path: s.GetLogoutURL(), Description: "Logout"},
 		)
+		if s.handler.supportsDeviceAuthorization() {
+			endpoints = append(endpoints, Endpoint{Path: s.GetDeviceAuthorizationURL(), Description: "Device authorization"})
+		}
+		if s.handler.consent != nil {
+			endpoints = append(endpoints, Endpoint{Path: s.GetConsentURL(), Description: "Consent page"})
+		}
 	}
 
 	return endpoints
@@ -212,6 +291,17 @@ func (s *Server) LogStartup(useTLS bool) {
 		s.logger.Info("  - OAuth callback: %s", s.GetCallbackURL())
 		s.logger.Info("  - Token endpoint: %s", s.GetTokenURL())
 		s.logger.Info("  - Client registration: %s", s.GetRegisterURL())
+		s.logger.Info("  - Pushed authorization requests: %s", s.GetPushedAuthorizationRequestURL())
+		s.logger.Info("  - Logout: %s", s.GetLogoutURL())
+		if s.handler.supportsDeviceAuthorization() {
+			s.logger.Info("  - Device authorization: %s", s.GetDeviceAuthorizationURL())
+		}
+		if s.handler.consent != nil {
+			s.logger.Info("  - Consent page: %s", s.GetConsentURL())
+		}
+		for _, upstream := range s.handler.upstreams[1:] {
+			s.logger.Info("  - Upstream provider %s: %s", upstream.name, upstream.config.Endpoint.AuthURL)
+		}
 	}
 }
 
@@ -241,15 +331,11 @@ func (s *Server) GetHTTPServerOptions() []mcpserver.StreamableHTTPOption {
 	}
 }
 
-type oauthErrorResponse struct {
-	Error            string `json:"error"`
-	ErrorDescription string `json:"error_description"`
-}
-
-// WrapHandler wraps an http.Handler with OAuth Bearer token validation.
-// It checks for a valid Authorization header before delegating to the wrapped handler.
+// WrapHandler wraps an http.Handler with OAuth token validation.
+// It checks for a valid Authorization header (Bearer, or DPoP when
+// Config.DPoPMode is set) before delegating to the wrapped handler.
 // If the token is missing or invalid, returns 401 with WWW-Authenticate headers
-// and proper OAuth error response per RFC 6750.
+// and proper OAuth error response per RFC 6750 and RFC 9449.
 //
 // This eliminates the need for consumers to manually check Bearer tokens in
 // their HTTP handlers. Use this to wrap MCP endpoints or any protected resource.
@@ -257,49 +343,49 @@ type oauthErrorResponse struct {
 // Example:
 //
 //	wrappedHandler := oauthServer.WrapHandler(mcpHandler)
-//	mux.HandleFunc("/mcp", wrappedHandler)
+//	mux.Handle("/mcp", wrappedHandler)
+//
+// WrapHandler has the func(http.Handler) http.Handler signature of router
+// middleware, e.g. with chi:
+//
+//	r.With(oauthServer.WrapHandler).Handle("/mcp", mcpHandler)
+//
+// Do not apply it to the OAuth endpoints themselves (see Handler).
 func (s *Server) WrapHandler(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" || len(authHeader) < 7 || authHeader[:7] != "Bearer " {
+		scheme, token := parseAuthorizationHeader(r.Header.Get("Authorization"))
+		if token == "" {
 			s.logger.Info("OAuth: No bearer token provided, returning 401 with discovery info")
-
-			metadataURL := s.GetProtectedResourceMetadataURL()
-			w.Header().Add("WWW-Authenticate", `Bearer realm="OAuth", error="invalid_token", error_description="Missing or invalid access token"`)
-			w.Header().Add("WWW-Authenticate", fmt.Sprintf(`resource_metadata="%s"`, metadataURL))
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusUnauthorized)
-
-			if err := json.NewEncoder(w).Encode(oauthErrorResponse{
-				Error:            "invalid_token",
-				ErrorDescription: "Missing or invalid access token",
-			}); err != nil {
-				s.logger.Error("Error encoding OAuth error response: %v", err)
-			}
+			s.writeAuthChallenge(w, invalidTokenError("Missing or invalid access token", nil))
 			return
 		}
 
-		token := authHeader[7:]
+		ctx := withRequestInfo(r.Context(), newRequestInfo(r, scheme))
 
-		user, err := s.ValidateTokenCached(r.Context(), token)
+		user, err := s.ValidateTokenCached(ctx, token)
 		if err != nil {
 			s.logger.Info("OAuth: Token validation failed: %v", err)
+			s.writeAuthChallenge(w, asAuthError(err))
+			return
+		}
 
-			metadataURL := s.GetProtectedResourceMetadataURL()
-			w.Header().Add("WWW-Authenticate", `Bearer realm="OAuth", error="invalid_token", error_description="Authentication failed"`)
-			w.Header().Add("WWW-Authenticate", fmt.Sprintf(`resource_metadata="%s"`, metadataURL))
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusUnauthorized)
-
-			_ = json.NewEncoder(w).Encode(oauthErrorResponse{
-				Error:            "invalid_token",
-				ErrorDescription: "Authentication failed",
-			})
+		// Enforce step-up requirements of the called tools (RFC 9470)
+		if err := s.checkStepUpRequest(r, user); err != nil {
+			var requestErr *OAuthError
+			if errors.As(err, &requestErr) {
+				s.logger.Info("OAuth: Rejected request body: %v", err)
+				writeOAuthErrorResponse(w, requestErr)
+				return
+			}
+			s.logger.Info("OAuth: Step-up authentication required: %v", err)
+			s.writeAuthChallenge(w, asAuthError(err))
 			return
 		}
 
-		ctx := WithOAuthToken(r.Context(), token)
+		ctx = withTokenBindingVerified(ctx, token)
+		ctx = WithOAuthToken(ctx, token)
 		ctx = WithUser(ctx, user)
+		ctx = WithServer(ctx, s)
 		r = r.WithContext(ctx)
 
 		next.ServeHTTP(w, r)
@@ -0,0 +1,186 @@
+package oauth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// requestURIPrefix is the RFC 9126 request_uri scheme for pushed requests
+const requestURIPrefix = "urn:ietf:params:oauth:request_uri:"
+
+// pushedRequestLifetime is how long a request_uri can be used
+const pushedRequestLifetime = 60 * time.Second
+
+// pushedRequest is an authorization request stored by the PAR endpoint
+type pushedRequest struct {
+	params    url.Values
+	expiresAt time.Time
+}
+
+// parStore holds pushed authorization requests until they are used once
+type parStore struct {
+	mu       sync.Mutex
+	requests map[string]*pushedRequest
+}
+
+// newPARStore creates an empty pushed authorization request store
+func newPARStore() *parStore {
+	return &parStore{requests: make(map[string]*pushedRequest)}
+}
+
+// add stores params for lifetime and returns a new request_uri
+func (s *parStore) add(params url.Values, lifetime time.Duration) (string, error) {
+	id := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("failed to generate request_uri: %w", err)
+	}
+	requestURI := requestURIPrefix + base64.RawURLEncoding.EncodeToString(id)
+
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, request := range s.requests {
+		if now.After(request.expiresAt) {
+			delete(s.requests, key)
+		}
+	}
+	s.requests[requestURI] = &pushedRequest{params: params, expiresAt: now.Add(lifetime)}
+	return requestURI, nil
+}
+
+// take removes and returns the parameters stored for requestURI if it has not
+// expired and was pushed by clientID. A request is kept when another client_id
+// is presented, so that knowing a request_uri is not enough to use it up.
+func (s *parStore) take(requestURI, clientID string) (url.Values, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	request, ok := s.requests[requestURI]
+	if !ok {
+		return nil, fmt.Errorf("unknown or expired request_uri")
+	}
+	if time.Now().After(request.expiresAt) {
+		delete(s.requests, requestURI)
+		return nil, fmt.Errorf("unknown or expired request_uri")
+	}
+	// RFC 9126 Section 4: client_id must match the pushed request
+	if request.params.Get("client_id") != clientID {
+		return nil, fmt.Errorf("client_id does not match the pushed authorization request")
+	}
+	delete(s.requests, requestURI)
+	return request.params, nil
+}
+
+// HandlePushedAuthorizationRequest handles the pushed authorization request
+// endpoint (RFC 9126). The client POSTs the parameters it would send to
+// /oauth/authorize and receives a short-lived, single-use request_uri to use
+// instead, which keeps PKCE, scope and resource values out of browser URLs.
+//
+// PKCE, scope, resource and step-up parameters are validated when the request is
+// pushed; all authorization request checks are applied again at /oauth/authorize.
+func (h *OAuth2Handler) HandlePushedAuthorizationRequest(w http.ResponseWriter, r *http.Request) {
+	// Defense in depth: Check OAuth mode
+	if h.config.Mode == "native" || h.par == nil {
+		writeOAuthError(w, http.StatusNotFound, "invalid_request", "OAuth proxy disabled in native mode")
+		return
+	}
+
+	// Add CORS headers for browser-based MCP clients
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Authorization, *")
+	w.Header().Set("Access-Control-Max-Age", "86400")
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "POST" {
+		writeOAuthError(w, http.StatusMethodNotAllowed, "invalid_request", "Method not allowed")
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		h.logger.Error("OAuth2: Failed to parse form: %v", err)
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "Invalid request")
+		return
+	}
+	params := r.PostForm
+
+	h.logger.Info("OAuth2: Pushed authorization request from %s - client_id: %s", r.RemoteAddr, params.Get("client_id"))
+
+	if params.Get("request_uri") != "" {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "request_uri is not allowed in a pushed authorization request")
+		return
+	}
+	if params.Get("client_id") == "" {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "Missing client_id")
+		return
+	}
+	if params.Get("redirect_uri") == "" {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "Missing redirect_uri")
+		return
+	}
+	if _, err := h.validateCodeChallenge(params.Get("code_challenge"), params.Get("code_challenge_method")); err != nil {
+		h.logger.Warn("SECURITY: Invalid PKCE parameters: %v", err)
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	if err := validateStepUpParams(params.Get("max_age"), params.Get("prompt")); err != nil {
+		h.logger.Warn("SECURITY: Invalid step-up parameter: %v", err)
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "Invalid request")
+		return
+	}
+	if err := h.validateRequestedScope(params.Get("scope")); err != nil {
+		h.logger.Warn("SECURITY: Invalid scope parameter: %v", err)
+		writeOAuthError(w, http.StatusBadRequest, "invalid_scope", "Invalid scope")
+		return
+	}
+	if err := h.validateResources(params["resource"]); err != nil {
+		h.logger.Warn("SECURITY: Invalid resource parameter: %v", err)
+		writeOAuthError(w, http.StatusBadRequest, "invalid_target", "Invalid resource parameter")
+		return
+	}
+
+	requestURI, err := h.par.add(params, pushedRequestLifetime)
+	if err != nil {
+		h.logger.Error("OAuth2: %v", err)
+		writeOAuthError(w, http.StatusInternalServerError, "server_error", "Internal server error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"request_uri": requestURI,
+		"expires_in":  int(pushedRequestLifetime.Seconds()),
+	}); err != nil {
+		h.logger.Error("OAuth2: Failed to encode pushed authorization response: %v", err)
+	}
+}
+
+// resolveAuthorizationRequest returns the authorization request parameters,
+// replacing a request_uri with the pushed parameters it refers to
+func (h *OAuth2Handler) resolveAuthorizationRequest(query url.Values) (url.Values, error) {
+	requestURI := query.Get("request_uri")
+	if requestURI == "" {
+		if h.config.RequirePushedAuthorizationRequests {
+			return nil, fmt.Errorf("pushed authorization request required")
+		}
+		return query, nil
+	}
+
+	if h.par == nil || !strings.HasPrefix(requestURI, requestURIPrefix) {
+		return nil, fmt.Errorf("invalid request_uri")
+	}
+	return h.par.take(requestURI, query.Get("client_id"))
+}
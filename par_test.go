@@ -0,0 +1,139 @@
+package oauth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// newPARTestHandler creates an allowlist-mode proxy handler
+func newPARTestHandler(requirePAR bool) *OAuth2Handler {
+	return NewOAuth2Handler(&OAuth2Config{
+		Mode:                               "proxy",
+		Provider:                           "hmac",
+		Issuer:                             "https://idp.example.com",
+		ClientID:                           "client-id",
+		RedirectURIs:                       "https://client.example.com/callback,https://client.example.com/alt",
+		MCPURL:                             "https://mcp.example.com",
+		RequirePushedAuthorizationRequests: requirePAR,
+		stateSigningKey:                    []byte("test-state-signing-key"),
+	}, nil)
+}
+
+// pushAuthorizationRequest sends params to the PAR endpoint
+func pushAuthorizationRequest(t *testing.T, handler *OAuth2Handler, params url.Values) string {
+	t.Helper()
+
+	rec := postForm(handler.HandlePushedAuthorizationRequest, "/oauth/par", params)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201 from PAR endpoint, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var response struct {
+		RequestURI string `json:"request_uri"`
+		ExpiresIn  int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Invalid PAR response: %v", err)
+	}
+	if response.ExpiresIn != 60 {
+		t.Errorf("Expected expires_in 60, got %d", response.ExpiresIn)
+	}
+	return response.RequestURI
+}
+
+// TestPushedAuthorizationRequest tests pushing a request and using its request_uri
+func TestPushedAuthorizationRequest(t *testing.T) {
+	handler := newPARTestHandler(true)
+
+	authorize := func(params url.Values) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.HandleAuthorize(rec, httptest.NewRequest(http.MethodGet, "/oauth/authorize?"+params.Encode(), nil))
+		return rec
+	}
+
+	// PAR is required: plain authorization requests are rejected
+	if rec := authorize(url.Values{"client_id": {"client"}, "redirect_uri": {"https://client.example.com/callback"}}); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected request without request_uri to be rejected, got %d", rec.Code)
+	}
+
+	requestURI := pushAuthorizationRequest(t, handler, url.Values{
+		"client_id":             {"client"},
+		"redirect_uri":          {"https://client.example.com/callback"},
+		"state":                 {"client-state"},
+		"code_challenge":        {testCodeChallenge},
+		"code_challenge_method": {PKCEMethodS256},
+	})
+
+	rec := authorize(url.Values{"client_id": {"client"}, "request_uri": {requestURI}})
+	if rec.Code != http.StatusTemporaryRedirect {
+		t.Fatalf("Expected redirect, got %d: %s", rec.Code, rec.Body.String())
+	}
+	location, _ := url.Parse(rec.Header().Get("Location"))
+	query := location.Query()
+	if query.Get("code_challenge") != testCodeChallenge || query.Get("state") != "client-state" ||
+		query.Get("redirect_uri") != "https://client.example.com/callback" {
+		t.Errorf("Expected pushed parameters in upstream URL, got %s", location.RawQuery)
+	}
+
+	// request_uri is single use
+	if rec := authorize(url.Values{"client_id": {"client"}, "request_uri": {requestURI}}); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected reused request_uri to be rejected, got %d", rec.Code)
+	}
+
+	// client_id must match the pushed request
+	params := url.Values{"client_id": {"client"}, "redirect_uri": {"https://client.example.com/callback"}}
+	setTestCodeChallenge(params)
+	requestURI = pushAuthorizationRequest(t, handler, params)
+	if rec := authorize(url.Values{"client_id": {"other"}, "request_uri": {requestURI}}); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected client_id mismatch to be rejected, got %d", rec.Code)
+	}
+	// and the request remains usable by its client
+	if rec := authorize(url.Values{"client_id": {"client"}, "request_uri": {requestURI}}); rec.Code != http.StatusTemporaryRedirect {
+		t.Errorf("Expected the pushed request to survive a client_id mismatch, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+// TestPushedAuthorizationRequest_Validation tests rejected pushed requests and metadata
+func TestPushedAuthorizationRequest_Validation(t *testing.T) {
+	handler := newPARTestHandler(false)
+
+	base := url.Values{"client_id": {"client"}, "redirect_uri": {"https://client.example.com/callback"}}
+	setTestCodeChallenge(base)
+	tests := []struct {
+		name  string
+		key   string
+		value string
+	}{
+		{name: "missing client_id", key: "client_id", value: ""},
+		{name: "plain PKCE", key: "code_challenge_method", value: PKCEMethodPlain},
+		{name: "nested request_uri", key: "request_uri", value: requestURIPrefix + "abc"},
+		{name: "unsupported scope", key: "scope", value: "admin"},
+		{name: "unknown resource", key: "resource", value: "https://other.example.com"},
+		{name: "invalid max_age", key: "max_age", value: "-1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := url.Values{}
+			for key, values := range base {
+				params[key] = values
+			}
+			params.Set(tt.key, tt.value)
+			if rec := postForm(handler.HandlePushedAuthorizationRequest, "/oauth/par", params); rec.Code != http.StatusBadRequest {
+				t.Errorf("Expected 400, got %d: %s", rec.Code, rec.Body.String())
+			}
+		})
+	}
+
+	metadata := handler.GetAuthorizationServerMetadata()
+	if metadata.PushedAuthorizationRequestEndpoint != "https://mcp.example.com/oauth/par" {
+		t.Errorf("Expected pushed_authorization_request_endpoint, got %v", metadata.PushedAuthorizationRequestEndpoint)
+	}
+	if metadata.RequirePushedAuthorizationRequests {
+		t.Error("Expected require_pushed_authorization_requests to be omitted when PAR is optional")
+	}
+	if !newPARTestHandler(true).GetAuthorizationServerMetadata().RequirePushedAuthorizationRequests {
+		t.Error("Expected require_pushed_authorization_requests when PAR is required")
+	}
+}
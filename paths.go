@@ -0,0 +1,210 @@
+package oauth
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// Paths of the metadata documents, relative to Config.BasePath. These are
+// fixed by RFC 8414, RFC 9728 and OpenID Connect Discovery.
+const (
+	authorizationServerMetadataPath = "/.well-known/oauth-authorization-server"
+	protectedResourceMetadataPath   = "/.well-known/oauth-protected-resource"
+	oidcDiscoveryPath               = "/.well-known/openid-configuration"
+)
+
+// Paths overrides the paths of the OAuth endpoints (see Config.Paths). Paths
+// are relative to Config.BasePath; empty fields keep the default.
+type Paths struct {
+	Authorize                  string // default: /oauth/authorize
+	Callback                   string // default: /oauth/callback
+	Token                      string // default: /oauth/token
+	Register                   string // default: /oauth/register
+	PushedAuthorizationRequest string // default: /oauth/par
+	DeviceAuthorization        string // default: /oauth/device_authorization
+	Consent                    string // default: /oauth/consent
+	Logout                     string // default: /oauth/logout
+	JWKS                       string // default: /.well-known/jwks.json
+}
+
+// withDefaults returns p with empty fields set to the default paths
+func (p Paths) withDefaults() Paths {
+	defaults := []struct {
+		field *string
+		path  string
+	}{
+		{&p.Authorize, "/oauth/authorize"},
+		{&p.Callback, "/oauth/callback"},
+		{&p.Token, "/oauth/token"},
+		{&p.Register, "/oauth/register"},
+		{&p.PushedAuthorizationRequest, "/oauth/par"},
+		{&p.DeviceAuthorization, "/oauth/device_authorization"},
+		{&p.Consent, "/oauth/consent"},
+		{&p.Logout, "/oauth/logout"},
+		{&p.JWKS, "/.well-known/jwks.json"},
+	}
+	for _, d := range defaults {
+		if *d.field == "" {
+			*d.field = d.path
+		}
+	}
+	return p
+}
+
+// all returns the endpoint paths, including the metadata documents
+func (p Paths) all() []string {
+	return []string{
+		authorizationServerMetadataPath, protectedResourceMetadataPath, oidcDiscoveryPath,
+		p.Authorize, p.Callback, p.Token, p.Register, p.PushedAuthorizationRequest,
+		p.DeviceAuthorization, p.Consent, p.Logout, p.JWKS,
+	}
+}
+
+// validatePaths checks Config.BasePath and Config.Paths
+func validatePaths(c *Config) error {
+	if c.BasePath != "" {
+		if err := validatePath(c.BasePath); err != nil {
+			return fmt.Errorf("invalid BasePath: %w", err)
+		}
+		if strings.HasSuffix(c.BasePath, "/") {
+			return fmt.Errorf("invalid BasePath: %q must not end with /", c.BasePath)
+		}
+	}
+
+	var seen []string
+	for _, path := range c.Paths.withDefaults().all() {
+		if err := validatePath(path); err != nil {
+			return fmt.Errorf("invalid Paths: %w", err)
+		}
+		if slices.Contains(seen, path) {
+			return fmt.Errorf("invalid Paths: %s is used for more than one endpoint", path)
+		}
+		seen = append(seen, path)
+	}
+	return nil
+}
+
+// validatePath checks that path is an absolute URL path without query, fragment or pattern syntax
+func validatePath(path string) error {
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") {
+		return fmt.Errorf("%q must start with a single /", path)
+	}
+	if strings.ContainsAny(path, "?#{} \t\r\n") || strings.Contains(path, "/../") || strings.HasSuffix(path, "/..") {
+		return fmt.Errorf("%q is not a plain URL path", path)
+	}
+	return nil
+}
+
+// paths returns the configured endpoint paths with defaults
+func (h *OAuth2Handler) paths() Paths {
+	return h.config.Paths.withDefaults()
+}
+
+// baseURL returns the URL the endpoint paths are relative to: the scheme and
+// host of MCPURL followed by BasePath, which is where RegisterHandlers and
+// Routes mount them. A path in MCPURL is not part of it. In proxy mode it is
+// also the issuer identifier.
+func (h *OAuth2Handler) baseURL() string {
+	parsed, err := url.Parse(h.config.MCPURL)
+	if err != nil || parsed.Host == "" {
+		return strings.TrimSuffix(h.config.MCPURL, "/") + h.config.BasePath
+	}
+	return parsed.Scheme + "://" + parsed.Host + h.config.BasePath
+}
+
+// endpointURL returns the absolute URL of the endpoint at path
+func (h *OAuth2Handler) endpointURL(path string) string {
+	return h.baseURL() + path
+}
+
+// cookiePath returns the narrowest cookie path covering the browser-facing endpoints
+func (h *OAuth2Handler) cookiePath() string {
+	paths := h.paths()
+	prefix := paths.Authorize
+	for _, path := range []string{paths.Callback, paths.Consent, paths.Logout} {
+		for !strings.HasPrefix(path, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return h.config.BasePath + prefix[:strings.LastIndex(prefix, "/")+1]
+}
+
+// wellKnownPatterns returns the paths a metadata document is served at: under
+// BasePath, and for an identifier with a path also at the location defined
+// by RFC 8414 Section 3.1 and RFC 9728 Section 3.1, where the well-known
+// segment is inserted between the host and the path.
+func (h *OAuth2Handler) wellKnownPatterns(wellKnown, identifier string) []string {
+	patterns := []string{h.config.BasePath + wellKnown}
+	parsed, err := url.Parse(identifier)
+	if err != nil {
+		return patterns
+	}
+	if path := strings.TrimSuffix(parsed.EscapedPath(), "/"); path != "" {
+		patterns = append(patterns, wellKnown+path)
+	}
+	return patterns
+}
+
+// Route is an OAuth endpoint for mounting on any router (see Server.Routes)
+type Route struct {
+	Method  string // HTTP method, e.g. "GET"
+	Path    string // Full path, including Config.BasePath
+	Handler http.HandlerFunc
+}
+
+// Routes returns the OAuth endpoints registered by RegisterHandlers, one
+// entry per method and path, for routers other than http.ServeMux:
+//
+//	r := chi.NewRouter()
+//	for _, route := range oauthServer.Routes() {
+//	    r.Method(route.Method, route.Path, route.Handler)
+//	}
+//
+// The handlers check the method themselves, so a router may also mount each
+// path for all methods. To mount everything at once, use Handler instead.
+func (s *Server) Routes() []Route {
+	h := s.handler
+	paths := h.paths()
+	base := h.config.BasePath
+
+	var routes []Route
+	add := func(handler http.HandlerFunc, methods []string, patterns ...string) {
+		for _, pattern := range patterns {
+			for _, method := range methods {
+				if !containsRoute(routes, method, pattern) {
+					routes = append(routes, Route{Method: method, Path: pattern, Handler: handler})
+				}
+			}
+		}
+	}
+	get := []string{http.MethodGet}
+	post := []string{http.MethodPost, http.MethodOptions}
+
+	add(h.HandleAuthorizationServerMetadata, []string{http.MethodGet, http.MethodHead, http.MethodOptions},
+		h.wellKnownPatterns(authorizationServerMetadataPath, h.baseURL())...)
+	add(h.HandleProtectedResourceMetadata, get, h.wellKnownPatterns(protectedResourceMetadataPath, h.config.MCPURL)...)
+	add(h.HandleJWKS, get, base+paths.JWKS)
+	add(h.HandleAuthorize, get, base+paths.Authorize)
+	add(h.HandleCallback, get, base+paths.Callback)
+	add(h.HandleToken, post, base+paths.Token)
+	add(h.HandlePushedAuthorizationRequest, post, base+paths.PushedAuthorizationRequest)
+	add(h.HandleDeviceAuthorization, post, base+paths.DeviceAuthorization)
+	add(h.HandleRegister, post, base+paths.Register)
+	add(h.HandleConsent, []string{http.MethodPost}, base+paths.Consent)
+	add(s.HandleLogout, []string{http.MethodGet, http.MethodPost}, base+paths.Logout)
+	add(h.HandleOIDCDiscovery, get, h.wellKnownPatterns(oidcDiscoveryPath, h.baseURL())...)
+	return routes
+}
+
+// containsRoute reports whether routes has a route for method and path
+func containsRoute(routes []Route, method, path string) bool {
+	for _, r := range routes {
+		if r.Method == method && r.Path == path {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,234 @@
+package oauth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newPathsTestMux creates a proxy server with the given base path and paths, registered on a new mux
+func newPathsTestMux(t *testing.T, serverURL, basePath string, paths Paths) (*Server, *http.ServeMux) {
+	t.Helper()
+
+	server := newTestServer(t, nil, func(cfg *Config) {
+		cfg.Mode = "proxy"
+		cfg.ServerURL = serverURL
+		cfg.RedirectURIs = "https://mcp.example.com/api/mcp/oauth/callback"
+		cfg.BasePath = basePath
+		cfg.Paths = paths
+	})
+	mux := http.NewServeMux()
+	server.RegisterHandlers(mux)
+	return server, mux
+}
+
+// getMetadata fetches a metadata document from mux, or returns nil if it is not served
+func getMetadata(mux *http.ServeMux, path string) map[string]interface{} {
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	if rec.Code != http.StatusOK {
+		return nil
+	}
+	var metadata map[string]interface{}
+	if err := json.NewDecoder(rec.Body).Decode(&metadata); err != nil {
+		return nil
+	}
+	return metadata
+}
+
+// TestBasePath tests registering and advertising the endpoints under a base path
+func TestBasePath(t *testing.T) {
+	server, mux := newPathsTestMux(t, "https://mcp.example.com/api/mcp", "/api/mcp", Paths{})
+
+	if url := server.GetTokenURL(); url != "https://mcp.example.com/api/mcp/oauth/token" {
+		t.Errorf("Expected token URL under the base path, got %s", url)
+	}
+	if url := server.GetProtectedResourceMetadataURL(); url != "https://mcp.example.com/api/mcp/.well-known/oauth-protected-resource" {
+		t.Errorf("Expected resource metadata URL under the base path, got %s", url)
+	}
+
+	// Authorization server metadata under the base path and at the RFC 8414 location
+	for _, path := range []string{"/api/mcp/.well-known/oauth-authorization-server", "/.well-known/oauth-authorization-server/api/mcp"} {
+		metadata := getMetadata(mux, path)
+		if metadata == nil {
+			t.Fatalf("Expected metadata at %s", path)
+		}
+		if metadata["issuer"] != "https://mcp.example.com/api/mcp" || metadata["authorization_endpoint"] != "https://mcp.example.com/api/mcp/oauth/authorize" {
+			t.Errorf("Expected endpoints under the base path at %s, got %v", path, metadata)
+		}
+	}
+
+	// Protected resource metadata under the base path and at the RFC 9728 location
+	for _, path := range []string{"/api/mcp/.well-known/oauth-protected-resource", "/.well-known/oauth-protected-resource/api/mcp"} {
+		metadata := getMetadata(mux, path)
+		if metadata == nil || metadata["resource"] != "https://mcp.example.com/api/mcp" {
+			t.Errorf("Expected resource metadata at %s, got %v", path, metadata)
+		}
+	}
+	for _, path := range []string{"/api/mcp/.well-known/openid-configuration", "/.well-known/openid-configuration/api/mcp"} {
+		if metadata := getMetadata(mux, path); metadata == nil || metadata["token_endpoint"] != "https://mcp.example.com/api/mcp/oauth/token" {
+			t.Errorf("Expected OIDC discovery at %s, got %v", path, metadata)
+		}
+	}
+
+	// Nothing is registered at the root paths
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/oauth/authorize", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected /oauth/authorize not to be registered, got %d", rec.Code)
+	}
+	if path := server.handler.cookiePath(); path != "/api/mcp/oauth/" {
+		t.Errorf("Expected session cookie under the base path, got %s", path)
+	}
+}
+
+// TestBasePath_ServerURLPath tests that a path in ServerURL without BasePath
+// does not move the advertised endpoints away from where they are registered
+func TestBasePath_ServerURLPath(t *testing.T) {
+	server, mux := newPathsTestMux(t, "https://mcp.example.com/mcp", "", Paths{})
+
+	if url := server.GetAuthorizeURL(); url != "https://mcp.example.com/oauth/authorize" {
+		t.Errorf("Expected authorize URL at the root, got %s", url)
+	}
+
+	metadata := getMetadata(mux, "/.well-known/oauth-authorization-server")
+	if metadata == nil {
+		t.Fatal("Expected metadata at the root")
+	}
+	if metadata["issuer"] != "https://mcp.example.com" {
+		t.Errorf("Expected the issuer without the resource path, got %v", metadata["issuer"])
+	}
+	for _, field := range []string{"authorization_endpoint", "token_endpoint", "registration_endpoint"} {
+		endpoint, _ := metadata[field].(string)
+		path := strings.TrimPrefix(endpoint, "https://mcp.example.com")
+		if path == endpoint || !strings.HasPrefix(path, "/oauth/") {
+			t.Errorf("Expected %s at the root, got %s", field, endpoint)
+			continue
+		}
+		if _, pattern := mux.Handler(httptest.NewRequest(http.MethodGet, path, nil)); pattern == "" {
+			t.Errorf("Expected %s to be registered at %s", field, path)
+		}
+	}
+
+	// The resource keeps its path
+	if metadata := getMetadata(mux, "/.well-known/oauth-protected-resource/mcp"); metadata == nil || metadata["resource"] != "https://mcp.example.com/mcp" {
+		t.Errorf("Expected resource metadata at the RFC 9728 location, got %v", metadata)
+	}
+}
+
+// TestPaths tests overriding individual endpoint paths
+func TestPaths(t *testing.T) {
+	server, mux := newPathsTestMux(t, "https://mcp.example.com", "", Paths{Authorize: "/authorize", Token: "/connect/token"})
+
+	metadata := getMetadata(mux, "/.well-known/oauth-authorization-server")
+	if metadata == nil || metadata["issuer"] != "https://mcp.example.com" {
+		t.Fatalf("Expected metadata at the root, got %v", metadata)
+	}
+	if metadata["authorization_endpoint"] != "https://mcp.example.com/authorize" || metadata["token_endpoint"] != "https://mcp.example.com/connect/token" {
+		t.Errorf("Expected overridden endpoints, got %v", metadata)
+	}
+	if metadata["registration_endpoint"] != "https://mcp.example.com/oauth/register" {
+		t.Errorf("Expected default registration endpoint, got %v", metadata["registration_endpoint"])
+	}
+	if server.GetAuthorizeURL() != "https://mcp.example.com/authorize" {
+		t.Errorf("Unexpected authorize URL: %s", server.GetAuthorizeURL())
+	}
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/connect/token", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected token endpoint at /connect/token, got %d", rec.Code)
+	}
+	if path := server.handler.cookiePath(); path != "/" {
+		t.Errorf("Expected session cookie to cover /authorize and /oauth/consent, got %s", path)
+	}
+}
+
+// TestPathsValidation tests BasePath and Paths checks
+func TestPathsValidation(t *testing.T) {
+	invalid := map[string]func(cfg *Config){
+		"relative base path":  func(cfg *Config) { cfg.BasePath = "api/mcp" },
+		"trailing slash":      func(cfg *Config) { cfg.BasePath = "/api/mcp/" },
+		"query in base path":  func(cfg *Config) { cfg.BasePath = "/api?x=1" },
+		"relative path":       func(cfg *Config) { cfg.Paths.Token = "token" },
+		"pattern wildcard":    func(cfg *Config) { cfg.Paths.Token = "/{token}" },
+		"dot segments":        func(cfg *Config) { cfg.Paths.Token = "/oauth/../token" },
+		"duplicate endpoint":  func(cfg *Config) { cfg.Paths.Token = "/oauth/authorize" },
+		"metadata collision":  func(cfg *Config) { cfg.Paths.JWKS = "/.well-known/openid-configuration" },
+		"scheme-relative URL": func(cfg *Config) { cfg.BasePath = "//evil.example.com" },
+	}
+	for name, mutate := range invalid {
+		t.Run(name, func(t *testing.T) {
+			cfg := &Config{Mode: "native", Provider: "hmac", Audience: "api://test", JWTSecret: []byte("secret")}
+			mutate(cfg)
+			if err := cfg.Validate(); err == nil {
+				t.Error("Expected configuration to be rejected")
+			}
+		})
+	}
+
+	cfg := NewConfigBuilder().WithMode("native").WithProvider("hmac").WithAudience("api://test").WithJWTSecret([]byte("secret")).
+		WithBasePath("/api/mcp").WithPaths(Paths{Token: "/token"})
+	if _, err := cfg.Build(); err != nil {
+		t.Errorf("Expected valid paths, got %v", err)
+	}
+}
+
+// TestRoutes tests mounting the OAuth endpoints on other routers
+func TestRoutes(t *testing.T) {
+	server, _ := newPathsTestMux(t, "https://mcp.example.com/api/mcp", "/api/mcp", Paths{})
+
+	// A method-aware router, like chi or gin
+	router := map[string]http.HandlerFunc{}
+	for _, route := range server.Routes() {
+		key := route.Method + " " + route.Path
+		if _, exists := router[key]; exists {
+			t.Errorf("Duplicate route %s", key)
+		}
+		router[key] = route.Handler
+	}
+	for _, key := range []string{
+		"GET /api/mcp/oauth/authorize",
+		"POST /api/mcp/oauth/token",
+		"OPTIONS /api/mcp/oauth/register",
+		"POST /api/mcp/oauth/consent",
+		"GET /api/mcp/oauth/logout",
+		"HEAD /.well-known/oauth-authorization-server/api/mcp",
+		"GET /.well-known/oauth-protected-resource/api/mcp",
+	} {
+		if router[key] == nil {
+			t.Errorf("Expected route %s", key)
+		}
+	}
+	if router["GET /api/mcp/oauth/token"] != nil {
+		t.Error("Expected the token endpoint to be POST only")
+	}
+	rec := httptest.NewRecorder()
+	router["POST /api/mcp/oauth/token"](rec, httptest.NewRequest(http.MethodPost, "/api/mcp/oauth/token", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected the token endpoint to handle the request, got %d", rec.Code)
+	}
+
+	// Handler serves every route and nothing else
+	handler := server.Handler()
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/.well-known/oauth-authorization-server/api/mcp", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected metadata from Handler, got %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/mcp/tools", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for other paths, got %d", rec.Code)
+	}
+
+	// WrapHandler is router middleware
+	var middleware func(http.Handler) http.Handler = server.WrapHandler
+	rec = httptest.NewRecorder()
+	middleware(http.NotFoundHandler()).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/mcp/mcp", nil))
+	if rec.Code != http.StatusUnauthorized || rec.Header().Get("WWW-Authenticate") == "" {
+		t.Errorf("Expected 401 challenge from the middleware, got %d", rec.Code)
+	}
+}
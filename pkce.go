@@ -0,0 +1,169 @@
+package oauth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"slices"
+	"sync"
+	"time"
+)
+
+// PKCE code challenge methods (RFC 7636)
+const (
+	PKCEMethodS256  = "S256"
+	PKCEMethodPlain = "plain"
+)
+
+// defaultPKCEMethods allows only S256, as recommended by OAuth 2.1
+var defaultPKCEMethods = []string{PKCEMethodS256}
+
+// pkceTransactionLifetime bounds the time between callback and token exchange
+const pkceTransactionLifetime = 10 * time.Minute
+
+// pkceValuePattern matches code verifiers and S256/plain challenges (RFC 7636 Section 4.1)
+var pkceValuePattern = regexp.MustCompile(`^[A-Za-z0-9\-._~]{43,128}$`)
+
+// validatePKCEMethods checks configured code challenge methods
+func validatePKCEMethods(methods []string) error {
+	for _, method := range methods {
+		if method != PKCEMethodS256 && method != PKCEMethodPlain {
+			return fmt.Errorf("unsupported PKCE method: %s (supported: %s, %s)", method, PKCEMethodS256, PKCEMethodPlain)
+		}
+	}
+	return nil
+}
+
+// pkceMethods returns the accepted code challenge methods
+func (h *OAuth2Handler) pkceMethods() []string {
+	if len(h.config.PKCEMethods) > 0 {
+		return h.config.PKCEMethods
+	}
+	return defaultPKCEMethods
+}
+
+// validateCodeChallenge checks a client's code_challenge and returns the
+// effective method. PKCE is required: proxy clients are public clients.
+func (h *OAuth2Handler) validateCodeChallenge(challenge, method string) (string, error) {
+	if challenge == "" {
+		return "", fmt.Errorf("code_challenge is required")
+	}
+	if method == "" {
+		method = PKCEMethodPlain // RFC 7636 Section 4.3 default
+	}
+	if !slices.Contains(h.pkceMethods(), method) {
+		return "", fmt.Errorf("code_challenge_method %q is not allowed", method)
+	}
+	if !pkceValuePattern.MatchString(challenge) {
+		return "", fmt.Errorf("malformed code_challenge")
+	}
+	return method, nil
+}
+
+// verifyCodeVerifier checks a code_verifier against the challenge of the authorization request
+func verifyCodeVerifier(verifier, challenge, method string) error {
+	if !pkceValuePattern.MatchString(verifier) {
+		return fmt.Errorf("malformed code_verifier")
+	}
+	expected := verifier
+	if method == PKCEMethodS256 {
+		sum := sha256.Sum256([]byte(verifier))
+		expected = base64.RawURLEncoding.EncodeToString(sum[:])
+	}
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) != 1 {
+		return fmt.Errorf("code_verifier does not match code_challenge")
+	}
+	return nil
+}
+
+// pkceTransaction binds an authorization code to the client's PKCE challenge
+type pkceTransaction struct {
+	challenge   string
+	method      string
+	redirectURI string
+	upstream    string // Name of the upstream provider that issued the code
+	expiresAt   time.Time
+}
+
+// pkceStore holds transactions from the proxy callback until the code is redeemed
+type pkceStore struct {
+	mu           sync.Mutex
+	transactions map[string]*pkceTransaction
+}
+
+// newPKCEStore creates an empty transaction store
+func newPKCEStore() *pkceStore {
+	return &pkceStore{transactions: make(map[string]*pkceTransaction)}
+}
+
+// add records the transaction for an authorization code
+func (s *pkceStore) add(code string, transaction *pkceTransaction) {
+	now := time.Now()
+	transaction.expiresAt = now.Add(pkceTransactionLifetime)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, t := range s.transactions {
+		if now.After(t.expiresAt) {
+			delete(s.transactions, key)
+		}
+	}
+	s.transactions[codeKey(code)] = transaction
+}
+
+// take removes and returns the unexpired transaction for an authorization code
+func (s *pkceStore) take(code string) (*pkceTransaction, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := codeKey(code)
+	transaction, ok := s.transactions[key]
+	if !ok {
+		return nil, false
+	}
+	delete(s.transactions, key)
+	if time.Now().After(transaction.expiresAt) {
+		return nil, false
+	}
+	return transaction, true
+}
+
+// codeKey avoids keeping authorization codes in memory
+func codeKey(code string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(code)))
+}
+
+// verifyPKCE checks the token request's code_verifier before the code is
+// redeemed upstream. In fixed redirect mode the callback recorded the
+// challenge for the code, so the verifier and redirect_uri are checked
+// locally and the transaction is returned; in allowlist mode the code never
+// passes through the proxy, and the challenge forwarded to the provider is
+// verified there.
+func (h *OAuth2Handler) verifyPKCE(code, verifier, redirectURI string, fixedRedirect bool) (*pkceTransaction, error) {
+	if verifier == "" {
+		return nil, fmt.Errorf("code_verifier is required")
+	}
+	if !fixedRedirect {
+		if !pkceValuePattern.MatchString(verifier) {
+			return nil, fmt.Errorf("malformed code_verifier")
+		}
+		return nil, nil
+	}
+
+	if h.pkce == nil {
+		return nil, fmt.Errorf("no authorization transaction for code")
+	}
+	transaction, ok := h.pkce.take(code)
+	if !ok {
+		return nil, fmt.Errorf("unknown or expired authorization code")
+	}
+	if redirectURI != transaction.redirectURI {
+		return nil, fmt.Errorf("redirect_uri does not match the authorization request")
+	}
+	if err := verifyCodeVerifier(verifier, transaction.challenge, transaction.method); err != nil {
+		return nil, err
+	}
+	return transaction, nil
+}
@@ -0,0 +1,151 @@
+package oauth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+// PKCE example values from RFC 7636 Appendix B
+const (
+	testCodeVerifier  = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	testCodeChallenge = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+)
+
+// setTestCodeChallenge adds an S256 PKCE challenge to authorization request parameters
+func setTestCodeChallenge(params url.Values) {
+	params.Set("code_challenge", testCodeChallenge)
+	params.Set("code_challenge_method", PKCEMethodS256)
+}
+
+// TestValidateCodeChallenge tests the PKCE policy applied at /oauth/authorize
+func TestValidateCodeChallenge(t *testing.T) {
+	s256Only := &OAuth2Handler{config: &OAuth2Config{}}
+	withPlain := &OAuth2Handler{config: &OAuth2Config{PKCEMethods: []string{PKCEMethodS256, PKCEMethodPlain}}}
+
+	tests := []struct {
+		name      string
+		handler   *OAuth2Handler
+		challenge string
+		method    string
+		expected  string
+		expectErr bool
+	}{
+		{name: "S256", handler: s256Only, challenge: testCodeChallenge, method: "S256", expected: "S256"},
+		{name: "missing challenge", handler: s256Only, method: "S256", expectErr: true},
+		{name: "plain rejected by default", handler: s256Only, challenge: testCodeVerifier, method: "plain", expectErr: true},
+		{name: "missing method means plain", handler: s256Only, challenge: testCodeVerifier, expectErr: true},
+		{name: "plain allowed when configured", handler: withPlain, challenge: testCodeVerifier, expected: "plain"},
+		{name: "unknown method", handler: withPlain, challenge: testCodeChallenge, method: "S512", expectErr: true},
+		{name: "too short", handler: s256Only, challenge: "abc", method: "S256", expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method, err := tt.handler.validateCodeChallenge(tt.challenge, tt.method)
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("validateCodeChallenge() error = %v, expectErr %v", err, tt.expectErr)
+			}
+			if method != tt.expected {
+				t.Errorf("Expected method %q, got %q", tt.expected, method)
+			}
+		})
+	}
+
+	if err := verifyCodeVerifier(testCodeVerifier, testCodeChallenge, PKCEMethodS256); err != nil {
+		t.Errorf("Expected RFC 7636 example to verify: %v", err)
+	}
+	if err := verifyCodeVerifier(testCodeVerifier+"x", testCodeChallenge, PKCEMethodS256); err == nil {
+		t.Error("Expected wrong verifier to be rejected")
+	}
+	if err := validatePKCEMethods([]string{"S512"}); err == nil {
+		t.Error("Expected unknown configured method to be rejected")
+	}
+}
+
+// TestPKCE_FixedRedirectFlow tests that the proxy binds the code to the challenge at the callback
+// and verifies the code_verifier before calling the upstream token endpoint
+func TestPKCE_FixedRedirectFlow(t *testing.T) {
+	var upstreamCalls int
+	var upstreamVerifier string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamCalls++
+		_ = r.ParseForm()
+		upstreamVerifier = r.PostForm.Get("code_verifier")
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "upstream-token", "token_type": "Bearer", "expires_in": 3600})
+	}))
+	defer upstream.Close()
+
+	handler := NewOAuth2Handler(&OAuth2Config{
+		Mode:            "proxy",
+		Provider:        "hmac",
+		Issuer:          upstream.URL,
+		ClientID:        "client-id",
+		RedirectURIs:    "https://mcp.example.com/oauth/callback",
+		MCPURL:          "https://mcp.example.com",
+		stateSigningKey: []byte("test-state-signing-key"),
+	}, nil)
+	clientRedirect := "http://localhost:3000/callback"
+
+	// authorizeAndCallback runs /oauth/authorize and the upstream redirect to /oauth/callback for code
+	authorizeAndCallback := func(code string) {
+		params := url.Values{"client_id": {"client"}, "redirect_uri": {clientRedirect}, "state": {"client-state"}}
+		setTestCodeChallenge(params)
+		rec := httptest.NewRecorder()
+		handler.HandleAuthorize(rec, httptest.NewRequest(http.MethodGet, "/oauth/authorize?"+params.Encode(), nil))
+		if rec.Code != http.StatusTemporaryRedirect {
+			t.Fatalf("Expected redirect, got %d: %s", rec.Code, rec.Body.String())
+		}
+		location, _ := url.Parse(rec.Header().Get("Location"))
+		state := location.Query().Get("state")
+
+		rec = httptest.NewRecorder()
+		callback := url.Values{"code": {code}, "state": {state}}
+		handler.HandleCallback(rec, httptest.NewRequest(http.MethodGet, "/oauth/callback?"+callback.Encode(), nil))
+		if rec.Code != http.StatusFound {
+			t.Fatalf("Expected callback redirect, got %d: %s", rec.Code, rec.Body.String())
+		}
+	}
+
+	token := func(code, verifier string) int {
+		form := url.Values{"grant_type": {"authorization_code"}, "code": {code}, "redirect_uri": {clientRedirect}, "code_verifier": {verifier}}
+		return postForm(handler.HandleToken, "/oauth/token", form).Code
+	}
+
+	authorizeAndCallback("code-1")
+	if status := token("code-1", "wrong-verifier-wrong-verifier-wrong-verifier-xyz"); status != http.StatusBadRequest {
+		t.Errorf("Expected wrong verifier to be rejected, got %d", status)
+	}
+	if upstreamCalls != 0 {
+		t.Errorf("Expected no upstream call for a rejected verifier, got %d", upstreamCalls)
+	}
+	// The transaction is consumed by the failed attempt
+	if status := token("code-1", testCodeVerifier); status != http.StatusBadRequest {
+		t.Errorf("Expected consumed code to be rejected, got %d", status)
+	}
+
+	authorizeAndCallback("code-2")
+	if status := token("code-2", ""); status != http.StatusBadRequest {
+		t.Errorf("Expected missing verifier to be rejected, got %d", status)
+	}
+	authorizeAndCallback("code-3")
+	if status := token("code-3", testCodeVerifier); status != http.StatusOK {
+		t.Fatalf("Expected valid verifier to be accepted, got %d", status)
+	}
+	if upstreamVerifier != testCodeVerifier {
+		t.Errorf("Expected code_verifier forwarded upstream, got %q", upstreamVerifier)
+	}
+
+	if status := token("never-issued", testCodeVerifier); status != http.StatusBadRequest {
+		t.Errorf("Expected unknown code to be rejected, got %d", status)
+	}
+
+	methods := handler.GetAuthorizationServerMetadata().CodeChallengeMethodsSupported
+	if !reflect.DeepEqual(methods, []string{PKCEMethodS256}) {
+		t.Errorf("Expected only S256 advertised, got %v", methods)
+	}
+}
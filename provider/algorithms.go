@@ -0,0 +1,76 @@
+package provider
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// hmacAlgorithms are the symmetric algorithms supported by HMACValidator
+var hmacAlgorithms = map[string]bool{
+	"HS256": true,
+	"HS384": true,
+	"HS512": true,
+}
+
+// asymmetricAlgorithms are the JWKS-verifiable algorithms supported by OIDCValidator
+var asymmetricAlgorithms = map[string]bool{
+	"RS256": true,
+	"RS384": true,
+	"RS512": true,
+	"ES256": true,
+	"ES384": true,
+	"ES512": true,
+	"PS256": true,
+	"PS384": true,
+	"PS512": true,
+	"EdDSA": true,
+}
+
+// DefaultAlgorithms returns the signing algorithms accepted when
+// Config.AllowedAlgorithms is empty: HS256 for HMAC, RS256 and ES256 for OIDC.
+func DefaultAlgorithms(providerName string) []string {
+	if providerName == "hmac" {
+		return []string{"HS256"}
+	}
+	return []string{"RS256", "ES256"}
+}
+
+// ValidateAlgorithms checks that every algorithm can be verified by the provider.
+// HMAC providers only accept HS256/HS384/HS512; OIDC providers only accept
+// asymmetric algorithms, so a shared secret can never be confused with a public key.
+func ValidateAlgorithms(providerName string, algorithms []string) error {
+	supported := asymmetricAlgorithms
+	if providerName == "hmac" {
+		supported = hmacAlgorithms
+	}
+
+	for _, alg := range algorithms {
+		if !supported[alg] {
+			return fmt.Errorf("signing algorithm %q is not supported for provider %s (supported: %s)",
+				alg, providerName, strings.Join(sortedKeys(supported), ", "))
+		}
+	}
+	return nil
+}
+
+// allowedAlgorithms resolves the configured algorithm allowlist for a provider
+func allowedAlgorithms(providerName string, configured []string) ([]string, error) {
+	if len(configured) == 0 {
+		return DefaultAlgorithms(providerName), nil
+	}
+	if err := ValidateAlgorithms(providerName, configured); err != nil {
+		return nil, err
+	}
+	return configured, nil
+}
+
+// sortedKeys returns the keys of a set in a stable order for error messages
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
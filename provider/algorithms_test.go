@@ -0,0 +1,124 @@
+package provider
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// TestValidateAlgorithms tests the per-provider algorithm allowlist checks
+func TestValidateAlgorithms(t *testing.T) {
+	tests := []struct {
+		name       string
+		provider   string
+		algorithms []string
+		expectErr  bool
+	}{
+		{name: "defaults", provider: "okta"},
+		{name: "HMAC variants", provider: "hmac", algorithms: []string{"HS256", "HS384", "HS512"}},
+		{name: "OIDC asymmetric", provider: "azure", algorithms: []string{"RS256", "PS256", "ES384", "EdDSA"}},
+		{name: "HMAC with RSA", provider: "hmac", algorithms: []string{"RS256"}, expectErr: true},
+		{name: "OIDC with HMAC", provider: "okta", algorithms: []string{"HS256"}, expectErr: true},
+		{name: "none algorithm", provider: "google", algorithms: []string{"none"}, expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateAlgorithms(tt.provider, tt.algorithms)
+			if (err != nil) != tt.expectErr {
+				t.Errorf("ValidateAlgorithms() error = %v, expectErr %v", err, tt.expectErr)
+			}
+		})
+	}
+}
+
+// TestHMACValidator_AllowedAlgorithms tests that HMAC variants are explicitly controlled
+func TestHMACValidator_AllowedAlgorithms(t *testing.T) {
+	secret := []byte("test-secret-key-for-hmac-validation")
+
+	sign := func(method jwt.SigningMethod) string {
+		token := jwt.NewWithClaims(method, jwt.MapClaims{
+			"sub": "test-user",
+			"aud": "api://test",
+			"exp": time.Now().Add(time.Hour).Unix(),
+			"iat": time.Now().Unix(),
+		})
+		tokenString, err := token.SignedString(secret)
+		if err != nil {
+			t.Fatalf("Failed to sign token: %v", err)
+		}
+		return tokenString
+	}
+
+	t.Run("DefaultRejectsHS512", func(t *testing.T) {
+		validator := &HMACValidator{}
+		if err := validator.Initialize(&Config{JWTSecret: secret, Audience: "api://test"}); err != nil {
+			t.Fatalf("Failed to initialize validator: %v", err)
+		}
+
+		if _, err := validator.ValidateToken(context.Background(), sign(jwt.SigningMethodHS256)); err != nil {
+			t.Errorf("Expected HS256 token to pass, got error: %v", err)
+		}
+
+		_, err := validator.ValidateToken(context.Background(), sign(jwt.SigningMethodHS512))
+		if err == nil || !strings.Contains(err.Error(), "signing method HS512 is invalid") {
+			t.Errorf("Expected HS512 to be rejected by default, got: %v", err)
+		}
+	})
+
+	t.Run("ExplicitHS512", func(t *testing.T) {
+		validator := &HMACValidator{}
+		err := validator.Initialize(&Config{JWTSecret: secret, Audience: "api://test", AllowedAlgorithms: []string{"HS512"}})
+		if err != nil {
+			t.Fatalf("Failed to initialize validator: %v", err)
+		}
+
+		if _, err := validator.ValidateToken(context.Background(), sign(jwt.SigningMethodHS512)); err != nil {
+			t.Errorf("Expected HS512 token to pass, got error: %v", err)
+		}
+		if _, err := validator.ValidateToken(context.Background(), sign(jwt.SigningMethodHS256)); err == nil {
+			t.Error("Expected HS256 token to be rejected when only HS512 is allowed")
+		}
+	})
+
+	t.Run("InvalidAllowlist", func(t *testing.T) {
+		err := (&HMACValidator{}).Initialize(&Config{JWTSecret: secret, Audience: "api://test", AllowedAlgorithms: []string{"RS256"}})
+		if err == nil {
+			t.Error("Expected RS256 allowlist to fail for HMAC provider")
+		}
+	})
+}
+
+// TestOIDCValidator_AllowedAlgorithms tests PS256 and EdDSA issuers
+func TestOIDCValidator_AllowedAlgorithms(t *testing.T) {
+	for _, alg := range []string{"PS256", "ES256", "EdDSA"} {
+		t.Run(alg, func(t *testing.T) {
+			server := newTestOIDCServerWithAlg(t, alg)
+			claims := jwt.MapClaims{
+				"iss": server.URL,
+				"sub": "user-123",
+				"aud": "api://test",
+				"exp": time.Now().Add(time.Hour).Unix(),
+			}
+
+			allowed := &OIDCValidator{}
+			if err := allowed.Initialize(&Config{Issuer: server.URL, Audience: "api://test", AllowedAlgorithms: []string{alg}}); err != nil {
+				t.Fatalf("Failed to initialize validator: %v", err)
+			}
+			if _, err := allowed.ValidateToken(context.Background(), server.sign(t, claims)); err != nil {
+				t.Errorf("Expected %s token to pass, got error: %v", alg, err)
+			}
+
+			restricted := &OIDCValidator{}
+			if err := restricted.Initialize(&Config{Issuer: server.URL, Audience: "api://test", AllowedAlgorithms: []string{"RS256"}}); err != nil {
+				t.Fatalf("Failed to initialize validator: %v", err)
+			}
+			if _, err := restricted.ValidateToken(context.Background(), server.sign(t, claims)); err == nil {
+				t.Errorf("Expected %s token to be rejected when only RS256 is allowed", alg)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package provider
+
+import "github.com/golang-jwt/jwt/v5"
+
+// ClaimMapping controls which token claims populate User fields.
+// Empty fields fall back to the standard OIDC claim names.
+//
+// Example (Azure AD tokens without preferred_username):
+//
+//	&provider.ClaimMapping{Username: "upn", Email: "unique_name"}
+type ClaimMapping struct {
+	Subject  string // Claim for User.Subject (default: "sub")
+	Username string // Claim for User.Username (default: "preferred_username")
+	Email    string // Claim for User.Email (default: "email")
+}
+
+// IssuerConfig describes a trusted OIDC issuer for MultiIssuerValidator
+type IssuerConfig struct {
+	// Issuer is the exact "iss" value (and discovery base URL) of the issuer
+	Issuer string
+	// Audiences accepted for this issuer. If empty, Config.Audience and
+	// Config.Audiences are used.
+	Audiences []string
+	// ClaimMapping overrides Config.ClaimMapping for tokens from this issuer
+	ClaimMapping *ClaimMapping
+}
+
+// SubjectClaim returns the claim name used for User.Subject
+func (m *ClaimMapping) SubjectClaim() string {
+	if m != nil && m.Subject != "" {
+		return m.Subject
+	}
+	return "sub"
+}
+
+// UsernameClaim returns the claim name used for User.Username
+func (m *ClaimMapping) UsernameClaim() string {
+	if m != nil && m.Username != "" {
+		return m.Username
+	}
+	return "preferred_username"
+}
+
+// EmailClaim returns the claim name used for User.Email
+func (m *ClaimMapping) EmailClaim() string {
+	if m != nil && m.Email != "" {
+		return m.Email
+	}
+	return "email"
+}
+
+// userFromClaims builds a User from validated token claims.
+// A nil mapping uses the standard OIDC claim names.
+func (m *ClaimMapping) userFromClaims(claims jwt.MapClaims) *User {
+	return &User{
+		Subject:  getStringClaim(claims, m.SubjectClaim()),
+		Username: getStringClaim(claims, m.UsernameClaim()),
+		Email:    getStringClaim(claims, m.EmailClaim()),
+		Issuer:   getStringClaim(claims, "iss"),
+		Claims:   claims,
+	}
+}
@@ -0,0 +1,100 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// MultiIssuerValidator validates tokens from several trusted OIDC issuers.
+// Tokens are routed to the issuer's verifier by their (unverified) "iss"
+// claim, which must be on the configured allowlist. The signature, audience
+// and expiry are then verified by that issuer's OIDCValidator.
+//
+// Trusted issuers are Config.Issuer plus every entry in Config.Issuers.
+type MultiIssuerValidator struct {
+	validators map[string]*OIDCValidator
+	logger     Logger
+}
+
+// Initialize performs OIDC discovery for every trusted issuer
+func (v *MultiIssuerValidator) Initialize(cfg *Config) error {
+	v.logger = cfg.Logger
+	if v.logger == nil {
+		v.logger = &noOpLogger{}
+	}
+
+	issuers := cfg.Issuers
+	if cfg.Issuer != "" {
+		issuers = append([]IssuerConfig{{Issuer: cfg.Issuer}}, issuers...)
+	}
+	if len(issuers) == 0 {
+		return fmt.Errorf("at least one OIDC issuer is required for multi-issuer validation")
+	}
+
+	v.validators = make(map[string]*OIDCValidator, len(issuers))
+	for _, issuer := range issuers {
+		if issuer.Issuer == "" {
+			return fmt.Errorf("issuer URL is required for each trusted issuer")
+		}
+		if _, exists := v.validators[issuer.Issuer]; exists {
+			return fmt.Errorf("duplicate trusted issuer: %s", issuer.Issuer)
+		}
+
+		// Copy the shared configuration and narrow it to this issuer
+		issuerCfg := *cfg
+		issuerCfg.Issuer = issuer.Issuer
+		issuerCfg.Issuers = nil
+		if len(issuer.Audiences) > 0 {
+			issuerCfg.Audience = issuer.Audiences[0]
+			issuerCfg.Audiences = issuer.Audiences[1:]
+		}
+		if issuer.ClaimMapping != nil {
+			issuerCfg.ClaimMapping = issuer.ClaimMapping
+		}
+
+		validator := &OIDCValidator{}
+		if err := validator.Initialize(&issuerCfg); err != nil {
+			return fmt.Errorf("issuer %s: %w", issuer.Issuer, err)
+		}
+		v.validators[issuer.Issuer] = validator
+	}
+
+	v.logger.Info("OAuth: Multi-issuer validator initialized with %d trusted issuers", len(v.validators))
+	return nil
+}
+
+// ValidateToken routes the token to the verifier of its issuer
+func (v *MultiIssuerValidator) ValidateToken(ctx context.Context, tokenString string) (*User, error) {
+	tokenString = strings.TrimPrefix(tokenString, "Bearer ")
+
+	issuer, err := unverifiedIssuer(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	validator, ok := v.validators[issuer]
+	if !ok {
+		v.logger.Warn("SECURITY: Rejecting token from untrusted issuer: %s", issuer)
+		return nil, fmt.Errorf("untrusted issuer: %s", issuer)
+	}
+
+	return validator.ValidateToken(ctx, tokenString)
+}
+
+// unverifiedIssuer extracts the "iss" claim without verifying the signature.
+// The result must only be used to select a verifier.
+func unverifiedIssuer(tokenString string) (string, error) {
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(tokenString, claims); err != nil {
+		return "", fmt.Errorf("failed to parse token: %w", err)
+	}
+
+	issuer := getStringClaim(claims, "iss")
+	if issuer == "" {
+		return "", fmt.Errorf("missing issuer claim")
+	}
+	return issuer, nil
+}
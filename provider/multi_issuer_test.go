@@ -0,0 +1,158 @@
+package provider
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// TestMultiIssuerValidator tests routing tokens to the verifier of their issuer
+func TestMultiIssuerValidator(t *testing.T) {
+	oldOrg := newTestOIDCServer(t)
+	newOrg := newTestOIDCServer(t)
+	untrusted := newTestOIDCServer(t)
+
+	validator := &MultiIssuerValidator{}
+	err := validator.Initialize(&Config{
+		Provider:  "okta",
+		Issuer:    oldOrg.URL,
+		Audience:  "api://new",
+		Audiences: []string{"api://legacy"},
+		Issuers: []IssuerConfig{{
+			Issuer:       newOrg.URL,
+			ClaimMapping: &ClaimMapping{Username: "upn"},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to initialize validator: %v", err)
+	}
+
+	claimsFor := func(issuer, audience string) jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":                issuer,
+			"sub":                "user-123",
+			"aud":                audience,
+			"preferred_username": "preferred",
+			"upn":                "user@new.example.com",
+			"exp":                time.Now().Add(time.Hour).Unix(),
+			"iat":                time.Now().Unix(),
+		}
+	}
+
+	t.Run("PrimaryIssuer", func(t *testing.T) {
+		token := oldOrg.sign(t, claimsFor(oldOrg.URL, "api://new"))
+
+		user, err := validator.ValidateToken(context.Background(), token)
+		if err != nil {
+			t.Fatalf("Expected token to pass, got error: %v", err)
+		}
+		if user.Username != "preferred" || user.Issuer != oldOrg.URL {
+			t.Errorf("Unexpected user: %+v", user)
+		}
+	})
+
+	t.Run("LegacyAudience", func(t *testing.T) {
+		token := oldOrg.sign(t, claimsFor(oldOrg.URL, "api://legacy"))
+
+		if _, err := validator.ValidateToken(context.Background(), token); err != nil {
+			t.Errorf("Expected legacy audience to pass, got error: %v", err)
+		}
+	})
+
+	t.Run("UnknownAudience", func(t *testing.T) {
+		token := oldOrg.sign(t, claimsFor(oldOrg.URL, "api://other"))
+
+		_, err := validator.ValidateToken(context.Background(), token)
+		if err == nil || !strings.Contains(err.Error(), "audience validation failed") {
+			t.Errorf("Expected audience validation failure, got: %v", err)
+		}
+	})
+
+	t.Run("SecondIssuerUsesOwnClaimMapping", func(t *testing.T) {
+		token := newOrg.sign(t, claimsFor(newOrg.URL, "api://new"))
+
+		user, err := validator.ValidateToken(context.Background(), token)
+		if err != nil {
+			t.Fatalf("Expected token to pass, got error: %v", err)
+		}
+		if user.Username != "user@new.example.com" {
+			t.Errorf("Expected username from upn claim, got %q", user.Username)
+		}
+	})
+
+	t.Run("UntrustedIssuer", func(t *testing.T) {
+		token := untrusted.sign(t, claimsFor(untrusted.URL, "api://new"))
+
+		_, err := validator.ValidateToken(context.Background(), token)
+		if err == nil || !strings.Contains(err.Error(), "untrusted issuer") {
+			t.Errorf("Expected untrusted issuer error, got: %v", err)
+		}
+	})
+
+	t.Run("IssuerClaimSpoofed", func(t *testing.T) {
+		// Signed by the untrusted key but claiming a trusted issuer
+		token := untrusted.sign(t, claimsFor(oldOrg.URL, "api://new"))
+
+		if _, err := validator.ValidateToken(context.Background(), token); err == nil {
+			t.Error("Expected token signed by untrusted key to fail")
+		}
+	})
+
+	t.Run("MissingIssuer", func(t *testing.T) {
+		claims := claimsFor(oldOrg.URL, "api://new")
+		delete(claims, "iss")
+		token := oldOrg.sign(t, claims)
+
+		_, err := validator.ValidateToken(context.Background(), token)
+		if err == nil || err.Error() != "missing issuer claim" {
+			t.Errorf("Expected missing issuer error, got: %v", err)
+		}
+	})
+}
+
+// TestMultiIssuerValidator_InitializationValidation tests multi-issuer configuration errors
+func TestMultiIssuerValidator_InitializationValidation(t *testing.T) {
+	server := newTestOIDCServer(t)
+
+	tests := []struct {
+		name     string
+		config   *Config
+		errorMsg string
+	}{
+		{
+			name:     "no issuers",
+			config:   &Config{Audience: "api://test"},
+			errorMsg: "at least one OIDC issuer is required for multi-issuer validation",
+		},
+		{
+			name: "empty issuer entry",
+			config: &Config{
+				Issuer:   server.URL,
+				Audience: "api://test",
+				Issuers:  []IssuerConfig{{}},
+			},
+			errorMsg: "issuer URL is required for each trusted issuer",
+		},
+		{
+			name: "duplicate issuer",
+			config: &Config{
+				Issuer:   server.URL,
+				Audience: "api://test",
+				Issuers:  []IssuerConfig{{Issuer: server.URL}},
+			},
+			errorMsg: "duplicate trusted issuer: " + server.URL,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := (&MultiIssuerValidator{}).Initialize(tt.config)
+			if err == nil || err.Error() != tt.errorMsg {
+				t.Errorf("Expected error '%s', got '%v'", tt.errorMsg, err)
+			}
+		})
+	}
+}
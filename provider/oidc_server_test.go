@@ -0,0 +1,127 @@
+package provider
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// testOIDCServer is a minimal OIDC issuer serving discovery and JWKS documents
+type testOIDCServer struct {
+	*httptest.Server
+	alg    string
+	method jwt.SigningMethod
+	key    crypto.Signer
+	kid    string
+}
+
+// newTestOIDCServer starts an OIDC issuer signing tokens with a fresh RSA key
+func newTestOIDCServer(t *testing.T) *testOIDCServer {
+	t.Helper()
+	return newTestOIDCServerWithAlg(t, "RS256")
+}
+
+// newTestOIDCServerWithAlg starts an OIDC issuer signing tokens with alg
+// (RS256, PS256, ES256 or EdDSA)
+func newTestOIDCServerWithAlg(t *testing.T, alg string) *testOIDCServer {
+	t.Helper()
+
+	s := &testOIDCServer{alg: alg, method: jwt.GetSigningMethod(alg), kid: "test-key-" + alg}
+
+	var jwk map[string]string
+	switch alg {
+	case "RS256", "PS256":
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatalf("Failed to generate RSA key: %v", err)
+		}
+		s.key = key
+		jwk = map[string]string{
+			"kty": "RSA",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}
+	case "ES256":
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatalf("Failed to generate EC key: %v", err)
+		}
+		s.key = key
+		jwk = map[string]string{
+			"kty": "EC",
+			"crv": "P-256",
+			"x":   base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+			"y":   base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+		}
+	case "EdDSA":
+		public, private, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatalf("Failed to generate Ed25519 key: %v", err)
+		}
+		s.key = private
+		jwk = map[string]string{
+			"kty": "OKP",
+			"crv": "Ed25519",
+			"x":   base64.RawURLEncoding.EncodeToString(public),
+		}
+	default:
+		t.Fatalf("Unsupported test signing algorithm: %s", alg)
+	}
+	jwk["kid"] = s.kid
+	jwk["use"] = "sig"
+	jwk["alg"] = alg
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                                s.URL,
+			"authorization_endpoint":                s.URL + "/authorize",
+			"token_endpoint":                        s.URL + "/token",
+			"jwks_uri":                              s.URL + "/keys",
+			"id_token_signing_alg_values_supported": []string{alg},
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{jwk},
+		})
+	})
+
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+	return s
+}
+
+// sign issues a token with the given claims
+func (s *testOIDCServer) sign(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+	return s.signTyped(t, "JWT", claims)
+}
+
+// signTyped issues a token with the given "typ" header and claims
+func (s *testOIDCServer) signTyped(t *testing.T, typ string, claims jwt.MapClaims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(s.method, claims)
+	token.Header["kid"] = s.kid
+	token.Header["typ"] = typ
+
+	tokenString, err := token.SignedString(s.key)
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+	return tokenString
+}
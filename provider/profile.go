@@ -0,0 +1,80 @@
+package provider
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ProfileRFC9068 enforces the JWT Profile for OAuth 2.0 Access Tokens (RFC 9068)
+const ProfileRFC9068 = "rfc9068"
+
+// rfc9068RequiredClaims are the claims every RFC 9068 access token must carry
+var rfc9068RequiredClaims = []string{"iss", "exp", "aud", "sub", "iat", "jti", "client_id", "scope"}
+
+// idTokenOnlyClaims only appear in OpenID Connect ID tokens, never in access tokens
+var idTokenOnlyClaims = []string{"nonce", "at_hash", "c_hash"}
+
+// ProfileError reports which access token profile requirement a token failed
+type ProfileError struct {
+	Profile     string // e.g. "rfc9068"
+	Requirement string // Human-readable description of the failed requirement
+}
+
+// Error implements the error interface
+func (e *ProfileError) Error() string {
+	return fmt.Sprintf("%s access token profile violation: %s", e.Profile, e.Requirement)
+}
+
+// validateAccessTokenProfile checks a verified token against the configured
+// access token profile. An empty profile accepts any JWT.
+func validateAccessTokenProfile(profile string, header map[string]interface{}, claims jwt.MapClaims) error {
+	switch profile {
+	case "":
+		return nil
+	case ProfileRFC9068:
+		return validateRFC9068(header, claims)
+	default:
+		return fmt.Errorf("unknown access token profile: %s", profile)
+	}
+}
+
+// validateRFC9068 implements the checks of RFC 9068 Section 4
+func validateRFC9068(header map[string]interface{}, claims jwt.MapClaims) error {
+	rawTyp, _ := header["typ"].(string)
+	typ := strings.TrimPrefix(strings.ToLower(rawTyp), "application/")
+
+	if typ != "at+jwt" {
+		// Explain the common mistake of sending an ID token explicitly
+		for _, claim := range idTokenOnlyClaims {
+			if _, ok := claims[claim]; ok {
+				return &ProfileError{
+					Profile:     ProfileRFC9068,
+					Requirement: fmt.Sprintf("ID tokens are not accepted as access tokens (found %q claim)", claim),
+				}
+			}
+		}
+		requirement := fmt.Sprintf(`header "typ" must be "at+jwt", got %q`, rawTyp)
+		if rawTyp == "" {
+			requirement = `missing header "typ" (must be "at+jwt")`
+		}
+		return &ProfileError{Profile: ProfileRFC9068, Requirement: requirement}
+	}
+
+	for _, claim := range rfc9068RequiredClaims {
+		if value, ok := claims[claim]; !ok || value == "" {
+			return &ProfileError{
+				Profile:     ProfileRFC9068,
+				Requirement: fmt.Sprintf("missing required %q claim", claim),
+			}
+		}
+	}
+
+	return nil
+}
+
+// validProfile reports whether profile is a supported access token profile
+func validProfile(profile string) bool {
+	return profile == "" || profile == ProfileRFC9068
+}
@@ -0,0 +1,189 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// accessTokenClaims returns claims satisfying RFC 9068
+func accessTokenClaims(issuer, audience string) jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":       issuer,
+		"sub":       "user-123",
+		"aud":       audience,
+		"exp":       time.Now().Add(time.Hour).Unix(),
+		"iat":       time.Now().Unix(),
+		"jti":       "token-id-1",
+		"client_id": "mcp-client",
+		"scope":     "mcp:tools.read",
+	}
+}
+
+// TestValidateRFC9068 tests the RFC 9068 profile requirements
+func TestValidateRFC9068(t *testing.T) {
+	tests := []struct {
+		name        string
+		typ         interface{}
+		mutate      func(jwt.MapClaims)
+		requirement string
+	}{
+		{name: "valid access token", typ: "at+jwt"},
+		{name: "media type form", typ: "application/at+JWT"},
+		{
+			name:        "plain JWT type",
+			typ:         "JWT",
+			requirement: `header "typ" must be "at+jwt", got "JWT"`,
+		},
+		{
+			name:        "missing type",
+			requirement: `missing header "typ" (must be "at+jwt")`,
+		},
+		{
+			name:        "ID token",
+			typ:         "JWT",
+			mutate:      func(c jwt.MapClaims) { c["nonce"] = "n-0S6_WzA2Mj" },
+			requirement: `ID tokens are not accepted as access tokens (found "nonce" claim)`,
+		},
+		{
+			name:        "missing client_id",
+			typ:         "at+jwt",
+			mutate:      func(c jwt.MapClaims) { delete(c, "client_id") },
+			requirement: `missing required "client_id" claim`,
+		},
+		{
+			name:        "missing scope",
+			typ:         "at+jwt",
+			mutate:      func(c jwt.MapClaims) { delete(c, "scope") },
+			requirement: `missing required "scope" claim`,
+		},
+		{
+			name:        "empty jti",
+			typ:         "at+jwt",
+			mutate:      func(c jwt.MapClaims) { c["jti"] = "" },
+			requirement: `missing required "jti" claim`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := accessTokenClaims("https://issuer.example.com", "api://test")
+			if tt.mutate != nil {
+				tt.mutate(claims)
+			}
+			header := map[string]interface{}{"alg": "RS256"}
+			if tt.typ != nil {
+				header["typ"] = tt.typ
+			}
+
+			err := validateAccessTokenProfile(ProfileRFC9068, header, claims)
+			if tt.requirement == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+
+			var profileErr *ProfileError
+			if !errors.As(err, &profileErr) {
+				t.Fatalf("Expected ProfileError, got: %v", err)
+			}
+			if profileErr.Requirement != tt.requirement {
+				t.Errorf("Expected requirement '%s', got '%s'", tt.requirement, profileErr.Requirement)
+			}
+		})
+	}
+}
+
+// TestOIDCValidator_AccessTokenProfile tests RFC 9068 enforcement with a real OIDC verifier
+func TestOIDCValidator_AccessTokenProfile(t *testing.T) {
+	server := newTestOIDCServer(t)
+
+	validator := &OIDCValidator{}
+	err := validator.Initialize(&Config{
+		Issuer:             server.URL,
+		Audience:           "api://test",
+		AccessTokenProfile: ProfileRFC9068,
+	})
+	if err != nil {
+		t.Fatalf("Failed to initialize validator: %v", err)
+	}
+
+	t.Run("AccessTokenAccepted", func(t *testing.T) {
+		token := server.signTyped(t, "at+jwt", accessTokenClaims(server.URL, "api://test"))
+
+		if _, err := validator.ValidateToken(context.Background(), token); err != nil {
+			t.Errorf("Expected access token to pass, got error: %v", err)
+		}
+	})
+
+	t.Run("IDTokenRejected", func(t *testing.T) {
+		claims := accessTokenClaims(server.URL, "api://test")
+		claims["nonce"] = "abc"
+		token := server.sign(t, claims)
+
+		_, err := validator.ValidateToken(context.Background(), token)
+		if err == nil || !strings.Contains(err.Error(), "ID tokens are not accepted") {
+			t.Errorf("Expected ID token rejection, got: %v", err)
+		}
+	})
+
+	t.Run("ProfileDisabledAcceptsAnyJWT", func(t *testing.T) {
+		plain := &OIDCValidator{}
+		if err := plain.Initialize(&Config{Issuer: server.URL, Audience: "api://test"}); err != nil {
+			t.Fatalf("Failed to initialize validator: %v", err)
+		}
+
+		token := server.sign(t, jwt.MapClaims{
+			"iss": server.URL,
+			"sub": "user-123",
+			"aud": "api://test",
+			"exp": time.Now().Add(time.Hour).Unix(),
+		})
+		if _, err := plain.ValidateToken(context.Background(), token); err != nil {
+			t.Errorf("Expected plain JWT to pass without profile, got error: %v", err)
+		}
+	})
+}
+
+// TestHMACValidator_AccessTokenProfile tests RFC 9068 enforcement for HMAC tokens
+func TestHMACValidator_AccessTokenProfile(t *testing.T) {
+	cfg := &Config{
+		JWTSecret:          []byte("test-secret-key-for-hmac-validation"),
+		Audience:           "api://test",
+		AccessTokenProfile: ProfileRFC9068,
+	}
+
+	validator := &HMACValidator{}
+	if err := validator.Initialize(cfg); err != nil {
+		t.Fatalf("Failed to initialize validator: %v", err)
+	}
+
+	sign := func(typ string) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, accessTokenClaims("https://issuer.example.com", "api://test"))
+		token.Header["typ"] = typ
+		tokenString, err := token.SignedString(cfg.JWTSecret)
+		if err != nil {
+			t.Fatalf("Failed to sign token: %v", err)
+		}
+		return tokenString
+	}
+
+	if _, err := validator.ValidateToken(context.Background(), sign("at+jwt")); err != nil {
+		t.Errorf("Expected access token to pass, got error: %v", err)
+	}
+
+	_, err := validator.ValidateToken(context.Background(), sign("JWT"))
+	var profileErr *ProfileError
+	if !errors.As(err, &profileErr) {
+		t.Errorf("Expected ProfileError for plain JWT, got: %v", err)
+	}
+
+	if err := (&HMACValidator{}).Initialize(&Config{JWTSecret: []byte("s"), Audience: "a", AccessTokenProfile: "bogus"}); err == nil {
+		t.Error("Expected unknown profile to fail initialization")
+	}
+}
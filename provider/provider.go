@@ -5,6 +5,7 @@ import (
 	"crypto/tls"
 	"fmt"
 	"net/http"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -18,6 +19,11 @@ type User struct {
 	Username string
 	Email    string
 	Subject  string
+
+	// Issuer is the "iss" claim of the validated token
+	Issuer string
+	// Claims holds all claims of the validated token for advanced use cases
+	Claims map[string]interface{}
 }
 
 // Logger interface for pluggable logging
@@ -35,6 +41,25 @@ type Config struct {
 	Audience  string
 	JWTSecret []byte
 	Logger    Logger
+
+	// Audiences lists additional accepted audiences besides Audience
+	Audiences []string
+	// Issuers lists additional trusted OIDC issuers (see MultiIssuerValidator)
+	Issuers []IssuerConfig
+	// ClaimMapping controls which claims populate User fields (nil uses defaults)
+	ClaimMapping *ClaimMapping
+
+	// AccessTokenProfile enforces a JWT access token profile ("" or ProfileRFC9068)
+	AccessTokenProfile string
+	// AllowedAlgorithms restricts accepted signing algorithms (nil uses DefaultAlgorithms)
+	AllowedAlgorithms []string
+
+	// Leeway is the clock skew tolerated when checking exp, nbf and iat
+	Leeway time.Duration
+	// Clock returns the current time (nil uses time.Now). Intended for tests.
+	Clock func() time.Time
+	// ClaimPolicy configures required claims (exp, jti, maximum age)
+	ClaimPolicy ClaimPolicy
 }
 
 // TokenValidator interface for OAuth token validation
@@ -45,17 +70,26 @@ type TokenValidator interface {
 
 // HMACValidator validates JWT tokens using HMAC-SHA256 (backward compatibility)
 type HMACValidator struct {
-	secret     string
-	audience   string
-	secretOnce sync.Once
+	secret       string
+	audience     string
+	audiences    []string
+	claimMapping *ClaimMapping
+	profile      string
+	algorithms   []string
+	claims       *claimValidator
+	secretOnce   sync.Once
 }
 
 // OIDCValidator validates JWT tokens using OIDC/JWKS (Okta, Google, Azure)
 type OIDCValidator struct {
-	verifier *oidc.IDTokenVerifier
-	provider *oidc.Provider
-	audience string
-	logger   Logger
+	verifier     *oidc.IDTokenVerifier
+	provider     *oidc.Provider
+	audience     string
+	audiences    []string
+	claimMapping *ClaimMapping
+	profile      string
+	claims       *claimValidator
+	logger       Logger
 }
 
 // Initialize sets up the HMAC validator with JWT secret and audience
@@ -63,6 +97,10 @@ func (v *HMACValidator) Initialize(cfg *Config) error {
 	v.secretOnce.Do(func() {
 		v.secret = string(cfg.JWTSecret)
 		v.audience = cfg.Audience
+		v.audiences = cfg.Audiences
+		v.claimMapping = cfg.ClaimMapping
+		v.profile = cfg.AccessTokenProfile
+		v.claims = newClaimValidator(cfg)
 	})
 
 	if v.secret == "" {
@@ -73,10 +111,20 @@ func (v *HMACValidator) Initialize(cfg *Config) error {
 		return fmt.Errorf("JWT audience is required for HMAC provider")
 	}
 
+	if !validProfile(v.profile) {
+		return fmt.Errorf("unknown access token profile: %s", v.profile)
+	}
+
+	algorithms, err := allowedAlgorithms("hmac", cfg.AllowedAlgorithms)
+	if err != nil {
+		return err
+	}
+	v.algorithms = algorithms
+
 	return nil
 }
 
-// ValidateToken validates JWT token using HMAC-SHA256
+// ValidateToken validates JWT token using HMAC (HS256 unless configured otherwise)
 func (v *HMACValidator) ValidateToken(ctx context.Context, tokenString string) (*User, error) {
 	// Note: ctx parameter accepted for interface compliance, but HMAC validation is local-only (no I/O)
 	// Remove Bearer prefix if present
@@ -89,7 +137,7 @@ func (v *HMACValidator) ValidateToken(ctx context.Context, tokenString string) (
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
 		return []byte(v.secret), nil
-	})
+	}, jwt.WithValidMethods(v.algorithms), jwt.WithoutClaimsValidation()) // Time claims are checked by v.claims with leeway
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse and validate token: %w", err)
@@ -104,8 +152,8 @@ func (v *HMACValidator) ValidateToken(ctx context.Context, tokenString string) (
 		return nil, fmt.Errorf("invalid token claims")
 	}
 
-	// Validate required claims including audience
-	if err := validateTokenClaims(claims); err != nil {
+	// Validate time-based claims and the required-claims policy
+	if err := v.claims.validate(claims); err != nil {
 		return nil, fmt.Errorf("token validation failed: %w", err)
 	}
 
@@ -114,13 +162,14 @@ func (v *HMACValidator) ValidateToken(ctx context.Context, tokenString string) (
 		return nil, fmt.Errorf("audience validation failed: %w", err)
 	}
 
-	// Extract user information
-	user := &User{
-		Subject:  getStringClaim(claims, "sub"),
-		Username: getStringClaim(claims, "preferred_username"),
-		Email:    getStringClaim(claims, "email"),
+	// Enforce the configured access token profile
+	if err := validateAccessTokenProfile(v.profile, token.Header, claims); err != nil {
+		return nil, err
 	}
 
+	// Extract user information
+	user := v.claimMapping.userFromClaims(claims)
+
 	if user.Subject == "" {
 		return nil, fmt.Errorf("missing subject in token")
 	}
@@ -130,31 +179,7 @@ func (v *HMACValidator) ValidateToken(ctx context.Context, tokenString string) (
 
 // validateAudience validates the audience claim matches the expected value
 func (v *HMACValidator) validateAudience(claims jwt.MapClaims) error {
-	// Extract audience claim (can be string or []string)
-	audClaim, exists := claims["aud"]
-	if !exists {
-		return fmt.Errorf("missing audience claim")
-	}
-
-	// Handle string audience
-	if audStr, ok := audClaim.(string); ok {
-		if audStr != v.audience {
-			return fmt.Errorf("invalid audience: expected %s, got %s", v.audience, audStr)
-		}
-		return nil
-	}
-
-	// Handle array of audiences
-	if audArray, ok := audClaim.([]interface{}); ok {
-		for _, aud := range audArray {
-			if audStr, ok := aud.(string); ok && audStr == v.audience {
-				return nil
-			}
-		}
-		return fmt.Errorf("invalid audience: expected %s not found in audience list", v.audience)
-	}
-
-	return fmt.Errorf("invalid audience claim type")
+	return validateAudienceClaim(claims, acceptedAudiences(v.audience, v.audiences))
 }
 
 // Initialize sets up the OIDC validator with provider discovery
@@ -165,12 +190,23 @@ func (v *OIDCValidator) Initialize(cfg *Config) error {
 	if cfg.Audience == "" {
 		return fmt.Errorf("OIDC audience is required for OIDC provider")
 	}
+	if !validProfile(cfg.AccessTokenProfile) {
+		return fmt.Errorf("unknown access token profile: %s", cfg.AccessTokenProfile)
+	}
+	algorithms, err := allowedAlgorithms(cfg.Provider, cfg.AllowedAlgorithms)
+	if err != nil {
+		return err
+	}
 
 	v.logger = cfg.Logger
 	if v.logger == nil {
 		v.logger = &noOpLogger{}
 	}
 	v.audience = cfg.Audience
+	v.audiences = cfg.Audiences
+	v.claimMapping = cfg.ClaimMapping
+	v.profile = cfg.AccessTokenProfile
+	v.claims = newClaimValidator(cfg)
 
 	// Use standard library context with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
@@ -200,16 +236,22 @@ func (v *OIDCValidator) Initialize(cfg *Config) error {
 		return fmt.Errorf("failed to initialize OIDC provider: %w", err)
 	}
 
+	// go-oidc only compares against a single ClientID, so with multiple accepted
+	// audiences the check is delegated to validateAudience
+	accepted := acceptedAudiences(cfg.Audience, cfg.Audiences)
+
 	// Configure token verifier with required validation settings
 	verifier := provider.Verifier(&oidc.Config{
 		ClientID:             cfg.Audience, // Note: go-oidc uses ClientID field for audience validation - see https://github.com/coreos/go-oidc/blob/v3/oidc/verify.go#L85
-		SupportedSigningAlgs: []string{oidc.RS256, oidc.ES256},
-		SkipClientIDCheck:    false, // Always validate if ClientID is provided
-		SkipExpiryCheck:      false, // Verify expiration
-		SkipIssuerCheck:      false, // Verify issuer
+		SupportedSigningAlgs: algorithms,
+		SkipClientIDCheck:    len(accepted) > 1, // Validate in go-oidc unless multiple audiences are accepted
+		SkipExpiryCheck:      true,              // Expiration is verified by v.claims with the configured leeway
+		SkipIssuerCheck:      false,             // Verify issuer
+		Now:                  v.claims.now,
 	})
 
-	v.logger.Info("OAuth: OIDC validator initialized with audience validation: %s", cfg.Audience)
+	v.logger.Info("OAuth: OIDC validator initialized for issuer %s with audience validation: %s (algorithms: %s)",
+		cfg.Issuer, strings.Join(accepted, ", "), strings.Join(algorithms, ", "))
 
 	v.provider = provider
 	v.verifier = verifier
@@ -231,45 +273,65 @@ func (v *OIDCValidator) ValidateToken(ctx context.Context, tokenString string) (
 		return nil, fmt.Errorf("token verification failed: %w", err)
 	}
 
-	// Extract claims from verified token
-	var claims struct {
-		Subject           string `json:"sub"`
-		PreferredUsername string `json:"preferred_username"`
-		Email             string `json:"email"`
-		EmailVerified     bool   `json:"email_verified,omitempty"`
-		Name              string `json:"name,omitempty"`
-		// Standard OIDC claims are validated by go-oidc:
-		// - iss (issuer)
-		// - aud (audience)
-		// - exp (expiration)
-		// - iat (issued at)
-		// - nbf (not before)
-	}
-
-	if err := idToken.Claims(&claims); err != nil {
-		return nil, fmt.Errorf("failed to extract claims: %w", err)
-	}
-
-	// Extract raw claims for audience validation
+	// Extract raw claims from verified token. go-oidc validates the signature,
+	// iss and, with a single accepted audience, aud. It skips exp and nbf
+	// (SkipExpiryCheck): v.claims validates exp, nbf and iat with the configured
+	// leeway and the required-claims policy, and validateAudience checks aud.
 	var rawClaims jwt.MapClaims
 	if err := idToken.Claims(&rawClaims); err != nil {
 		return nil, fmt.Errorf("failed to extract raw claims: %w", err)
 	}
 
+	if err := v.claims.validate(rawClaims); err != nil {
+		return nil, fmt.Errorf("token validation failed: %w", err)
+	}
+
 	// Validate audience claim for security (explicit check)
 	if err := v.validateAudience(rawClaims); err != nil {
 		return nil, fmt.Errorf("audience validation failed: %w", err)
 	}
 
-	return &User{
-		Subject:  claims.Subject,
-		Username: claims.PreferredUsername,
-		Email:    claims.Email,
-	}, nil
+	// Enforce the configured access token profile. go-oidc does not expose the
+	// JOSE header, so re-read it from the already verified token.
+	if v.profile != "" {
+		parsed, _, err := jwt.NewParser().ParseUnverified(tokenString, jwt.MapClaims{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse token header: %w", err)
+		}
+		if err := validateAccessTokenProfile(v.profile, parsed.Header, rawClaims); err != nil {
+			return nil, err
+		}
+	}
+
+	return v.claimMapping.userFromClaims(rawClaims), nil
 }
 
 // validateAudience validates the audience claim matches the expected value for OIDC tokens
 func (v *OIDCValidator) validateAudience(claims jwt.MapClaims) error {
+	return validateAudienceClaim(claims, acceptedAudiences(v.audience, v.audiences))
+}
+
+// acceptedAudiences combines the primary audience with additional audiences
+func acceptedAudiences(primary string, additional []string) []string {
+	accepted := make([]string, 0, len(additional)+1)
+	if primary != "" {
+		accepted = append(accepted, primary)
+	}
+	for _, aud := range additional {
+		if aud != "" && aud != primary {
+			accepted = append(accepted, aud)
+		}
+	}
+	return accepted
+}
+
+// validateAudienceClaim validates the audience claim contains one of the accepted values
+func validateAudienceClaim(claims jwt.MapClaims, accepted []string) error {
+	expected := strings.Join(accepted, ", ")
+	if len(accepted) > 1 {
+		expected = "one of [" + expected + "]"
+	}
+
 	// Extract audience claim (can be string or []string)
 	audClaim, exists := claims["aud"]
 	if !exists {
@@ -278,8 +340,8 @@ func (v *OIDCValidator) validateAudience(claims jwt.MapClaims) error {
 
 	// Handle string audience
 	if audStr, ok := audClaim.(string); ok {
-		if audStr != v.audience {
-			return fmt.Errorf("invalid audience: expected %s, got %s", v.audience, audStr)
+		if !slices.Contains(accepted, audStr) {
+			return fmt.Errorf("invalid audience: expected %s, got %s", expected, audStr)
 		}
 		return nil
 	}
@@ -287,48 +349,16 @@ func (v *OIDCValidator) validateAudience(claims jwt.MapClaims) error {
 	// Handle array of audiences
 	if audArray, ok := audClaim.([]interface{}); ok {
 		for _, aud := range audArray {
-			if audStr, ok := aud.(string); ok && audStr == v.audience {
+			if audStr, ok := aud.(string); ok && slices.Contains(accepted, audStr) {
 				return nil
 			}
 		}
-		return fmt.Errorf("invalid audience: expected %s not found in audience list", v.audience)
+		return fmt.Errorf("invalid audience: expected %s not found in audience list", expected)
 	}
 
 	return fmt.Errorf("invalid audience claim type")
 }
 
-// validateTokenClaims validates standard JWT claims
-func validateTokenClaims(claims jwt.MapClaims) error {
-	// Validate expiration
-	if exp, ok := claims["exp"]; ok {
-		if expTime, ok := exp.(float64); ok {
-			if time.Now().Unix() > int64(expTime) {
-				return fmt.Errorf("token expired")
-			}
-		}
-	}
-
-	// Validate not before
-	if nbf, ok := claims["nbf"]; ok {
-		if nbfTime, ok := nbf.(float64); ok {
-			if time.Now().Unix() < int64(nbfTime) {
-				return fmt.Errorf("token not yet valid")
-			}
-		}
-	}
-
-	// Validate issued at (should not be in the future)
-	if iat, ok := claims["iat"]; ok {
-		if iatTime, ok := iat.(float64); ok {
-			if time.Now().Unix() < int64(iatTime) {
-				return fmt.Errorf("token issued in the future")
-			}
-		}
-	}
-
-	return nil
-}
-
 // getStringClaim safely extracts a string claim
 func getStringClaim(claims jwt.MapClaims, key string) string {
 	if val, ok := claims[key].(string); ok {
@@ -144,6 +144,58 @@ func TestHMACValidator_AudienceValidation(t *testing.T) {
 	})
 }
 
+// TestHMACValidator_MultipleAudiences tests accepting additional audiences
+func TestHMACValidator_MultipleAudiences(t *testing.T) {
+	cfg := &Config{
+		JWTSecret: []byte("test-secret-key-for-hmac-validation"),
+		Audience:  "api://new",
+		Audiences: []string{"api://legacy"},
+	}
+
+	validator := &HMACValidator{}
+	if err := validator.Initialize(cfg); err != nil {
+		t.Fatalf("Failed to initialize validator: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		audience interface{}
+		errMsg   string
+	}{
+		{name: "primary audience", audience: "api://new"},
+		{name: "additional audience", audience: "api://legacy"},
+		{name: "additional audience in array", audience: []string{"other", "api://legacy"}},
+		{
+			name:     "unknown audience",
+			audience: "api://other",
+			errMsg:   "audience validation failed: invalid audience: expected one of [api://new, api://legacy], got api://other",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+				"sub": "test-user",
+				"aud": tt.audience,
+				"exp": time.Now().Add(time.Hour).Unix(),
+				"iat": time.Now().Unix(),
+			})
+			tokenString, err := token.SignedString(cfg.JWTSecret)
+			if err != nil {
+				t.Fatalf("Failed to sign token: %v", err)
+			}
+
+			_, err = validator.ValidateToken(context.Background(), tokenString)
+			if tt.errMsg == "" && err != nil {
+				t.Errorf("Expected token to pass, got error: %v", err)
+			}
+			if tt.errMsg != "" && (err == nil || err.Error() != tt.errMsg) {
+				t.Errorf("Expected error '%s', got '%v'", tt.errMsg, err)
+			}
+		})
+	}
+}
+
 // TestHMACValidator_InitializationValidation tests validator initialization
 func TestHMACValidator_InitializationValidation(t *testing.T) {
 	t.Run("MissingSecret", func(t *testing.T) {
@@ -0,0 +1,121 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ClaimPolicy configures which registered claims a token must carry.
+// The zero value requires "exp" and nothing else.
+type ClaimPolicy struct {
+	// AllowMissingExpiration accepts tokens without an "exp" claim.
+	// Such tokens never expire, so only enable this for legacy HMAC tokens.
+	AllowMissingExpiration bool
+	// MaxTokenAge rejects tokens issued longer ago than this duration,
+	// measured from the "iat" claim (which becomes mandatory). Zero disables the check.
+	MaxTokenAge time.Duration
+	// RequireJTI rejects tokens without a "jti" (token identifier) claim
+	RequireJTI bool
+}
+
+// claimValidator validates time-based claims and the required-claims policy
+// for both the HMAC and OIDC validators
+type claimValidator struct {
+	leeway time.Duration
+	now    func() time.Time
+	policy ClaimPolicy
+}
+
+// newClaimValidator creates a claimValidator from the provider configuration
+func newClaimValidator(cfg *Config) *claimValidator {
+	now := cfg.Clock
+	if now == nil {
+		now = time.Now
+	}
+	return &claimValidator{
+		leeway: cfg.Leeway,
+		now:    now,
+		policy: cfg.ClaimPolicy,
+	}
+}
+
+// validate checks exp, nbf and iat (with leeway) and the required claims
+func (c *claimValidator) validate(claims jwt.MapClaims) error {
+	now := c.now()
+
+	// Validate expiration
+	exp, hasExp, err := numericClaim(claims, "exp")
+	if err != nil {
+		return err
+	}
+	if !hasExp && !c.policy.AllowMissingExpiration {
+		return fmt.Errorf("missing expiration claim")
+	}
+	if hasExp && now.After(exp.Add(c.leeway)) {
+		return fmt.Errorf("token expired")
+	}
+
+	// Validate not before
+	nbf, hasNbf, err := numericClaim(claims, "nbf")
+	if err != nil {
+		return err
+	}
+	if hasNbf && now.Add(c.leeway).Before(nbf) {
+		return fmt.Errorf("token not yet valid")
+	}
+
+	// Validate issued at (should not be in the future)
+	iat, hasIat, err := numericClaim(claims, "iat")
+	if err != nil {
+		return err
+	}
+	if hasIat && now.Add(c.leeway).Before(iat) {
+		return fmt.Errorf("token issued in the future")
+	}
+
+	// Validate maximum token age
+	if c.policy.MaxTokenAge > 0 {
+		if !hasIat {
+			return fmt.Errorf("missing issued at claim")
+		}
+		if now.Sub(iat) > c.policy.MaxTokenAge+c.leeway {
+			return fmt.Errorf("token exceeds maximum age of %s", c.policy.MaxTokenAge)
+		}
+	}
+
+	// Validate token identifier
+	if c.policy.RequireJTI && getStringClaim(claims, "jti") == "" {
+		return fmt.Errorf("missing jti claim")
+	}
+
+	return nil
+}
+
+// numericClaim extracts a NumericDate claim. Returns false if the claim is absent.
+func numericClaim(claims jwt.MapClaims, key string) (time.Time, bool, error) {
+	raw, ok := claims[key]
+	if !ok {
+		return time.Time{}, false, nil
+	}
+
+	var seconds float64
+	switch value := raw.(type) {
+	case float64:
+		seconds = value
+	case int64:
+		seconds = float64(value)
+	case json.Number:
+		f, err := value.Float64()
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("invalid %s claim", key)
+		}
+		seconds = f
+	default:
+		return time.Time{}, false, fmt.Errorf("invalid %s claim", key)
+	}
+
+	return time.Unix(int64(seconds), 0), true, nil
+}
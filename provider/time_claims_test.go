@@ -0,0 +1,175 @@
+package provider
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// TestClaimValidator tests leeway handling and the required-claims policy
+func TestClaimValidator(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	at := func(offset time.Duration) float64 { return float64(now.Add(offset).Unix()) }
+
+	tests := []struct {
+		name   string
+		cfg    Config
+		claims jwt.MapClaims
+		errMsg string
+	}{
+		{
+			name:   "valid token",
+			claims: jwt.MapClaims{"exp": at(time.Hour), "iat": at(-time.Minute)},
+		},
+		{
+			name:   "missing exp rejected by default",
+			claims: jwt.MapClaims{"iat": at(-time.Minute)},
+			errMsg: "missing expiration claim",
+		},
+		{
+			name:   "missing exp allowed by policy",
+			cfg:    Config{ClaimPolicy: ClaimPolicy{AllowMissingExpiration: true}},
+			claims: jwt.MapClaims{"iat": at(-time.Minute)},
+		},
+		{
+			name:   "expired without leeway",
+			claims: jwt.MapClaims{"exp": at(-10 * time.Second)},
+			errMsg: "token expired",
+		},
+		{
+			name:   "expired within leeway",
+			cfg:    Config{Leeway: 30 * time.Second},
+			claims: jwt.MapClaims{"exp": at(-10 * time.Second)},
+		},
+		{
+			name:   "not yet valid",
+			claims: jwt.MapClaims{"exp": at(time.Hour), "nbf": at(10 * time.Second)},
+			errMsg: "token not yet valid",
+		},
+		{
+			name:   "not yet valid within leeway",
+			cfg:    Config{Leeway: 30 * time.Second},
+			claims: jwt.MapClaims{"exp": at(time.Hour), "nbf": at(10 * time.Second)},
+		},
+		{
+			name:   "issued in the future",
+			claims: jwt.MapClaims{"exp": at(time.Hour), "iat": at(10 * time.Second)},
+			errMsg: "token issued in the future",
+		},
+		{
+			name:   "maximum age exceeded",
+			cfg:    Config{ClaimPolicy: ClaimPolicy{MaxTokenAge: 10 * time.Minute}},
+			claims: jwt.MapClaims{"exp": at(time.Hour), "iat": at(-11 * time.Minute)},
+			errMsg: "token exceeds maximum age of 10m0s",
+		},
+		{
+			name:   "maximum age requires iat",
+			cfg:    Config{ClaimPolicy: ClaimPolicy{MaxTokenAge: 10 * time.Minute}},
+			claims: jwt.MapClaims{"exp": at(time.Hour)},
+			errMsg: "missing issued at claim",
+		},
+		{
+			name:   "jti required",
+			cfg:    Config{ClaimPolicy: ClaimPolicy{RequireJTI: true}},
+			claims: jwt.MapClaims{"exp": at(time.Hour)},
+			errMsg: "missing jti claim",
+		},
+		{
+			name:   "invalid exp type",
+			claims: jwt.MapClaims{"exp": "tomorrow"},
+			errMsg: "invalid exp claim",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			cfg.Clock = clock
+
+			err := newClaimValidator(&cfg).validate(tt.claims)
+			if tt.errMsg == "" && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			if tt.errMsg != "" && (err == nil || err.Error() != tt.errMsg) {
+				t.Errorf("Expected error '%s', got '%v'", tt.errMsg, err)
+			}
+		})
+	}
+}
+
+// TestValidators_ClaimPolicy tests that both validators apply leeway and policy
+func TestValidators_ClaimPolicy(t *testing.T) {
+	now := time.Now().Add(-24 * time.Hour)
+	clock := func() time.Time { return now }
+	server := newTestOIDCServer(t)
+
+	hmacCfg := &Config{
+		JWTSecret: []byte("test-secret-key-for-hmac-validation"),
+		Audience:  "api://test",
+		Clock:     clock,
+		Leeway:    time.Minute,
+	}
+	hmacValidator := &HMACValidator{}
+	if err := hmacValidator.Initialize(hmacCfg); err != nil {
+		t.Fatalf("Failed to initialize HMAC validator: %v", err)
+	}
+
+	oidcValidator := &OIDCValidator{}
+	if err := oidcValidator.Initialize(&Config{Issuer: server.URL, Audience: "api://test", Clock: clock, Leeway: time.Minute}); err != nil {
+		t.Fatalf("Failed to initialize OIDC validator: %v", err)
+	}
+
+	signHMAC := func(claims jwt.MapClaims) string {
+		tokenString, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(hmacCfg.JWTSecret)
+		if err != nil {
+			t.Fatalf("Failed to sign token: %v", err)
+		}
+		return tokenString
+	}
+
+	claims := func(exp time.Time) jwt.MapClaims {
+		return jwt.MapClaims{"iss": server.URL, "sub": "user-123", "aud": "api://test", "exp": exp.Unix()}
+	}
+
+	validators := []struct {
+		name      string
+		validator TokenValidator
+		sign      func(jwt.MapClaims) string
+	}{
+		{"HMAC", hmacValidator, signHMAC},
+		{"OIDC", oidcValidator, func(c jwt.MapClaims) string { return server.sign(t, c) }},
+	}
+
+	for _, v := range validators {
+		t.Run(v.name, func(t *testing.T) {
+			// Valid according to the injected clock, although expired in real time
+			if _, err := v.validator.ValidateToken(context.Background(), v.sign(claims(now.Add(-30*time.Second)))); err != nil {
+				t.Errorf("Expected token within leeway to pass, got error: %v", err)
+			}
+
+			if _, err := v.validator.ValidateToken(context.Background(), v.sign(claims(now.Add(-2*time.Minute)))); err == nil {
+				t.Error("Expected token expired beyond leeway to fail")
+			}
+
+			noExp := claims(now)
+			delete(noExp, "exp")
+			if _, err := v.validator.ValidateToken(context.Background(), v.sign(noExp)); err == nil {
+				t.Error("Expected token without exp to fail")
+			}
+
+			// nbf is checked against the injected clock with leeway
+			notYetValid := claims(now.Add(time.Hour))
+			notYetValid["nbf"] = now.Add(30 * time.Second).Unix()
+			if _, err := v.validator.ValidateToken(context.Background(), v.sign(notYetValid)); err != nil {
+				t.Errorf("Expected nbf within leeway to pass, got error: %v", err)
+			}
+			notYetValid["nbf"] = now.Add(2 * time.Minute).Unix()
+			if _, err := v.validator.ValidateToken(context.Background(), v.sign(notYetValid)); err == nil {
+				t.Error("Expected future nbf beyond leeway to fail")
+			}
+		})
+	}
+}
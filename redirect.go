@@ -0,0 +1,216 @@
+package oauth
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// defaultClientRedirectURIs are the client redirect URIs accepted in fixed
+// redirect mode when ClientRedirectURIs is not set: any loopback address,
+// port and path
+var defaultClientRedirectURIs = []string{
+	"http://localhost/*", "https://localhost/*",
+	"http://127.0.0.1/*", "https://127.0.0.1/*",
+	"http://[::1]/*", "https://[::1]/*",
+}
+
+// forbiddenRedirectSchemes can execute or read content in the user agent
+var forbiddenRedirectSchemes = []string{"javascript", "data", "vbscript", "file", "blob", "about"}
+
+// redirectPattern is a parsed redirect URI allowlist entry. Entries match
+// exactly, except that:
+//   - loopback hosts (localhost, 127.0.0.1, [::1]) match any port (RFC 8252 Section 7.3)
+//   - a path ending in "*" matches any path with that prefix
+//   - a host of the form "*.example.com" matches a single subdomain label (https only)
+//
+// Private-use URI schemes such as cursor:// (RFC 8252 Section 7.1) follow the same rules.
+type redirectPattern struct {
+	scheme     string
+	host       string
+	port       string
+	path       string
+	pathPrefix bool
+	rawQuery   string
+}
+
+// parseRedirectPattern parses a redirect URI allowlist entry
+func parseRedirectPattern(pattern string) (*redirectPattern, error) {
+	u, err := parseRedirectURI(strings.TrimSpace(pattern))
+	if err != nil {
+		return nil, fmt.Errorf("invalid redirect URI pattern %q: %w", pattern, err)
+	}
+
+	p := &redirectPattern{
+		scheme:   u.Scheme,
+		host:     strings.ToLower(u.Hostname()),
+		port:     u.Port(),
+		path:     u.Path,
+		rawQuery: u.RawQuery,
+	}
+	if strings.HasSuffix(p.path, "*") {
+		p.path = strings.TrimSuffix(p.path, "*")
+		p.pathPrefix = true
+	}
+	if strings.Contains(p.path, "*") {
+		return nil, fmt.Errorf("invalid redirect URI pattern %q: \"*\" is only allowed at the end of the path", pattern)
+	}
+
+	if (p.scheme == "http" || p.scheme == "https") && p.host == "" {
+		return nil, fmt.Errorf("invalid redirect URI pattern %q: missing host", pattern)
+	}
+	if strings.Contains(p.host, "*") {
+		suffix := strings.TrimPrefix(p.host, "*")
+		if p.scheme != "https" || !strings.HasPrefix(suffix, ".") || strings.Contains(suffix, "*") || strings.Count(suffix, ".") < 2 {
+			return nil, fmt.Errorf("invalid redirect URI pattern %q: wildcard hosts must be https and of the form *.example.com", pattern)
+		}
+	}
+	return p, nil
+}
+
+// matches reports whether the redirect URI u matches the pattern
+func (p *redirectPattern) matches(u *url.URL) bool {
+	if u.Scheme != p.scheme || u.RawQuery != p.rawQuery {
+		return false
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if suffix, ok := strings.CutPrefix(p.host, "*"); ok {
+		label, found := strings.CutSuffix(host, suffix)
+		if !found || label == "" || strings.Contains(label, ".") {
+			return false
+		}
+	} else if host != p.host {
+		return false
+	}
+
+	// RFC 8252 Section 7.3: native apps listen on an ephemeral loopback port
+	if u.Port() != p.port && !isLoopbackHost(p.host) {
+		return false
+	}
+
+	if p.pathPrefix {
+		return strings.HasPrefix(u.Path, p.path)
+	}
+	return u.Path == p.path
+}
+
+// parseRedirectURI parses a redirect URI and rejects URIs that are unsafe
+// regardless of the allowlist
+func parseRedirectURI(uri string) (*url.URL, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" {
+		return nil, fmt.Errorf("missing scheme")
+	}
+	if slices.Contains(forbiddenRedirectSchemes, u.Scheme) {
+		return nil, fmt.Errorf("scheme %q is not allowed", u.Scheme)
+	}
+	if u.Opaque != "" {
+		return nil, fmt.Errorf("opaque URIs are not allowed")
+	}
+	if u.User != nil {
+		return nil, fmt.Errorf("userinfo is not allowed")
+	}
+	// OAuth 2.0 spec: redirect URIs must not contain a fragment
+	if strings.Contains(uri, "#") {
+		return nil, fmt.Errorf("fragment is not allowed")
+	}
+	// Dot segments would escape a path prefix pattern
+	for _, segment := range strings.Split(u.Path, "/") {
+		if segment == "." || segment == ".." {
+			return nil, fmt.Errorf("dot segments are not allowed in the path")
+		}
+	}
+	return u, nil
+}
+
+// matchRedirectURI reports whether uri matches any of the redirect URI patterns.
+// Invalid patterns are skipped; Config.Validate rejects them up front.
+func matchRedirectURI(patterns []string, uri string) bool {
+	u, err := parseRedirectURI(uri)
+	if err != nil {
+		return false
+	}
+	for _, pattern := range patterns {
+		p, err := parseRedirectPattern(pattern)
+		if err != nil {
+			continue
+		}
+		if p.matches(u) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateRedirectPatterns checks redirect URI allowlist entries
+func validateRedirectPatterns(name string, patterns []string) error {
+	for _, pattern := range patterns {
+		if strings.TrimSpace(pattern) == "" {
+			continue
+		}
+		if _, err := parseRedirectPattern(pattern); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// isLoopbackHost reports whether host is localhost or a loopback IP literal
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// hasFixedRedirect reports whether the proxy uses a single fixed redirect URI
+// with the provider and proxies the callback to the client
+func (h *OAuth2Handler) hasFixedRedirect() bool {
+	return h.config.RedirectURIs != "" && !strings.Contains(h.config.RedirectURIs, ",")
+}
+
+// validateClientRedirectURI checks a client redirect URI in fixed redirect
+// mode against ClientRedirectURIs (default: any loopback URI)
+func (h *OAuth2Handler) validateClientRedirectURI(uri string) error {
+	parsedURI, err := url.Parse(uri)
+	if err != nil {
+		return fmt.Errorf("malformed redirect_uri")
+	}
+	if parsedURI.Fragment != "" {
+		return fmt.Errorf("redirect_uri must not contain fragment")
+	}
+
+	// Enforce HTTPS for non-localhost URIs
+	if parsedURI.Scheme == "http" && !isLocalhostURI(uri) {
+		return fmt.Errorf("HTTPS required for non-localhost redirect_uri")
+	}
+
+	patterns := h.config.ClientRedirectURIs
+	if len(patterns) == 0 {
+		// This prevents open redirect attacks while still supporting native and development tools
+		if !matchRedirectURI(defaultClientRedirectURIs, uri) {
+			return fmt.Errorf("fixed redirect mode only allows localhost redirect URIs; use allowlist mode or ClientRedirectURIs for production")
+		}
+		return nil
+	}
+	if !matchRedirectURI(patterns, uri) {
+		return fmt.Errorf("redirect_uri does not match ClientRedirectURIs")
+	}
+	return nil
+}
+
+// allowsRedirectURI reports whether a client may use uri as its redirect URI
+// in the configured redirect mode
+func (h *OAuth2Handler) allowsRedirectURI(uri string) bool {
+	if h.hasFixedRedirect() {
+		return h.validateClientRedirectURI(uri) == nil
+	}
+	return h.isValidRedirectURI(uri)
+}
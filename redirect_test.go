@@ -0,0 +1,188 @@
+package oauth
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// TestMatchRedirectURI tests RFC 8252 redirect URI patterns
+func TestMatchRedirectURI(t *testing.T) {
+	tests := []struct {
+		name     string
+		pattern  string
+		uri      string
+		expected bool
+	}{
+		{"exact match", "https://client.example.com/callback", "https://client.example.com/callback", true},
+		{"exact port mismatch", "https://client.example.com/callback", "https://client.example.com:8443/callback", false},
+		{"loopback IPv4 any port", "http://127.0.0.1/callback", "http://127.0.0.1:53124/callback", true},
+		{"loopback IPv6 any port", "http://[::1]:8080/callback", "http://[::1]:9000/callback", true},
+		{"localhost any port", "http://localhost/oauth/callback", "http://localhost:33418/oauth/callback", true},
+		{"loopback path mismatch", "http://127.0.0.1/callback", "http://127.0.0.1:53124/other", false},
+		{"loopback host mismatch", "http://127.0.0.1/callback", "http://localhost:53124/callback", false},
+		{"path prefix", "https://app.example.com/oauth/*", "https://app.example.com/oauth/done", true},
+		{"path prefix mismatch", "https://app.example.com/oauth/*", "https://app.example.com/other", false},
+		{"path prefix dot segments", "https://app.example.com/oauth/*", "https://app.example.com/oauth/../admin", false},
+		{"path prefix encoded dot segments", "https://app.example.com/oauth/*", "https://app.example.com/oauth/%2e%2e/admin", false},
+		{"wildcard host", "https://*.example.com/callback", "https://tenant.example.com/callback", true},
+		{"wildcard host nested label", "https://*.example.com/callback", "https://a.b.example.com/callback", false},
+		{"wildcard host apex", "https://*.example.com/callback", "https://example.com/callback", false},
+		{"wildcard host suffix attack", "https://*.example.com/callback", "https://evilexample.com/callback", false},
+		{"private-use scheme", "cursor://anysphere.cursor-retrieval/oauth/*", "cursor://anysphere.cursor-retrieval/oauth/callback", true},
+		{"private-use scheme mismatch", "cursor://anysphere.cursor-retrieval/oauth/*", "vscode://anysphere.cursor-retrieval/oauth/callback", false},
+		{"reverse domain scheme", "com.example.app:/oauth2redirect", "com.example.app:/oauth2redirect", true},
+		{"fragment rejected", "http://127.0.0.1/*", "http://127.0.0.1:8080/callback#frag", false},
+		{"userinfo rejected", "https://*.example.com/*", "https://user@tenant.example.com/callback", false},
+		{"query must match", "https://client.example.com/callback", "https://client.example.com/callback?x=1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := matchRedirectURI([]string{tt.pattern}, tt.uri); result != tt.expected {
+				t.Errorf("matchRedirectURI(%q, %q) = %v, expected %v", tt.pattern, tt.uri, result, tt.expected)
+			}
+		})
+	}
+}
+
+// TestRedirectPatternValidation tests configuration checks for redirect URI patterns
+func TestRedirectPatternValidation(t *testing.T) {
+	invalid := []string{
+		"javascript:alert(1)",
+		"https://*.com/callback",
+		"http://*.example.com/callback",
+		"https://*/callback",
+		"https://app.example.com/*/callback",
+		"https://app.example.com/callback#frag",
+		"/relative/callback",
+	}
+	for _, pattern := range invalid {
+		if _, err := parseRedirectPattern(pattern); err == nil {
+			t.Errorf("Expected pattern %q to be rejected", pattern)
+		}
+	}
+
+	base := Config{Mode: "proxy", Provider: "hmac", Audience: "api://test", JWTSecret: []byte("secret"), ClientID: "client", ServerURL: "https://mcp.example.com"}
+
+	cfg := base
+	cfg.RedirectURIs = "https://mcp.example.com/oauth/callback"
+	cfg.ClientRedirectURIs = []string{"http://127.0.0.1/*", "cursor://anysphere.cursor-retrieval/*"}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected valid ClientRedirectURIs, got %v", err)
+	}
+
+	cfg.ClientRedirectURIs = []string{"https://*.com/*"}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected invalid ClientRedirectURIs to be rejected")
+	}
+
+	cfg = base
+	cfg.RedirectURIs = "https://client.example.com/callback,http://127.0.0.1/callback"
+	cfg.ClientRedirectURIs = []string{"http://127.0.0.1/*"}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected ClientRedirectURIs to require fixed redirect mode")
+	}
+
+	cfg.ClientRedirectURIs = nil
+	cfg.RedirectURIs = "https://client.example.com/callback,javascript:alert(1)"
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected invalid allowlist entry to be rejected")
+	}
+}
+
+// TestRedirectMatching_Endpoints tests that /oauth/authorize, /oauth/register
+// and /oauth/callback apply the same redirect rules
+func TestRedirectMatching_Endpoints(t *testing.T) {
+	newHandler := func(redirectURIs string, clientRedirectURIs ...string) *OAuth2Handler {
+		return NewOAuth2Handler(&OAuth2Config{
+			Mode:               "proxy",
+			Provider:           "hmac",
+			Issuer:             "https://idp.example.com",
+			ClientID:           "client-id",
+			RedirectURIs:       redirectURIs,
+			ClientRedirectURIs: clientRedirectURIs,
+			MCPURL:             "https://mcp.example.com",
+			stateSigningKey:    []byte("test-state-signing-key"),
+		}, nil)
+	}
+	authorize := func(handler *OAuth2Handler, redirectURI string) *httptest.ResponseRecorder {
+		params := url.Values{"client_id": {"client"}, "redirect_uri": {redirectURI}, "state": {"client-state"}}
+		setTestCodeChallenge(params)
+		rec := httptest.NewRecorder()
+		handler.HandleAuthorize(rec, httptest.NewRequest(http.MethodGet, "/oauth/authorize?"+params.Encode(), nil))
+		return rec
+	}
+	register := func(handler *OAuth2Handler, redirectURIs ...string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]interface{}{"client_name": "test", "redirect_uris": redirectURIs})
+		rec := httptest.NewRecorder()
+		handler.HandleRegister(rec, httptest.NewRequest(http.MethodPost, "/oauth/register", bytes.NewReader(body)))
+		return rec
+	}
+
+	// Allowlist mode: a loopback entry accepts the ephemeral port picked by the client
+	allowlist := newHandler("https://client.example.com/callback,http://127.0.0.1/oauth/callback")
+	if rec := authorize(allowlist, "http://127.0.0.1:49152/oauth/callback"); rec.Code != http.StatusTemporaryRedirect {
+		t.Errorf("Expected loopback redirect with any port, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := authorize(allowlist, "http://127.0.0.1:49152/other"); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected loopback redirect with another path to be rejected, got %d", rec.Code)
+	}
+	if rec := register(allowlist, "http://127.0.0.1:49152/oauth/callback"); rec.Code != http.StatusCreated {
+		t.Errorf("Expected registration to be accepted, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec := register(allowlist, "https://evil.example.com/callback")
+	if rec.Code != http.StatusBadRequest || !bytes.Contains(rec.Body.Bytes(), []byte("invalid_redirect_uri")) {
+		t.Errorf("Expected invalid_redirect_uri, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// Fixed redirect mode: the default accepts loopback URIs only
+	fixed := newHandler("https://mcp.example.com/oauth/callback")
+	if rec := authorize(fixed, "http://localhost:33418/oauth/callback"); rec.Code != http.StatusTemporaryRedirect {
+		t.Errorf("Expected localhost redirect, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := authorize(fixed, "cursor://anysphere.cursor-retrieval/oauth/callback"); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected private-use scheme to be rejected by default, got %d", rec.Code)
+	}
+	if rec := register(fixed, "http://localhost:33418/oauth/callback", "https://evil.example.com/callback"); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected registration with a non-loopback URI to be rejected, got %d", rec.Code)
+	}
+
+	// Fixed redirect mode with ClientRedirectURIs: private-use schemes and query-preserving callbacks
+	cursor := newHandler("https://mcp.example.com/oauth/callback", "cursor://anysphere.cursor-retrieval/oauth/*")
+	clientRedirect := "cursor://anysphere.cursor-retrieval/oauth/callback"
+	rec = authorize(cursor, clientRedirect)
+	if rec.Code != http.StatusTemporaryRedirect {
+		t.Fatalf("Expected private-use scheme redirect, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := authorize(cursor, "http://localhost:33418/oauth/callback"); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected loopback redirect outside ClientRedirectURIs to be rejected, got %d", rec.Code)
+	}
+	if rec := register(cursor, clientRedirect); rec.Code != http.StatusCreated {
+		t.Errorf("Expected registration to be accepted, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	location, _ := url.Parse(rec.Header().Get("Location"))
+	callback := url.Values{"code": {"auth-code"}, "state": {location.Query().Get("state")}}
+	rec = httptest.NewRecorder()
+	cursor.HandleCallback(rec, httptest.NewRequest(http.MethodGet, "/oauth/callback?"+callback.Encode(), nil))
+	if rec.Code != http.StatusFound {
+		t.Fatalf("Expected callback redirect, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("Location"); got != clientRedirect+"?code=auth-code&state=client-state" {
+		t.Errorf("Unexpected callback redirect: %s", got)
+	}
+
+	// The callback re-checks the redirect URI against the current configuration
+	rec = authorize(cursor, clientRedirect)
+	location, _ = url.Parse(rec.Header().Get("Location"))
+	callback.Set("state", location.Query().Get("state"))
+	rec = httptest.NewRecorder()
+	fixed.HandleCallback(rec, httptest.NewRequest(http.MethodGet, "/oauth/callback?"+callback.Encode(), nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected callback to reject a redirect URI that is no longer allowed, got %d", rec.Code)
+	}
+}
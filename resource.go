@@ -0,0 +1,135 @@
+package oauth
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// validateResourceIndicator checks that a resource is an absolute URI without
+// a fragment (RFC 8707 Section 2)
+func validateResourceIndicator(resource string) error {
+	parsed, err := url.Parse(resource)
+	if err != nil {
+		return fmt.Errorf("invalid resource %q: %w", resource, err)
+	}
+	if !parsed.IsAbs() || parsed.Host == "" {
+		return fmt.Errorf("resource %q must be an absolute URI", resource)
+	}
+	if parsed.Fragment != "" {
+		return fmt.Errorf("resource %q must not contain a fragment", resource)
+	}
+	return nil
+}
+
+// normalizeResource canonicalizes a resource identifier for comparison
+func normalizeResource(resource string) string {
+	return strings.TrimSuffix(resource, "/")
+}
+
+// resourceIdentifiers returns the resource identifiers served by this server:
+// the server URL followed by the configured additional resources
+func resourceIdentifiers(serverURL string, resources []string) []string {
+	identifiers := make([]string, 0, len(resources)+1)
+	if serverURL != "" {
+		identifiers = append(identifiers, normalizeResource(serverURL))
+	}
+	for _, resource := range resources {
+		identifiers = append(identifiers, normalizeResource(resource))
+	}
+	return identifiers
+}
+
+// tokenAudiences returns the additional audiences accepted by the validators:
+// Config.Audiences plus, when Config.Resources is set, the resource
+// identifiers served by this server. Without Resources a single Audience
+// stays the only accepted audience.
+func tokenAudiences(cfg *Config) []string {
+	audiences := append([]string{}, cfg.Audiences...)
+	if len(cfg.Resources) == 0 {
+		return audiences
+	}
+	return append(audiences, resourceIdentifiers(cfg.ServerURL, cfg.Resources)...)
+}
+
+// validateRequestedResources checks that every requested resource is served by this server
+func validateRequestedResources(requested, served []string) error {
+	for _, resource := range requested {
+		if err := validateResourceIndicator(resource); err != nil {
+			return err
+		}
+		if !slices.Contains(served, normalizeResource(resource)) {
+			return fmt.Errorf("resource %q is not served by this server", resource)
+		}
+	}
+	return nil
+}
+
+// requestURLs returns the absolute URLs (without query) a request may have been
+// addressed to: as seen by this server, and through the public server URL
+func requestURLs(info *requestInfo, serverURL string) []string {
+	scheme := "http"
+	if info.tls != nil || strings.EqualFold(info.forwardedProto, "https") {
+		scheme = "https"
+	}
+	urls := []string{strings.ToLower(scheme+"://"+info.host) + info.path}
+	if server, err := url.Parse(serverURL); err == nil && server.Host != "" {
+		urls = append(urls, strings.ToLower(server.Scheme+"://"+server.Host)+info.path)
+	}
+	return urls
+}
+
+// matchRequestedResource returns the most specific resource identifier that
+// covers the request URL, or "" if none does
+func matchRequestedResource(info *requestInfo, serverURL string, identifiers []string) string {
+	matched := ""
+	for _, requestURL := range requestURLs(info, serverURL) {
+		for _, identifier := range identifiers {
+			lower := strings.ToLower(identifier)
+			if requestURL != lower && !strings.HasPrefix(requestURL, lower+"/") {
+				continue
+			}
+			if len(identifier) > len(matched) {
+				matched = identifier
+			}
+		}
+	}
+	return matched
+}
+
+// checkResourceAudience enforces that a token issued for one of this server's
+// resource identifiers (RFC 8707) is only used at that resource. Tokens whose
+// audience is the logical Config.Audience are accepted for every resource.
+func (s *Server) checkResourceAudience(ctx context.Context, user *User) error {
+	if s.config == nil {
+		return nil
+	}
+	info, ok := getRequestInfo(ctx)
+	if !ok {
+		return nil
+	}
+
+	identifiers := resourceIdentifiers(s.config.ServerURL, s.config.Resources)
+	var bound []string
+	for _, aud := range audienceClaim(user) {
+		if slices.Contains(identifiers, normalizeResource(aud)) {
+			bound = append(bound, normalizeResource(aud))
+		}
+	}
+	if len(bound) == 0 {
+		return nil
+	}
+
+	requested := matchRequestedResource(info, s.config.ServerURL, identifiers)
+	if requested == "" || !slices.Contains(bound, requested) {
+		return invalidTokenError("Access token audience does not include the requested resource", nil)
+	}
+	return nil
+}
+
+// audienceClaim returns the token's "aud" claim as a list
+func audienceClaim(user *User) []string {
+	return stringListClaim(user.Claims["aud"])
+}
@@ -0,0 +1,224 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// TestValidateRequestedResources tests RFC 8707 resource parameter validation
+func TestValidateRequestedResources(t *testing.T) {
+	served := resourceIdentifiers("https://mcp.example.com/", []string{"https://mcp.example.com/admin"})
+
+	tests := []struct {
+		name      string
+		requested []string
+		expectErr bool
+	}{
+		{name: "none"},
+		{name: "server URL", requested: []string{"https://mcp.example.com"}},
+		{name: "server URL with trailing slash", requested: []string{"https://mcp.example.com/"}},
+		{name: "configured resource", requested: []string{"https://mcp.example.com/admin"}},
+		{name: "both", requested: []string{"https://mcp.example.com", "https://mcp.example.com/admin"}},
+		{name: "unknown resource", requested: []string{"https://other.example.com"}, expectErr: true},
+		{name: "relative", requested: []string{"/admin"}, expectErr: true},
+		{name: "fragment", requested: []string{"https://mcp.example.com#frag"}, expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateRequestedResources(tt.requested, served)
+			if (err != nil) != tt.expectErr {
+				t.Errorf("validateRequestedResources() error = %v, expectErr %v", err, tt.expectErr)
+			}
+		})
+	}
+}
+
+// newResourceTestHandler creates a proxy-mode handler whose upstream is issuer
+func newResourceTestHandler(issuer string) *OAuth2Handler {
+	return NewOAuth2Handler(&OAuth2Config{
+		Mode:            "proxy",
+		Provider:        "hmac",
+		Issuer:          issuer,
+		ClientID:        "client-id",
+		RedirectURIs:    "https://client.example.com/callback,https://client.example.com/alt",
+		MCPURL:          "https://mcp.example.com",
+		Resources:       []string{"https://mcp.example.com/admin"},
+		stateSigningKey: []byte("test-state-signing-key"),
+	}, nil)
+}
+
+// TestHandleAuthorize_Resource tests that resource indicators are validated and forwarded
+func TestHandleAuthorize_Resource(t *testing.T) {
+	handler := newResourceTestHandler("https://idp.example.com")
+
+	authorize := func(resources ...string) *httptest.ResponseRecorder {
+		query := url.Values{
+			"client_id":    {"client"},
+			"redirect_uri": {"https://client.example.com/callback"},
+			"state":        {"xyz"},
+			"resource":     resources,
+		}
+		setTestCodeChallenge(query)
+		rec := httptest.NewRecorder()
+		handler.HandleAuthorize(rec, httptest.NewRequest(http.MethodGet, "/oauth/authorize?"+query.Encode(), nil))
+		return rec
+	}
+
+	rec := authorize("https://mcp.example.com", "https://mcp.example.com/admin")
+	if rec.Code != http.StatusTemporaryRedirect {
+		t.Fatalf("Expected redirect, got %d: %s", rec.Code, rec.Body.String())
+	}
+	location, err := url.Parse(rec.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("Invalid redirect location: %v", err)
+	}
+	forwarded := location.Query()["resource"]
+	if len(forwarded) != 2 || forwarded[0] != "https://mcp.example.com" || forwarded[1] != "https://mcp.example.com/admin" {
+		t.Errorf("Expected both resources forwarded upstream, got %v", forwarded)
+	}
+
+	if rec := authorize("https://attacker.example.com"); redirectedErrorCode(rec) != "invalid_target" {
+		t.Errorf("Expected unknown resource to be rejected with invalid_target, got %d: %s", rec.Code, rec.Header().Get("Location"))
+	}
+}
+
+// TestHandleToken_Resource tests that resource indicators are forwarded in the token exchange
+func TestHandleToken_Resource(t *testing.T) {
+	var upstreamResources []string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		upstreamResources = r.PostForm["resource"]
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "upstream-token",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+		})
+	}))
+	defer upstream.Close()
+
+	handler := newResourceTestHandler(upstream.URL)
+
+	exchange := func(resource string) *httptest.ResponseRecorder {
+		form := url.Values{
+			"grant_type":    {"authorization_code"},
+			"code":          {"auth-code"},
+			"redirect_uri":  {"https://client.example.com/callback"},
+			"resource":      {resource},
+			"code_verifier": {testCodeVerifier},
+		}
+		req := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		handler.HandleToken(rec, req)
+		return rec
+	}
+
+	if rec := exchange("https://mcp.example.com/admin"); rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if len(upstreamResources) != 1 || upstreamResources[0] != "https://mcp.example.com/admin" {
+		t.Errorf("Expected resource forwarded upstream, got %v", upstreamResources)
+	}
+
+	if rec := exchange("https://attacker.example.com"); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected unknown resource to be rejected, got %d", rec.Code)
+	}
+}
+
+// TestWrapHandler_ResourceAudience tests that resource-bound tokens are only accepted at their resource
+func TestWrapHandler_ResourceAudience(t *testing.T) {
+	cfg := &Config{
+		Provider:  "hmac",
+		Audience:  "api://test",
+		JWTSecret: []byte("test-secret-key-for-resource-checks"),
+		ServerURL: "https://mcp.example.com",
+		Resources: []string{"https://mcp.example.com/admin"},
+	}
+	server, err := NewServer(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	handler := server.WrapHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	sign := func(aud string) string {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"sub": "user",
+			"aud": aud,
+			"exp": time.Now().Add(time.Hour).Unix(),
+		}).SignedString(cfg.JWTSecret)
+		if err != nil {
+			t.Fatalf("Failed to sign token: %v", err)
+		}
+		return token
+	}
+
+	tests := []struct {
+		name     string
+		aud      string
+		path     string
+		expected int
+	}{
+		{name: "logical audience at MCP endpoint", aud: "api://test", path: "/mcp", expected: http.StatusOK},
+		{name: "logical audience at admin", aud: "api://test", path: "/admin/tools", expected: http.StatusOK},
+		{name: "server URL at MCP endpoint", aud: "https://mcp.example.com", path: "/mcp", expected: http.StatusOK},
+		{name: "server URL at admin", aud: "https://mcp.example.com", path: "/admin/tools", expected: http.StatusUnauthorized},
+		{name: "admin resource at admin", aud: "https://mcp.example.com/admin", path: "/admin/tools", expected: http.StatusOK},
+		{name: "admin resource at MCP endpoint", aud: "https://mcp.example.com/admin", path: "/mcp", expected: http.StatusUnauthorized},
+		{name: "unknown audience", aud: "https://other.example.com", path: "/mcp", expected: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "http://internal:8080"+tt.path, nil)
+			req.Header.Set("Authorization", "Bearer "+sign(tt.aud))
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.expected {
+				t.Errorf("Expected status %d, got %d", tt.expected, rec.Code)
+			}
+		})
+	}
+}
+
+// TestTokenAudiences tests that resource identifiers are accepted as audiences only when Resources is configured
+func TestTokenAudiences(t *testing.T) {
+	// A single Audience stays the only accepted audience, keeping go-oidc's audience check
+	cfg := &Config{Provider: "hmac", Audience: "api://test", ServerURL: "https://mcp.example.com"}
+	if audiences := tokenAudiences(cfg); len(audiences) != 0 {
+		t.Errorf("Expected no additional audiences, got %v", audiences)
+	}
+	cfg.Audiences = []string{"api://legacy"}
+	if audiences := tokenAudiences(cfg); len(audiences) != 1 || audiences[0] != "api://legacy" {
+		t.Errorf("Expected only Audiences, got %v", audiences)
+	}
+	cfg.Resources = []string{"https://mcp.example.com/admin/"}
+	if audiences := tokenAudiences(cfg); len(audiences) != 3 || audiences[1] != "https://mcp.example.com" || audiences[2] != "https://mcp.example.com/admin" {
+		t.Errorf("Expected Audiences and resource identifiers, got %v", audiences)
+	}
+
+	// Without Resources a token issued for ServerURL is rejected
+	server := newTestServer(t, nil, nil)
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": "user",
+		"aud": server.config.ServerURL,
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}).SignedString(server.config.JWTSecret)
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+	if _, err := server.ValidateTokenCached(context.Background(), token); err == nil {
+		t.Error("Expected the server URL not to be accepted as audience without Resources")
+	}
+}
@@ -0,0 +1,55 @@
+package oauth
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// defaultScopes are requested upstream and advertised when Config.Scopes is empty
+var defaultScopes = []string{"openid", "profile", "email"}
+
+// validateScopes checks that every scope is a valid scope token (RFC 6749 Section 3.3)
+func validateScopes(field string, scopes []string) error {
+	for _, scope := range scopes {
+		if scope == "" || strings.ContainsAny(scope, " \t\r\n\"\\") {
+			return fmt.Errorf("%s contains invalid scope %q", field, scope)
+		}
+	}
+	return nil
+}
+
+// resolveScopes returns the configured scopes and supported scopes with defaults applied
+func resolveScopes(scopes, supported []string) ([]string, []string) {
+	if len(scopes) == 0 {
+		scopes = defaultScopes
+	}
+	if len(supported) == 0 {
+		supported = scopes
+	}
+	return scopes, supported
+}
+
+// scopes returns the scopes requested from the upstream provider
+func (h *OAuth2Handler) scopes() []string {
+	scopes, _ := resolveScopes(h.config.Scopes, h.config.ScopesSupported)
+	return scopes
+}
+
+// scopesSupported returns the scopes advertised in metadata
+func (h *OAuth2Handler) scopesSupported() []string {
+	_, supported := resolveScopes(h.config.Scopes, h.config.ScopesSupported)
+	return supported
+}
+
+// validateRequestedScope checks a client's space-delimited scope parameter
+// against the supported scopes
+func (h *OAuth2Handler) validateRequestedScope(scope string) error {
+	supported := h.scopesSupported()
+	for _, requested := range strings.Fields(scope) {
+		if !slices.Contains(supported, requested) {
+			return fmt.Errorf("scope %q is not supported", requested)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package oauth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// TestHandleAuthorize_Scope tests that configured and client-requested scopes are forwarded upstream
+func TestHandleAuthorize_Scope(t *testing.T) {
+	handler := NewOAuth2Handler(&OAuth2Config{
+		Mode:            "proxy",
+		Provider:        "hmac",
+		Issuer:          "https://idp.example.com",
+		ClientID:        "client-id",
+		RedirectURIs:    "https://client.example.com/callback,https://client.example.com/alt",
+		MCPURL:          "https://mcp.example.com",
+		Scopes:          []string{"openid", "mcp:tools.read"},
+		ScopesSupported: []string{"openid", "mcp:tools.read", "mcp:admin"},
+		stateSigningKey: []byte("test-state-signing-key"),
+	}, nil)
+
+	authorize := func(scope string) *httptest.ResponseRecorder {
+		query := url.Values{
+			"client_id":    {"client"},
+			"redirect_uri": {"https://client.example.com/callback"},
+			"state":        {"xyz"},
+		}
+		setTestCodeChallenge(query)
+		if scope != "" {
+			query.Set("scope", scope)
+		}
+		rec := httptest.NewRecorder()
+		handler.HandleAuthorize(rec, httptest.NewRequest(http.MethodGet, "/oauth/authorize?"+query.Encode(), nil))
+		return rec
+	}
+
+	tests := []struct {
+		name     string
+		scope    string
+		expected string
+	}{
+		{name: "configured default", expected: "openid mcp:tools.read"},
+		{name: "client requested", scope: "mcp:admin openid", expected: "mcp:admin openid"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := authorize(tt.scope)
+			if rec.Code != http.StatusTemporaryRedirect {
+				t.Fatalf("Expected redirect, got %d: %s", rec.Code, rec.Body.String())
+			}
+			location, _ := url.Parse(rec.Header().Get("Location"))
+			if got := location.Query().Get("scope"); got != tt.expected {
+				t.Errorf("Expected upstream scope %q, got %q", tt.expected, got)
+			}
+		})
+	}
+
+	t.Run("unsupported scope", func(t *testing.T) {
+		if rec := authorize("openid mcp:root"); redirectedErrorCode(rec) != "invalid_scope" {
+			t.Errorf("Expected unsupported scope to be rejected with invalid_scope, got %d: %s", rec.Code, rec.Header().Get("Location"))
+		}
+	})
+}
+
+// TestWrapHandler_ScopeChallenge tests the scope parameter on WWW-Authenticate challenges
+func TestWrapHandler_ScopeChallenge(t *testing.T) {
+	server, err := NewServer(&Config{
+		Provider:  "hmac",
+		Audience:  "api://test",
+		JWTSecret: []byte("test-secret-key-for-scope-challenge"),
+		ServerURL: "https://mcp.example.com",
+		Scopes:    []string{"mcp:tools.read", "mcp:admin"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	server.WrapHandler(http.NotFoundHandler()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/mcp", nil))
+
+	challenge := rec.Header().Values("WWW-Authenticate")[0]
+	if !strings.Contains(challenge, `scope="mcp:tools.read mcp:admin"`) {
+		t.Errorf("Expected scope in challenge, got %s", challenge)
+	}
+}
+
+// TestConfigValidate_Scopes tests scope configuration validation
+func TestConfigValidate_Scopes(t *testing.T) {
+	tests := []struct {
+		name      string
+		scopes    []string
+		supported []string
+		expectErr bool
+	}{
+		{name: "defaults"},
+		{name: "custom scopes", scopes: []string{"mcp:tools.read"}},
+		{name: "subset of supported", scopes: []string{"mcp:tools.read"}, supported: []string{"mcp:tools.read", "mcp:admin"}},
+		{name: "not in supported", scopes: []string{"mcp:root"}, supported: []string{"mcp:admin"}, expectErr: true},
+		{name: "whitespace", scopes: []string{"mcp:tools.read mcp:admin"}, expectErr: true},
+		{name: "empty", supported: []string{""}, expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Provider:        "hmac",
+				Audience:        "api://test",
+				JWTSecret:       []byte("secret"),
+				Scopes:          tt.scopes,
+				ScopesSupported: tt.supported,
+			}
+			if err := cfg.Validate(); (err != nil) != tt.expectErr {
+				t.Errorf("Validate() error = %v, expectErr %v", err, tt.expectErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package oauth
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StepUpRequirement describes the authentication strength a tool requires
+// (RFC 9470). Zero-valued fields are not checked.
+type StepUpRequirement struct {
+	// ACRValues lists the acceptable authentication context classes; the
+	// token's "acr" claim must be one of them
+	ACRValues []string
+	// AMR lists authentication methods (e.g. "mfa") that must all be present
+	// in the token's "amr" claim
+	AMR []string
+	// MaxAge is the maximum time since the user last authenticated, measured
+	// from the token's "auth_time" claim
+	MaxAge time.Duration
+}
+
+// insufficientUserAuthentication is the RFC 9470 error code
+const insufficientUserAuthentication = "insufficient_user_authentication"
+
+// upstreamPrompts are the OIDC prompt values forwarded by the proxy
+var upstreamPrompts = map[string]bool{
+	"none":           true,
+	"login":          true,
+	"consent":        true,
+	"select_account": true,
+}
+
+// CheckStepUp verifies that the user's authentication satisfies the step-up
+// requirement configured for tool. Returns an *AuthError with code
+// "insufficient_user_authentication" and the acr_values/max_age challenge
+// parameters when it does not, or nil if the tool has no requirement.
+func (s *Server) CheckStepUp(user *User, tool string) error {
+	if s.config == nil {
+		return nil
+	}
+	requirement, ok := s.config.StepUp[tool]
+	if !ok {
+		return nil
+	}
+
+	if len(requirement.ACRValues) > 0 {
+		acr, _ := user.Claims["acr"].(string)
+		if !slices.Contains(requirement.ACRValues, acr) {
+			return stepUpError(requirement, "A different authentication level is required")
+		}
+	}
+
+	if len(requirement.AMR) > 0 {
+		amr := stringListClaim(user.Claims["amr"])
+		for _, method := range requirement.AMR {
+			if !slices.Contains(amr, method) {
+				return stepUpError(requirement, "Authentication method "+method+" is required")
+			}
+		}
+	}
+
+	if requirement.MaxAge > 0 {
+		authTime, ok := user.Claims["auth_time"].(float64)
+		if !ok {
+			return stepUpError(requirement, "Authentication time is unknown")
+		}
+		now := time.Now
+		if s.config.Clock != nil {
+			now = s.config.Clock
+		}
+		if now().Sub(time.Unix(int64(authTime), 0)) > requirement.MaxAge+s.config.Leeway {
+			return stepUpError(requirement, "More recent authentication is required")
+		}
+	}
+
+	return nil
+}
+
+// stepUpError builds the RFC 9470 challenge for an unmet requirement
+func stepUpError(requirement StepUpRequirement, description string) *AuthError {
+	params := map[string]string{}
+	if len(requirement.ACRValues) > 0 {
+		params["acr_values"] = strings.Join(requirement.ACRValues, " ")
+	}
+	if requirement.MaxAge > 0 {
+		params["max_age"] = strconv.FormatInt(int64(requirement.MaxAge/time.Second), 10)
+	}
+	return &AuthError{Code: insufficientUserAuthentication, Description: description, Params: params}
+}
+
+// stringListClaim returns a claim that may be a single string or an array of strings
+func stringListClaim(claim interface{}) []string {
+	switch value := claim.(type) {
+	case string:
+		return []string{value}
+	case []string:
+		return value
+	case []interface{}:
+		values := make([]string, 0, len(value))
+		for _, v := range value {
+			if s, ok := v.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// jsonrpcToolCall is the part of a JSON-RPC request needed to find tool calls
+type jsonrpcToolCall struct {
+	Method string `json:"method"`
+	Params struct {
+		Name string `json:"name"`
+	} `json:"params"`
+}
+
+// maxToolCallBodySize limits the request body buffered to find the called tools
+const maxToolCallBodySize = 10 << 20
+
+// requestedTools returns the names of the MCP tools called by a JSON-RPC
+// request (single or batch). The body is restored for the next handler.
+// Bodies larger than maxToolCallBodySize return an *http.MaxBytesError.
+func requestedTools(r *http.Request) ([]string, error) {
+	if r.Method != http.MethodPost || r.Body == nil {
+		return nil, nil
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, maxToolCallBodySize))
+	_ = r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+
+	var calls []jsonrpcToolCall
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &calls); err != nil {
+			return nil, nil
+		}
+	} else {
+		var call jsonrpcToolCall
+		if err := json.Unmarshal(trimmed, &call); err != nil {
+			return nil, nil
+		}
+		calls = append(calls, call)
+	}
+
+	var tools []string
+	for _, call := range calls {
+		if call.Method == "tools/call" && call.Params.Name != "" {
+			tools = append(tools, call.Params.Name)
+		}
+	}
+	return tools, nil
+}
+
+// checkStepUpRequest enforces step-up requirements for the tools called by an
+// HTTP request. A body that cannot be read returns an *OAuthError, which is
+// not an authentication failure; unmet requirements return an *AuthError.
+func (s *Server) checkStepUpRequest(r *http.Request, user *User) error {
+	if s.config == nil || len(s.config.StepUp) == 0 {
+		return nil
+	}
+
+	tools, err := requestedTools(r)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return &OAuthError{Status: http.StatusRequestEntityTooLarge, Code: "invalid_request", Description: "Request body too large", Err: err}
+	}
+	if err != nil {
+		return &OAuthError{Code: "invalid_request", Description: "Invalid request body", Err: err}
+	}
+	for _, tool := range tools {
+		if err := s.CheckStepUp(user, tool); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateStepUpParams checks the acr_values, max_age and prompt parameters
+// the proxy forwards to the upstream authorization endpoint
+func validateStepUpParams(maxAge, prompt string) error {
+	if maxAge != "" {
+		if seconds, err := strconv.Atoi(maxAge); err != nil || seconds < 0 {
+			return fmt.Errorf("max_age must be a non-negative integer")
+		}
+	}
+	for _, value := range strings.Fields(prompt) {
+		if !upstreamPrompts[value] {
+			return fmt.Errorf("unsupported prompt value %q", value)
+		}
+	}
+	return nil
+}
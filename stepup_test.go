@@ -0,0 +1,241 @@
+package oauth
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// stepUpTestConfig requires MFA and a recent login for "deploy", with the clock at now
+func stepUpTestConfig(now time.Time) func(cfg *Config) {
+	return func(cfg *Config) {
+		cfg.Clock = func() time.Time { return now }
+		cfg.StepUp = map[string]StepUpRequirement{
+			"deploy": {ACRValues: []string{"urn:mfa"}, AMR: []string{"mfa"}, MaxAge: 5 * time.Minute},
+		}
+	}
+}
+
+// TestCheckStepUp tests acr, amr and auth_time requirements
+func TestCheckStepUp(t *testing.T) {
+	now := time.Now()
+	server := newTestServer(t, nil, stepUpTestConfig(now))
+
+	tests := []struct {
+		name      string
+		tool      string
+		claims    map[string]interface{}
+		expectErr bool
+	}{
+		{name: "unprotected tool", tool: "read", claims: map[string]interface{}{}},
+		{
+			name:   "requirement met",
+			tool:   "deploy",
+			claims: map[string]interface{}{"acr": "urn:mfa", "amr": []interface{}{"pwd", "mfa"}, "auth_time": float64(now.Add(-time.Minute).Unix())},
+		},
+		{
+			name:      "wrong acr",
+			tool:      "deploy",
+			claims:    map[string]interface{}{"acr": "urn:pwd", "amr": []interface{}{"mfa"}, "auth_time": float64(now.Unix())},
+			expectErr: true,
+		},
+		{
+			name:      "missing amr",
+			tool:      "deploy",
+			claims:    map[string]interface{}{"acr": "urn:mfa", "amr": []interface{}{"pwd"}, "auth_time": float64(now.Unix())},
+			expectErr: true,
+		},
+		{
+			name:      "stale login",
+			tool:      "deploy",
+			claims:    map[string]interface{}{"acr": "urn:mfa", "amr": []interface{}{"mfa"}, "auth_time": float64(now.Add(-time.Hour).Unix())},
+			expectErr: true,
+		},
+		{
+			name:      "missing auth_time",
+			tool:      "deploy",
+			claims:    map[string]interface{}{"acr": "urn:mfa", "amr": []interface{}{"mfa"}},
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := server.CheckStepUp(&User{Claims: tt.claims}, tt.tool)
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("CheckStepUp() error = %v, expectErr %v", err, tt.expectErr)
+			}
+			if err == nil {
+				return
+			}
+			authErr := asAuthError(err)
+			if authErr.Code != "insufficient_user_authentication" || authErr.Params["acr_values"] != "urn:mfa" || authErr.Params["max_age"] != "300" {
+				t.Errorf("Unexpected step-up error: %+v", authErr)
+			}
+		})
+	}
+}
+
+// TestWrapHandler_StepUp tests the RFC 9470 challenge for tool calls over HTTP
+func TestWrapHandler_StepUp(t *testing.T) {
+	now := time.Now()
+	server := newTestServer(t, nil, stepUpTestConfig(now))
+	cfg := server.config
+
+	var receivedBody string
+	handler := server.WrapHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		receivedBody = string(body)
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub":       "user",
+		"aud":       cfg.Audience,
+		"exp":       now.Add(time.Hour).Unix(),
+		"acr":       "urn:pwd",
+		"auth_time": now.Add(-time.Hour).Unix(),
+	}).SignedString(cfg.JWTSecret)
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+
+	call := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "https://mcp.example.com/mcp", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	readCall := `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"read"}}`
+	if rec := call(readCall); rec.Code != http.StatusOK {
+		t.Fatalf("Expected unprotected tool to pass, got %d", rec.Code)
+	}
+	if receivedBody != readCall {
+		t.Errorf("Expected request body to be preserved, got %q", receivedBody)
+	}
+
+	rec := call(`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"deploy"}}`)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected 401 for deploy, got %d", rec.Code)
+	}
+	challenge := rec.Header().Values("WWW-Authenticate")[0]
+	for _, expected := range []string{`error="insufficient_user_authentication"`, `acr_values="urn:mfa"`, `max_age="300"`} {
+		if !strings.Contains(challenge, expected) {
+			t.Errorf("Expected %s in challenge, got %s", expected, challenge)
+		}
+	}
+
+	// A missing authentication method is described in a well-formed challenge
+	token, err = jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub":       "user",
+		"aud":       cfg.Audience,
+		"exp":       now.Add(time.Hour).Unix(),
+		"acr":       "urn:mfa",
+		"amr":       []string{"pwd"},
+		"auth_time": now.Add(-time.Minute).Unix(),
+	}).SignedString(cfg.JWTSecret)
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+	rec = call(`{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"deploy"}}`)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected 401 for deploy without mfa, got %d", rec.Code)
+	}
+	params := parseChallenge(t, rec.Header().Values("WWW-Authenticate")[0])
+	if params["error"] != insufficientUserAuthentication || params["error_description"] != "Authentication method mfa is required" {
+		t.Errorf("Unexpected challenge parameters %v", params)
+	}
+
+	// Oversized bodies are not buffered
+	rec = call(`{"jsonrpc":"2.0","id":4,"method":"tools/call","params":{"name":"read","arguments":"` + strings.Repeat("a", maxToolCallBodySize) + `"}}`)
+	if rec.Code != http.StatusRequestEntityTooLarge || decodeOAuthError(t, rec).Error != "invalid_request" {
+		t.Errorf("Expected 413 for an oversized body, got %d", rec.Code)
+	}
+	if challenge := rec.Header().Get("WWW-Authenticate"); challenge != "" {
+		t.Errorf("Expected no authentication challenge for an oversized body, got %s", challenge)
+	}
+}
+
+// TestMiddleware_StepUp tests the structured MCP error returned by the tool middleware
+func TestMiddleware_StepUp(t *testing.T) {
+	now := time.Now()
+	server := newTestServer(t, nil, stepUpTestConfig(now))
+	cfg := server.config
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": "user",
+		"aud": cfg.Audience,
+		"exp": now.Add(time.Hour).Unix(),
+	}).SignedString(cfg.JWTSecret)
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+
+	called := false
+	tool := server.Middleware()(func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		called = true
+		return mcp.NewToolResultText("deployed"), nil
+	})
+
+	req := mcp.CallToolRequest{}
+	req.Params.Name = "deploy"
+	result, err := tool(WithOAuthToken(context.Background(), token), req)
+	if err != nil {
+		t.Fatalf("Expected a tool error result, got error: %v", err)
+	}
+	if called || result == nil || !result.IsError {
+		t.Fatalf("Expected the tool to be blocked with an error result, got %+v", result)
+	}
+	content, _ := result.StructuredContent.(map[string]interface{})
+	if content["error"] != "insufficient_user_authentication" || content["max_age"] != "300" {
+		t.Errorf("Unexpected structured content: %v", result.StructuredContent)
+	}
+}
+
+// TestHandleAuthorize_StepUpParams tests forwarding of acr_values, max_age and prompt
+func TestHandleAuthorize_StepUpParams(t *testing.T) {
+	handler := NewOAuth2Handler(&OAuth2Config{
+		Mode:            "proxy",
+		Provider:        "hmac",
+		Issuer:          "https://idp.example.com",
+		ClientID:        "client-id",
+		RedirectURIs:    "https://client.example.com/callback,https://client.example.com/alt",
+		MCPURL:          "https://mcp.example.com",
+		stateSigningKey: []byte("test-state-signing-key"),
+	}, nil)
+
+	authorize := func(params url.Values) *httptest.ResponseRecorder {
+		params.Set("client_id", "client")
+		params.Set("redirect_uri", "https://client.example.com/callback")
+		setTestCodeChallenge(params)
+		rec := httptest.NewRecorder()
+		handler.HandleAuthorize(rec, httptest.NewRequest(http.MethodGet, "/oauth/authorize?"+params.Encode(), nil))
+		return rec
+	}
+
+	rec := authorize(url.Values{"acr_values": {"urn:mfa"}, "max_age": {"300"}, "prompt": {"login"}})
+	if rec.Code != http.StatusTemporaryRedirect {
+		t.Fatalf("Expected redirect, got %d: %s", rec.Code, rec.Body.String())
+	}
+	location, _ := url.Parse(rec.Header().Get("Location"))
+	query := location.Query()
+	if query.Get("acr_values") != "urn:mfa" || query.Get("max_age") != "300" || query.Get("prompt") != "login" {
+		t.Errorf("Expected step-up parameters forwarded upstream, got %s", location.RawQuery)
+	}
+
+	for _, params := range []url.Values{{"max_age": {"-1"}}, {"prompt": {"evil"}}} {
+		if rec := authorize(params); redirectedErrorCode(rec) != "invalid_request" {
+			t.Errorf("Expected %v to be rejected with invalid_request, got %d: %s", params, rec.Code, rec.Header().Get("Location"))
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package oauth
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+)
+
+// Names of the HTML pages served by the proxy (see Config.Templates)
+const (
+	successPageTemplate       = "success.html"
+	errorPageTemplate         = "error.html"
+	consentPageTemplate       = "consent.html"
+	logoutPageTemplate        = "logout.html"
+	logoutConfirmPageTemplate = "logout_confirm.html"
+)
+
+// PageData is passed to the HTML page templates (see Config.Templates).
+// Fields that do not apply to a page are empty.
+type PageData struct {
+	// Nonce must be set on inline <style> and <script> elements, which the
+	// Content-Security-Policy of the page only allows with this nonce
+	Nonce string
+
+	ClientName   string   // client_name from dynamic registration; self-asserted, may be empty
+	ClientID     string   // client_id of the authorization request
+	RedirectHost string   // Host the authorization code will be sent to
+	User         string   // Signed-in user, when known
+	Scopes       []string // Requested scopes
+
+	// Error page
+	ErrorCode        string // OAuth error code, e.g. "access_denied"
+	ErrorDescription string // Human-readable description
+
+	// Provider selection page
+	Providers []ProviderOption
+
+	// Consent and logout forms
+	Action    string // URL the form is posted to
+	ConsentID string // Value of the consent_id form field
+	CSRFToken string // Value of the csrf_token form field
+}
+
+// pageStyle is the stylesheet shared by the default pages
+const pageStyle = `{{define "style"}}<style nonce="{{.Nonce}}">
+		body { font-family: system-ui, sans-serif; max-width: 32rem; margin: 4rem auto; padding: 0 1rem; color: #1f2328; }
+		button { margin-right: .5rem; padding: .4rem 1rem; }
+		code { background: #f6f8fa; padding: .1rem .3rem; }
+	</style>{{end}}`
+
+// defaultTemplates are the built-in pages, executed with PageData
+var defaultTemplates = template.Must(template.New("").Parse(pageStyle + `
+{{define "success.html"}}<!DOCTYPE html>
+<html lang="en">
+<head>
+	<meta charset="utf-8">
+	<meta name="viewport" content="width=device-width, initial-scale=1">
+	<title>OAuth2 Success</title>
+	{{template "style" .}}
+</head>
+<body>
+	<h2>Authentication Successful!</h2>
+	<p>{{if .User}}You have been successfully authenticated as {{.User}}.{{else}}You have been successfully authenticated.{{end}}</p>
+	<p>You can now close this window and return to {{if .ClientName}}{{.ClientName}}{{else}}your application{{end}}.</p>
+</body>
+</html>
+{{end}}
+{{define "error.html"}}<!DOCTYPE html>
+<html lang="en">
+<head>
+	<meta charset="utf-8">
+	<meta name="viewport" content="width=device-width, initial-scale=1">
+	<title>Authentication Failed</title>
+	{{template "style" .}}
+</head>
+<body>
+	<h2>Authentication Failed</h2>
+	<p>{{if .ErrorDescription}}{{.ErrorDescription}}{{else}}The request could not be completed.{{end}}</p>
+	<p>Error code: <code>{{.ErrorCode}}</code></p>
+	<p>Close this window and try again from your application.</p>
+</body>
+</html>
+{{end}}
+{{define "consent.html"}}<!DOCTYPE html>
+<html lang="en">
+<head>
+	<meta charset="utf-8">
+	<meta name="viewport" content="width=device-width, initial-scale=1">
+	<title>Authorize access</title>
+	{{template "style" .}}
+</head>
+<body>
+	<h2>{{if .ClientName}}{{.ClientName}}{{else}}An unknown application{{end}} wants to access your account</h2>
+	<p>After you sign in, you will be sent back to <strong>{{.RedirectHost}}</strong>.
+	Only continue if you started this request and trust this destination.</p>
+	{{if .Scopes}}<p>Requested permissions:</p>
+	<ul>{{range .Scopes}}
+		<li>{{.}}</li>{{end}}
+	</ul>{{end}}
+	<form method="post" action="{{.Action}}">
+		<input type="hidden" name="consent_id" value="{{.ConsentID}}">
+		<input type="hidden" name="csrf_token" value="{{.CSRFToken}}">
+		<button type="submit" name="action" value="approve">Allow</button>
+		<button type="submit" name="action" value="switch_account">Use another account</button>
+		<button type="submit" name="action" value="deny">Deny</button>
+	</form>
+</body>
+</html>
+{{end}}
+{{define "select_provider.html"}}<!DOCTYPE html>
+<html lang="en">
+<head>
+	<meta charset="utf-8">
+	<meta name="viewport" content="width=device-width, initial-scale=1">
+	<title>Choose how to sign in</title>
+	{{template "style" .}}
+</head>
+<body>
+	<h2>Choose how to sign in</h2>
+	<p>After you sign in, you will be sent back to <strong>{{.RedirectHost}}</strong>.</p>
+	<ul>{{range .Providers}}
+		<li><a href="{{.URL}}">Continue with {{.DisplayName}}</a></li>{{end}}
+	</ul>
+</body>
+</html>
+{{end}}
+{{define "logout.html"}}<!DOCTYPE html>
+<html lang="en">
+<head>
+	<meta charset="utf-8">
+	<meta name="viewport" content="width=device-width, initial-scale=1">
+	<title>Signed out</title>
+	{{template "style" .}}
+</head>
+<body>
+	<h2>Signed out</h2>
+	<p>{{if .User}}{{.User}} has been{{else}}You have been{{end}} signed out. You can now close this window.</p>
+</body>
+</html>
+{{end}}
+{{define "logout_confirm.html"}}<!DOCTYPE html>
+<html lang="en">
+<head>
+	<meta charset="utf-8">
+	<meta name="viewport" content="width=device-width, initial-scale=1">
+	<title>Sign out</title>
+	{{template "style" .}}
+</head>
+<body>
+	<h2>Sign out?</h2>
+	<p>Do you want to sign {{if .User}}{{.User}}{{else}}yourself{{end}} out? Only continue if you started this request.</p>
+	<form method="post" action="{{.Action}}">
+		<input type="hidden" name="csrf_token" value="{{.CSRFToken}}">
+		<button type="submit">Sign out</button>
+	</form>
+</body>
+</html>
+{{end}}`))
+
+// loadTemplates returns the page templates configured in cfg, or nil to use the defaults
+func loadTemplates(cfg *Config) (*template.Template, error) {
+	if cfg.Templates != nil && cfg.TemplatesFS != nil {
+		return nil, fmt.Errorf("set only one of Templates and TemplatesFS")
+	}
+	if cfg.TemplatesFS == nil {
+		return cfg.Templates, nil
+	}
+	tmpl, err := template.ParseFS(cfg.TemplatesFS, "*.html")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse TemplatesFS: %w", err)
+	}
+	return tmpl, nil
+}
+
+// pageTemplate returns the configured template for page or the default
+func (h *OAuth2Handler) pageTemplate(page string) *template.Template {
+	if h.config.Templates != nil {
+		if tmpl := h.config.Templates.Lookup(page); tmpl != nil {
+			return tmpl
+		}
+	}
+	return defaultTemplates.Lookup(page)
+}
+
+// renderPage writes an HTML page with a fresh CSP nonce and the security
+// headers of the OAuth endpoints
+func (h *OAuth2Handler) renderPage(w http.ResponseWriter, status int, page string, data PageData) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		h.logger.Error("OAuth2: Failed to generate CSP nonce: %v", err)
+		writeOAuthError(w, http.StatusInternalServerError, "server_error", "Internal server error")
+		return
+	}
+	data.Nonce = base64.RawURLEncoding.EncodeToString(nonce) // html/template would escape "+"
+
+	// Render into a buffer so a template error does not leave a partial page
+	var buf bytes.Buffer
+	if err := h.pageTemplate(page).Execute(&buf, data); err != nil {
+		h.logger.Error("OAuth2: Failed to render %s: %v", page, err)
+		writeOAuthError(w, http.StatusInternalServerError, "server_error", "Internal server error")
+		return
+	}
+
+	h.addSecurityHeaders(w)
+	w.Header().Set("Content-Security-Policy", fmt.Sprintf(
+		"default-src 'none'; style-src 'nonce-%s'; script-src 'nonce-%s'; img-src 'self' data:; frame-ancestors 'none'; base-uri 'none'",
+		data.Nonce, data.Nonce))
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	_, _ = w.Write(buf.Bytes())
+}
+
+// acceptsHTML reports whether the request comes from a browser that asked for an HTML page
+func acceptsHTML(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+// writeErrorPage writes an error response for a browser-facing endpoint
+func (h *OAuth2Handler) writeErrorPage(w http.ResponseWriter, r *http.Request, status int, code, description string) {
+	h.writeErrorPageResponse(w, r, &OAuthError{Status: status, Code: code, Description: description})
+}
+
+// writeErrorPageResponse returns oauthErr as the error page to browsers and
+// as an RFC 6749 JSON error response to other clients
+func (h *OAuth2Handler) writeErrorPageResponse(w http.ResponseWriter, r *http.Request, oauthErr *OAuthError) {
+	if !acceptsHTML(r) {
+		writeOAuthErrorResponse(w, oauthErr)
+		return
+	}
+	h.renderPage(w, oauthErr.status(), errorPageTemplate, PageData{
+		ErrorCode:        oauthErr.Code,
+		ErrorDescription: oauthErr.Description,
+	})
+}
@@ -0,0 +1,173 @@
+package oauth
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+var styleNoncePattern = regexp.MustCompile(`<style nonce="([^"]+)">`)
+
+// newTemplateTestHandler creates an allowlist mode proxy with the given page templates
+func newTemplateTestHandler(templates *template.Template) *OAuth2Handler {
+	return NewOAuth2Handler(&OAuth2Config{
+		Mode:            "proxy",
+		Provider:        "hmac",
+		Issuer:          "https://idp.example.com",
+		ClientID:        "client-id",
+		RedirectURIs:    "https://client.example.com/callback,https://client.example.com/alt",
+		MCPURL:          "https://mcp.example.com",
+		Templates:       templates,
+		stateSigningKey: []byte("test-state-signing-key"),
+	}, nil)
+}
+
+// callback sends a browser request to /oauth/callback
+func callback(handler *OAuth2Handler, params url.Values, accept string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/oauth/callback?"+params.Encode(), nil)
+	req.Header.Set("Accept", accept)
+	rec := httptest.NewRecorder()
+	handler.HandleCallback(rec, req)
+	return rec
+}
+
+// TestDefaultPages tests the built-in success and error pages
+func TestDefaultPages(t *testing.T) {
+	handler := newTemplateTestHandler(nil)
+
+	rec := callback(handler, url.Values{"code": {"auth-code"}, "state": {"xyz"}}, "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "Authentication Successful") {
+		t.Fatalf("Expected success page, got %d: %s", rec.Code, rec.Body.String())
+	}
+	nonce := styleNoncePattern.FindStringSubmatch(rec.Body.String())
+	if nonce == nil {
+		t.Fatalf("Expected nonce on the inline stylesheet: %s", rec.Body.String())
+	}
+	csp := rec.Header().Get("Content-Security-Policy")
+	if !strings.Contains(csp, "style-src 'nonce-"+nonce[1]+"'") || !strings.Contains(csp, "frame-ancestors 'none'") {
+		t.Errorf("Expected CSP with the page nonce, got %q", csp)
+	}
+	if rec.Header().Get("X-Frame-Options") != "DENY" || rec.Header().Get("X-Content-Type-Options") != "nosniff" {
+		t.Error("Expected security headers on the success page")
+	}
+
+	// Each page gets a fresh nonce
+	again := callback(handler, url.Values{"code": {"auth-code"}, "state": {"xyz"}}, "")
+	if again.Header().Get("Content-Security-Policy") == csp {
+		t.Error("Expected a new nonce for every page")
+	}
+
+	// Browsers get the error page, other clients JSON
+	upstreamError := url.Values{"error": {"access_denied"}, "error_description": {"<script>alert(1)</script>"}}
+	rec = callback(handler, upstreamError, "text/html,application/xhtml+xml")
+	if rec.Code != http.StatusBadRequest || rec.Header().Get("Content-Type") != "text/html; charset=utf-8" {
+		t.Fatalf("Expected HTML error page, got %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if body := rec.Body.String(); !strings.Contains(body, "access_denied") || strings.Contains(body, "<script>alert") {
+		t.Errorf("Expected escaped error page, got %s", body)
+	}
+	if rec := callback(handler, upstreamError, "application/json"); decodeOAuthError(t, rec).Error != "access_denied" {
+		t.Errorf("Expected JSON error for non-browser clients")
+	}
+}
+
+// TestCustomTemplates tests replacing pages with Templates and TemplatesFS
+func TestCustomTemplates(t *testing.T) {
+	custom := template.Must(template.New("").Parse(`{{define "success.html"}}<p>Welcome back</p><style nonce="{{.Nonce}}"></style>{{end}}`))
+	handler := newTemplateTestHandler(custom)
+
+	rec := callback(handler, url.Values{"code": {"auth-code"}, "state": {"xyz"}}, "")
+	if rec.Body.String() == "" || !strings.Contains(rec.Body.String(), "Welcome back") {
+		t.Errorf("Expected custom success page, got %s", rec.Body.String())
+	}
+	if nonce := styleNoncePattern.FindStringSubmatch(rec.Body.String()); nonce == nil || !strings.Contains(rec.Header().Get("Content-Security-Policy"), nonce[1]) {
+		t.Errorf("Expected custom page to receive the CSP nonce")
+	}
+
+	// Pages that are not defined keep the default
+	rec = callback(handler, url.Values{"error": {"access_denied"}}, "text/html")
+	if !strings.Contains(rec.Body.String(), "Authentication Failed") {
+		t.Errorf("Expected default error page, got %s", rec.Body.String())
+	}
+
+	// A failing template returns a server error instead of a partial page
+	broken := template.Must(template.New("").Parse(`{{define "success.html"}}partial {{.Missing}}{{end}}`))
+	rec = callback(newTemplateTestHandler(broken), url.Values{"code": {"auth-code"}, "state": {"xyz"}}, "")
+	if rec.Code != http.StatusInternalServerError || strings.Contains(rec.Body.String(), "partial") {
+		t.Errorf("Expected 500 without partial output, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// TemplatesFS is parsed by name
+	base := Config{Mode: "proxy", Provider: "hmac", Audience: "api://test", JWTSecret: []byte("secret"), ClientID: "client", ServerURL: "https://mcp.example.com", RedirectURIs: "https://mcp.example.com/oauth/callback"}
+	cfg := base
+	cfg.TemplatesFS = fstest.MapFS{"error.html": {Data: []byte(`<p>Custom error {{.ErrorCode}}</p>`)}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Expected valid TemplatesFS, got %v", err)
+	}
+	oauth2Config := NewOAuth2ConfigFromConfig(&cfg, "test")
+	oauth2Config.stateSigningKey = []byte("test-state-signing-key")
+	rec = callback(NewOAuth2Handler(oauth2Config, nil), url.Values{"error": {"access_denied"}, "state": {"forged"}}, "text/html")
+	if !strings.Contains(rec.Body.String(), "Custom error invalid_request") {
+		t.Errorf("Expected error page from TemplatesFS, got %s", rec.Body.String())
+	}
+
+	// Validation
+	cfg.TemplatesFS = fstest.MapFS{"error.html": {Data: []byte(`{{.ErrorCode`)}}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected invalid TemplatesFS to be rejected")
+	}
+	cfg.TemplatesFS = fstest.MapFS{"error.html": {Data: []byte(`ok`)}}
+	cfg.Templates = custom
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected Templates and TemplatesFS together to be rejected")
+	}
+	cfg = Config{Mode: "native", Provider: "hmac", Audience: "api://test", JWTSecret: []byte("secret"), Templates: custom}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected Templates to require proxy mode")
+	}
+}
+
+// TestSuccessPageData tests that the success page receives the client and user of the consent page session
+func TestSuccessPageData(t *testing.T) {
+	custom := template.Must(template.New("").Parse(`{{define "success.html"}}{{.ClientName}}|{{.ClientID}}|{{.User}}{{end}}`))
+	handler := NewOAuth2Handler(&OAuth2Config{
+		Mode:            "proxy",
+		Provider:        "hmac",
+		Issuer:          "https://idp.example.com",
+		ClientID:        "client-id",
+		RedirectURIs:    "http://localhost:3000/callback,https://client.example.com/callback",
+		MCPURL:          "https://mcp.example.com",
+		Templates:       custom,
+		Consent:         true,
+		stateSigningKey: []byte("test-state-signing-key"),
+	}, nil)
+	flow := &consentTestFlow{t: t, handler: handler}
+
+	body := strings.NewReader(`{"client_name":"Inspector","redirect_uris":["http://localhost:3000/callback"]}`)
+	if rec := flow.do(handler.HandleRegister, httptest.NewRequest(http.MethodPost, "/oauth/register", body)); rec.Code != http.StatusCreated {
+		t.Fatalf("Registration failed: %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := flow.submit(flow.authorize(nil), "approve"); rec.Code != http.StatusTemporaryRedirect {
+		t.Fatalf("Expected redirect to the provider, got %d", rec.Code)
+	}
+
+	success := func() string {
+		return flow.do(handler.HandleCallback, httptest.NewRequest(http.MethodGet, "/oauth/callback?code=auth-code&state=xyz", nil)).Body.String()
+	}
+	if page := success(); page != "Inspector|client-id|" {
+		t.Errorf("Expected the client on the success page, got %q", page)
+	}
+
+	// A user who signed in earlier in this browser is shown too
+	for _, session := range handler.consent.sessions {
+		session.subject = subjectKey("https://idp.example.com", "user-1")
+	}
+	if page := success(); page != "Inspector|client-id|user-1" {
+		t.Errorf("Expected the signed-in user on the success page, got %q", page)
+	}
+}
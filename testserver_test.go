@@ -0,0 +1,59 @@
+package oauth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newTestServer creates a Server from an HMAC configuration that configure
+// may change (configure may be nil). When upstream is not nil, it serves an
+// upstream provider at Config.Issuer in proxy mode; the provider answers
+// OpenID Connect discovery with its endpoints below /oauth2/v1, including
+// device authorization, so providers other than hmac discover them.
+func newTestServer(t *testing.T, upstream http.HandlerFunc, configure func(cfg *Config)) *Server {
+	t.Helper()
+
+	cfg := &Config{
+		Mode:         "native",
+		Provider:     "hmac",
+		Audience:     "api://test",
+		ClientID:     "mcp-server",
+		ClientSecret: "mcp-secret",
+		ServerURL:    "https://mcp.example.com",
+		JWTSecret:    []byte("test-secret-key-must-be-32-bytes-long!"),
+	}
+	if upstream != nil {
+		idp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != oidcDiscoveryPath {
+				upstream(w, r)
+				return
+			}
+			issuer := "http://" + r.Host
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"issuer":                        issuer,
+				"authorization_endpoint":        issuer + "/oauth2/v1/authorize",
+				"token_endpoint":                issuer + "/oauth2/v1/token",
+				"device_authorization_endpoint": issuer + "/oauth2/v1/device/authorize",
+				"jwks_uri":                      issuer + "/oauth2/v1/keys",
+				"response_types_supported":      []string{"code"},
+			})
+		}))
+		t.Cleanup(idp.Close)
+
+		cfg.Mode = "proxy"
+		cfg.Issuer = idp.URL
+		cfg.RedirectURIs = "https://mcp.example.com/oauth/callback"
+	}
+	if configure != nil {
+		configure(cfg)
+	}
+
+	server, err := NewServer(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	return server
+}
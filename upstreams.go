@@ -0,0 +1,232 @@
+package oauth
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// providerSelectionLifetime is how long the links of the provider selection page can be used
+const providerSelectionLifetime = 10 * time.Minute
+
+// providerSelectionTemplate is the page listing the upstream identity providers
+const providerSelectionTemplate = "select_provider.html"
+
+// upstreamNamePattern matches the idp parameter values of upstream providers
+var upstreamNamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// UpstreamProvider is an additional upstream identity provider users can sign
+// in with in proxy mode (see Config.UpstreamProviders). Its endpoints are
+// discovered from Issuer, and tokens it issues are validated like tokens
+// from Config.Issuers.
+type UpstreamProvider struct {
+	// Name identifies the provider in the idp parameter of /oauth/authorize,
+	// e.g. "google". Letters, digits, ".", "_" and "-" only.
+	Name string
+	// DisplayName is shown on the provider selection page (default: Name)
+	DisplayName string
+
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+
+	// Scopes are requested when the client does not send a scope parameter
+	// (default: Config.Scopes)
+	Scopes []string
+	// Audiences accepted for tokens from this provider. If empty,
+	// Config.Audience and Config.Audiences are used.
+	Audiences []string
+	// ClaimMapping overrides Config.ClaimMapping for tokens from this provider
+	ClaimMapping *ClaimMapping
+}
+
+// ProviderOption is an upstream identity provider on the provider selection page
+type ProviderOption struct {
+	Name        string // Value of the idp parameter
+	DisplayName string // Label shown to the user
+	URL         string // Link that continues the authorization with this provider
+}
+
+// upstreamIdP is an upstream identity provider the proxy can redirect to
+type upstreamIdP struct {
+	name          string
+	displayName   string
+	issuer        string
+	config        *oauth2.Config
+	endSessionURL string // OIDC end_session_endpoint, if discovered
+}
+
+// validateUpstreamProviders checks Config.UpstreamProviders
+func validateUpstreamProviders(c *Config) error {
+	if len(c.UpstreamProviders) == 0 {
+		return nil
+	}
+	if c.Mode != "proxy" {
+		return fmt.Errorf("UpstreamProviders requires proxy mode")
+	}
+	if c.Provider == "hmac" {
+		return fmt.Errorf("UpstreamProviders are only supported for OIDC providers")
+	}
+	if c.RedirectURIs == "" || strings.Contains(c.RedirectURIs, ",") {
+		return fmt.Errorf("UpstreamProviders requires fixed redirect mode (a single RedirectURIs value)")
+	}
+
+	names := []string{primaryProviderName(c.ProviderName, c.Provider)}
+	if !upstreamNamePattern.MatchString(names[0]) {
+		return fmt.Errorf("invalid ProviderName: %q", names[0])
+	}
+	for _, upstream := range c.UpstreamProviders {
+		if !upstreamNamePattern.MatchString(upstream.Name) {
+			return fmt.Errorf("invalid upstream provider name: %q", upstream.Name)
+		}
+		if slices.Contains(names, upstream.Name) {
+			return fmt.Errorf("duplicate upstream provider name: %s", upstream.Name)
+		}
+		names = append(names, upstream.Name)
+		if upstream.Issuer == "" {
+			return fmt.Errorf("upstream provider %s requires Issuer", upstream.Name)
+		}
+		if upstream.ClientID == "" {
+			return fmt.Errorf("upstream provider %s requires ClientID", upstream.Name)
+		}
+	}
+	return nil
+}
+
+// primaryProviderName returns the idp name of the provider configured by
+// Config.Provider and Config.Issuer
+func primaryProviderName(name, providerType string) string {
+	if name != "" {
+		return name
+	}
+	return providerType
+}
+
+// trustedIssuers returns Config.Issuers plus the issuers of the upstream
+// providers that are not already trusted
+func trustedIssuers(cfg *Config) []IssuerConfig {
+	issuers := append([]IssuerConfig{}, cfg.Issuers...)
+	trusted := []string{cfg.Issuer}
+	for _, issuer := range issuers {
+		trusted = append(trusted, issuer.Issuer)
+	}
+	for _, upstream := range cfg.UpstreamProviders {
+		if slices.Contains(trusted, upstream.Issuer) {
+			continue
+		}
+		trusted = append(trusted, upstream.Issuer)
+		issuers = append(issuers, IssuerConfig{
+			Issuer:       upstream.Issuer,
+			Audiences:    upstream.Audiences,
+			ClaimMapping: upstream.ClaimMapping,
+		})
+	}
+	return issuers
+}
+
+// newUpstreams creates the upstream identity providers. The primary provider
+// (primary, with its end_session_endpoint) comes first.
+func newUpstreams(cfg *OAuth2Config, primary *oauth2.Config, endSessionURL string, logger Logger) []*upstreamIdP {
+	name := primaryProviderName(cfg.ProviderName, cfg.Provider)
+	upstreams := []*upstreamIdP{{name: name, displayName: name, issuer: cfg.Issuer, config: primary, endSessionURL: endSessionURL}}
+
+	for _, upstream := range cfg.UpstreamProviders {
+		endpoint, endSessionURL := discoverEndpoint("oidc", upstream.Issuer, logger)
+		oauth2Config := &oauth2.Config{
+			ClientID:     upstream.ClientID,
+			ClientSecret: upstream.ClientSecret,
+			Endpoint:     endpoint,
+			Scopes:       primary.Scopes,
+		}
+		if len(upstream.Scopes) > 0 {
+			oauth2Config.Scopes = upstream.Scopes
+		}
+		displayName := upstream.DisplayName
+		if displayName == "" {
+			displayName = upstream.Name
+		}
+		upstreams = append(upstreams, &upstreamIdP{
+			name:          upstream.Name,
+			displayName:   displayName,
+			issuer:        upstream.Issuer,
+			config:        oauth2Config,
+			endSessionURL: endSessionURL,
+		})
+	}
+	return upstreams
+}
+
+// upstream returns the upstream identity provider called name; an empty name
+// selects the primary provider
+func (h *OAuth2Handler) upstream(name string) *upstreamIdP {
+	if name == "" && len(h.upstreams) > 0 {
+		return h.upstreams[0]
+	}
+	for _, upstream := range h.upstreams {
+		if upstream.name == name {
+			return upstream
+		}
+	}
+	return nil
+}
+
+// upstreamForIssuer returns the upstream identity provider with issuer,
+// falling back to the primary provider
+func (h *OAuth2Handler) upstreamForIssuer(issuer string) *upstreamIdP {
+	for _, upstream := range h.upstreams {
+		if upstream.issuer == issuer {
+			return upstream
+		}
+	}
+	return h.upstream("")
+}
+
+// upstreamConfig returns the OAuth2 client configuration of the provider called
+// name, falling back to the primary provider
+func (h *OAuth2Handler) upstreamConfig(name string) *oauth2.Config {
+	if upstream := h.upstream(name); upstream != nil {
+		return upstream.config
+	}
+	return h.oauth2Config
+}
+
+// showProviderSelection lets the user choose an upstream identity provider.
+// Each choice is stored as a pushed authorization request (RFC 9126) with the
+// idp parameter set, so the page links carry no authorization parameters.
+func (h *OAuth2Handler) showProviderSelection(w http.ResponseWriter, r *http.Request, query url.Values, clientRedirectURI, state string) {
+	options := make([]ProviderOption, 0, len(h.upstreams))
+	for _, upstream := range h.upstreams {
+		params := url.Values{}
+		for key, values := range query {
+			params[key] = values
+		}
+		params.Del("request_uri")
+		params.Set("idp", upstream.name)
+
+		requestURI, err := h.par.add(params, providerSelectionLifetime)
+		if err != nil {
+			h.logger.Error("OAuth2: Failed to store provider selection: %v", err)
+			redirectOAuthError(w, r, clientRedirectURI, state, &OAuthError{Code: "server_error", Description: "Internal server error"})
+			return
+		}
+		link := url.Values{"client_id": {query.Get("client_id")}, "request_uri": {requestURI}}
+		options = append(options, ProviderOption{
+			Name:        upstream.name,
+			DisplayName: upstream.displayName,
+			URL:         h.endpointURL(h.paths().Authorize) + "?" + link.Encode(),
+		})
+	}
+
+	h.logger.Info("OAuth2: Showing provider selection page for client %s", query.Get("client_id"))
+	h.renderPage(w, http.StatusOK, providerSelectionTemplate, PageData{
+		ClientID:     query.Get("client_id"),
+		RedirectHost: redirectHost(clientRedirectURI),
+		Providers:    options,
+	})
+}
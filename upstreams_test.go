@@ -0,0 +1,171 @@
+package oauth
+
+import (
+	"encoding/json"
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+var providerLinkPattern = regexp.MustCompile(`<a href="([^"]+)">Continue with ([^<]+)</a>`)
+
+// newTestIdP starts an OIDC provider whose token endpoint returns accessToken
+// and counts token requests
+func newTestIdP(t *testing.T, accessToken string, tokenRequests *int32) *httptest.Server {
+	t.Helper()
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			_ = json.NewEncoder(w).Encode(map[string]string{
+				"issuer":                 server.URL,
+				"authorization_endpoint": server.URL + "/authorize",
+				"token_endpoint":         server.URL + "/token",
+				"jwks_uri":               server.URL + "/keys",
+				"end_session_endpoint":   server.URL + "/logout",
+			})
+		case "/token":
+			atomic.AddInt32(tokenRequests, 1)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"access_token": accessToken, "token_type": "Bearer", "expires_in": 3600})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// TestUpstreamProviders tests choosing an upstream provider and redeeming its code
+func TestUpstreamProviders(t *testing.T) {
+	var oktaTokens, googleTokens int32
+	okta := newTestIdP(t, "okta-token", &oktaTokens)
+	google := newTestIdP(t, "google-token", &googleTokens)
+
+	handler := NewOAuth2Handler(&OAuth2Config{
+		Mode:         "proxy",
+		Provider:     "okta",
+		Issuer:       okta.URL,
+		ClientID:     "okta-client",
+		RedirectURIs: "https://mcp.example.com/oauth/callback",
+		MCPURL:       "https://mcp.example.com",
+		ProviderName: "employees",
+		UpstreamProviders: []UpstreamProvider{
+			{Name: "google", DisplayName: "Google", Issuer: google.URL, ClientID: "google-client", Scopes: []string{"openid", "email"}},
+		},
+		stateSigningKey: []byte("test-state-signing-key"),
+	}, nil)
+
+	authorize := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.HandleAuthorize(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+	params := url.Values{"client_id": {"client"}, "redirect_uri": {"http://localhost:3000/callback"}, "state": {"client-state"}}
+	setTestCodeChallenge(params)
+
+	// Without an idp hint the user chooses a provider
+	rec := authorize("/oauth/authorize?" + params.Encode())
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected provider selection page, got %d: %s", rec.Code, rec.Body.String())
+	}
+	links := providerLinkPattern.FindAllStringSubmatch(rec.Body.String(), -1)
+	if len(links) != 2 || links[0][2] != "employees" || links[1][2] != "Google" {
+		t.Fatalf("Expected links for both providers, got %v", links)
+	}
+	link, _ := url.Parse(html.UnescapeString(links[1][1]))
+	if link.Query().Get("code_challenge") != "" || !strings.HasPrefix(link.Query().Get("request_uri"), requestURIPrefix) {
+		t.Errorf("Expected selection link to carry only a request_uri, got %s", link)
+	}
+
+	rec = authorize(link.RequestURI())
+	if rec.Code != http.StatusTemporaryRedirect {
+		t.Fatalf("Expected redirect to the chosen provider, got %d: %s", rec.Code, rec.Body.String())
+	}
+	location, _ := url.Parse(rec.Header().Get("Location"))
+	if !strings.HasPrefix(location.String(), google.URL+"/authorize?") || location.Query().Get("client_id") != "google-client" || location.Query().Get("scope") != "openid email" {
+		t.Fatalf("Expected redirect to Google with its client, got %s", location)
+	}
+
+	// The callback and token exchange redeem the code at the provider that issued it
+	callback := url.Values{"code": {"google-code"}, "state": {location.Query().Get("state")}}
+	rec = httptest.NewRecorder()
+	handler.HandleCallback(rec, httptest.NewRequest(http.MethodGet, "/oauth/callback?"+callback.Encode(), nil))
+	if rec.Code != http.StatusFound {
+		t.Fatalf("Expected callback redirect, got %d: %s", rec.Code, rec.Body.String())
+	}
+	form := url.Values{"grant_type": {"authorization_code"}, "code": {"google-code"}, "redirect_uri": {"http://localhost:3000/callback"}, "code_verifier": {testCodeVerifier}}
+	rec = postForm(handler.HandleToken, "/oauth/token", form)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "google-token") {
+		t.Fatalf("Expected Google token, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if googleTokens != 1 || oktaTokens != 0 {
+		t.Errorf("Expected exchange at Google only, got google=%d okta=%d", googleTokens, oktaTokens)
+	}
+
+	// An idp hint skips the selection page
+	params.Set("idp", "employees")
+	rec = authorize("/oauth/authorize?" + params.Encode())
+	if location := rec.Header().Get("Location"); rec.Code != http.StatusTemporaryRedirect || !strings.HasPrefix(location, okta.URL+"/authorize?") {
+		t.Errorf("Expected redirect to Okta, got %d: %s", rec.Code, location)
+	}
+
+	params.Set("idp", "github")
+	if rec := authorize("/oauth/authorize?" + params.Encode()); redirectedErrorCode(rec) != "invalid_request" {
+		t.Errorf("Expected unknown idp to be rejected, got %d", rec.Code)
+	}
+}
+
+// TestUpstreamProvidersValidation tests configuration checks and trusted issuers
+func TestUpstreamProvidersValidation(t *testing.T) {
+	base := Config{
+		Mode:         "proxy",
+		Provider:     "okta",
+		Issuer:       "https://company.okta.com",
+		Audience:     "api://mcp",
+		ClientID:     "okta-client",
+		ServerURL:    "https://mcp.example.com",
+		RedirectURIs: "https://mcp.example.com/oauth/callback",
+		Issuers:      []IssuerConfig{{Issuer: "https://login.example.com"}},
+		UpstreamProviders: []UpstreamProvider{
+			{Name: "google", Issuer: "https://accounts.google.com", ClientID: "google-client", Audiences: []string{"google-client"}},
+			{Name: "okta-partners", Issuer: "https://company.okta.com", ClientID: "partner-client"},
+		},
+	}
+	if err := base.Validate(); err != nil {
+		t.Fatalf("Expected valid configuration, got %v", err)
+	}
+
+	issuers := trustedIssuers(&base)
+	if len(issuers) != 2 || issuers[1].Issuer != "https://accounts.google.com" || issuers[1].Audiences[0] != "google-client" {
+		t.Errorf("Expected Google to be trusted once with its audiences, got %+v", issuers)
+	}
+
+	invalid := map[string]func(cfg *Config){
+		"allowlist mode":   func(cfg *Config) { cfg.RedirectURIs = "https://a.example.com/cb,https://b.example.com/cb" },
+		"duplicate name":   func(cfg *Config) { cfg.UpstreamProviders[1].Name = "google" },
+		"primary name":     func(cfg *Config) { cfg.UpstreamProviders[1].Name = "okta" },
+		"invalid name":     func(cfg *Config) { cfg.UpstreamProviders[0].Name = "google accounts" },
+		"missing issuer":   func(cfg *Config) { cfg.UpstreamProviders[0].Issuer = "" },
+		"missing clientID": func(cfg *Config) { cfg.UpstreamProviders[0].ClientID = "" },
+		"hmac provider": func(cfg *Config) {
+			cfg.Provider, cfg.JWTSecret, cfg.Issuers = "hmac", []byte("secret"), nil
+		},
+	}
+	for name, mutate := range invalid {
+		t.Run(name, func(t *testing.T) {
+			cfg := base
+			cfg.UpstreamProviders = append([]UpstreamProvider{}, base.UpstreamProviders...)
+			mutate(&cfg)
+			if err := cfg.Validate(); err == nil {
+				t.Error("Expected configuration to be rejected")
+			}
+		})
+	}
+}
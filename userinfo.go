@@ -0,0 +1,162 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// userInfoClient fetches OIDC UserInfo claims for validated access tokens
+type userInfoClient struct {
+	httpClient *http.Client
+	refresh    time.Duration // Config.UpstreamMetadataRefresh
+	logger     Logger
+
+	mu        sync.Mutex
+	discovery map[string]*upstreamMetadata // Discovery documents by issuer
+}
+
+// newUserInfoClient creates a client when Config.UserInfoEnrichment is enabled
+func newUserInfoClient(cfg *Config, logger Logger) *userInfoClient {
+	if !cfg.UserInfoEnrichment {
+		return nil
+	}
+	return &userInfoClient{
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		refresh:    cfg.UpstreamMetadataRefresh,
+		logger:     logger,
+		discovery:  make(map[string]*upstreamMetadata),
+	}
+}
+
+// enrichUser fills in User fields missing from the access token using the
+// issuer's UserInfo endpoint. Token claims take precedence over UserInfo
+// claims. On failure the user is returned unchanged and the error is logged.
+func (s *Server) enrichUser(ctx context.Context, token string, user *User) *User {
+	if s.userInfo == nil || (user.Username != "" && user.Email != "") {
+		return user
+	}
+	// The UserInfo request carries the token as a Bearer token, which a
+	// sender-constrained token cannot be used as
+	if confirmationClaim(user, "jkt") != "" || confirmationClaim(user, "x5t#S256") != "" {
+		return user
+	}
+
+	claims, err := s.userInfo.fetch(ctx, user.Issuer, token)
+	if err != nil {
+		s.logger.Warn("UserInfo enrichment failed for issuer %s: %v", user.Issuer, err)
+		return user
+	}
+
+	// OIDC Core 5.3.2: the UserInfo sub must match the token's sub
+	tokenSubject, _ := user.Claims["sub"].(string)
+	if sub, _ := claims["sub"].(string); sub == "" || sub != tokenSubject {
+		s.logger.Warn("UserInfo enrichment ignored: subject does not match the access token")
+		return user
+	}
+
+	merged := make(map[string]interface{}, len(user.Claims)+len(claims))
+	for name, value := range claims {
+		merged[name] = value
+	}
+	for name, value := range user.Claims {
+		merged[name] = value
+	}
+
+	enriched := *user
+	enriched.Claims = merged
+	mapping := s.claimMapping(user.Issuer)
+	if enriched.Username == "" {
+		enriched.Username, _ = merged[mapping.UsernameClaim()].(string)
+	}
+	if enriched.Email == "" {
+		enriched.Email, _ = merged[mapping.EmailClaim()].(string)
+	}
+	return &enriched
+}
+
+// claimMapping returns the claim mapping that applies to tokens from issuer
+func (s *Server) claimMapping(issuer string) *ClaimMapping {
+	for _, trusted := range trustedIssuers(s.config) {
+		if trusted.Issuer == issuer && trusted.ClaimMapping != nil {
+			return trusted.ClaimMapping
+		}
+	}
+	return s.config.ClaimMapping
+}
+
+// fetch calls the issuer's UserInfo endpoint with the access token
+func (c *userInfoClient) fetch(ctx context.Context, issuer, token string) (map[string]interface{}, error) {
+	endpoint, err := c.endpoint(issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create userinfo request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/json")
+
+	var claims map[string]interface{}
+	if err := c.getJSON(req, &claims); err != nil {
+		return nil, fmt.Errorf("userinfo request failed: %w", err)
+	}
+	return claims, nil
+}
+
+// endpoint returns the issuer's userinfo_endpoint from its discovery
+// document, which is cached and revalidated like the native mode mirror
+// (see upstreamMetadata), including after failed fetches
+func (c *userInfoClient) endpoint(issuer string) (string, error) {
+	if issuer == "" {
+		return "", fmt.Errorf("token has no issuer")
+	}
+
+	c.mu.Lock()
+	discovery, ok := c.discovery[issuer]
+	if !ok {
+		discovery = newDiscoveryMirror(issuer, c.refresh, c.logger)
+		c.discovery[issuer] = discovery
+	}
+	c.mu.Unlock()
+
+	metadata, err := discovery.get()
+	if err != nil {
+		return "", fmt.Errorf("failed to discover userinfo endpoint: %w", err)
+	}
+	if metadata.UserinfoEndpoint == "" {
+		return "", fmt.Errorf("issuer %s does not advertise a userinfo_endpoint", issuer)
+	}
+	return metadata.UserinfoEndpoint, nil
+}
+
+// getJSON performs req and decodes a 200 JSON response into v
+func (c *userInfoClient) getJSON(req *http.Request, v interface{}) error {
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType != "application/json" {
+		return fmt.Errorf("unsupported content type %q", mediaType)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("invalid JSON response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tuannvm/oauth-mcp-proxy/provider"
+)
+
+// staticValidator returns a copy of user for every token
+type staticValidator struct {
+	user User
+}
+
+func (v *staticValidator) Initialize(cfg *provider.Config) error { return nil }
+
+func (v *staticValidator) ValidateToken(ctx context.Context, token string) (*User, error) {
+	user := v.user
+	return &user, nil
+}
+
+// newUserInfoTestServer serves discovery and a UserInfo endpoint returning claims
+func newUserInfoTestServer(t *testing.T, claims map[string]interface{}, calls *int32) *httptest.Server {
+	t.Helper()
+
+	var idp *httptest.Server
+	idp = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			_ = json.NewEncoder(w).Encode(map[string]string{"issuer": idp.URL, "userinfo_endpoint": idp.URL + "/userinfo"})
+		case "/userinfo":
+			atomic.AddInt32(calls, 1)
+			if r.Header.Get("Authorization") != "Bearer thin-token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(claims)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(idp.Close)
+	return idp
+}
+
+// TestUserInfoEnrichment tests merging UserInfo claims into a thin access token
+func TestUserInfoEnrichment(t *testing.T) {
+	var calls int32
+	idp := newUserInfoTestServer(t, map[string]interface{}{
+		"sub":   "user-1",
+		"email": "user@example.com",
+		"upn":   "user@corp.example.com",
+		"name":  "Example User",
+	}, &calls)
+
+	cfg := &Config{
+		Provider:           "okta",
+		Issuer:             idp.URL,
+		Audience:           "api://test",
+		UserInfoEnrichment: true,
+		ClaimMapping:       &ClaimMapping{Username: "upn"},
+	}
+	server := &Server{
+		config: cfg,
+		validator: &staticValidator{user: User{
+			Subject: "user-1",
+			Issuer:  idp.URL,
+			Claims:  map[string]interface{}{"sub": "user-1", "iss": idp.URL, "name": "Token Name"},
+		}},
+		cache:    &TokenCache{cache: make(map[string]*CachedToken)},
+		logger:   &defaultLogger{},
+		userInfo: newUserInfoClient(cfg, &defaultLogger{}),
+	}
+
+	user, err := server.ValidateTokenCached(context.Background(), "thin-token")
+	if err != nil {
+		t.Fatalf("ValidateTokenCached failed: %v", err)
+	}
+	if user.Email != "user@example.com" || user.Username != "user@corp.example.com" {
+		t.Errorf("Expected enriched user, got %+v", user)
+	}
+	if user.Claims["name"] != "Token Name" {
+		t.Errorf("Expected token claims to take precedence, got %v", user.Claims["name"])
+	}
+
+	// The enriched result is cached with the validation
+	if _, err := server.ValidateTokenCached(context.Background(), "thin-token"); err != nil {
+		t.Fatalf("ValidateTokenCached failed: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected one UserInfo call, got %d", calls)
+	}
+}
+
+// TestUserInfoEnrichment_Ignored tests cases where UserInfo claims are not used
+func TestUserInfoEnrichment_Ignored(t *testing.T) {
+	var calls int32
+	idp := newUserInfoTestServer(t, map[string]interface{}{"sub": "someone-else", "email": "other@example.com"}, &calls)
+
+	cfg := &Config{Provider: "okta", Issuer: idp.URL, Audience: "api://test", UserInfoEnrichment: true}
+	server := &Server{config: cfg, logger: &defaultLogger{}, userInfo: newUserInfoClient(cfg, &defaultLogger{})}
+
+	tests := []struct {
+		name  string
+		user  *User
+		calls int32
+	}{
+		{
+			name:  "complete token",
+			user:  &User{Username: "user", Email: "user@example.com", Issuer: idp.URL, Claims: map[string]interface{}{"sub": "user-1"}},
+			calls: 0,
+		},
+		{
+			name:  "sender-constrained token",
+			user:  &User{Issuer: idp.URL, Claims: map[string]interface{}{"sub": "user-1", "cnf": map[string]interface{}{"jkt": "thumbprint"}}},
+			calls: 0,
+		},
+		{
+			name:  "subject mismatch",
+			user:  &User{Issuer: idp.URL, Claims: map[string]interface{}{"sub": "user-1"}},
+			calls: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			atomic.StoreInt32(&calls, 0)
+			enriched := server.enrichUser(context.Background(), "thin-token", tt.user)
+			if enriched.Email != tt.user.Email {
+				t.Errorf("Expected user to be unchanged, got %+v", enriched)
+			}
+			if calls != tt.calls {
+				t.Errorf("Expected %d UserInfo calls, got %d", tt.calls, calls)
+			}
+		})
+	}
+
+	if err := (&Config{Provider: "hmac", Audience: "api://test", JWTSecret: []byte("secret"), UserInfoEnrichment: true}).Validate(); err == nil {
+		t.Error("Expected UserInfoEnrichment to be rejected for HMAC")
+	}
+}
+
+// TestUserInfoEnrichment_FailedDiscovery tests that a failed discovery is not retried for every token
+func TestUserInfoEnrichment_FailedDiscovery(t *testing.T) {
+	var discoveries int32
+	idp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&discoveries, 1)
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(idp.Close)
+
+	cfg := &Config{Provider: "okta", Issuer: idp.URL, Audience: "api://test", UserInfoEnrichment: true}
+	server := &Server{config: cfg, logger: &defaultLogger{}, userInfo: newUserInfoClient(cfg, &defaultLogger{})}
+	user := &User{Issuer: idp.URL, Claims: map[string]interface{}{"sub": "user-1"}}
+
+	for i := 0; i < 3; i++ {
+		if enriched := server.enrichUser(context.Background(), "thin-token", user); enriched != user {
+			t.Fatalf("Expected user to be unchanged, got %+v", enriched)
+		}
+	}
+	if discoveries != 1 {
+		t.Errorf("Expected 1 discovery request, got %d", discoveries)
+	}
+
+	// After the retry interval the issuer is asked again
+	discovery := server.userInfo.discovery[idp.URL]
+	discovery.mu.Lock()
+	discovery.nextFetch = time.Now()
+	discovery.mu.Unlock()
+	server.enrichUser(context.Background(), "thin-token", user)
+	if discoveries != 2 {
+		t.Errorf("Expected discovery to be retried, got %d requests", discoveries)
+	}
+}
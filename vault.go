@@ -0,0 +1,301 @@
+package oauth
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/oauth2"
+)
+
+// TokenVault stores the upstream identity provider tokens obtained by the
+// proxy's /oauth/token endpoint, encrypted with AES-GCM and keyed by the
+// issuer and subject of the user. Tool handlers retrieve them with GetUpstreamToken; expired
+// access tokens are refreshed automatically when a refresh token is available.
+//
+// The vault is in memory and per process: tokens are lost on restart and are
+// not shared between replicas. Users who authenticated before the vault was
+// enabled must sign in again.
+type TokenVault struct {
+	aead cipher.AEAD
+
+	mu      sync.Mutex
+	records map[string][]byte
+
+	// refreshMu serializes refreshes so rotated refresh tokens are used once
+	refreshMu sync.Mutex
+}
+
+// vaultRecord is the plaintext form of a stored token
+type vaultRecord struct {
+	AccessToken  string    `json:"access_token"`
+	TokenType    string    `json:"token_type,omitempty"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	IDToken      string    `json:"id_token,omitempty"`
+	Expiry       time.Time `json:"expiry,omitempty"`
+	Upstream     string    `json:"upstream,omitempty"` // Name of the issuing upstream provider
+}
+
+// NewTokenVault creates an empty vault encrypting tokens with key, which must
+// be 16, 24 or 32 bytes (AES-128, AES-192 or AES-256).
+//
+// Example:
+//
+//	vault, err := oauth.NewTokenVault(vaultKey) // 32 random bytes
+//	cfg.TokenVault = vault
+func NewTokenVault(key []byte) (*TokenVault, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid token vault key: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize token vault cipher: %w", err)
+	}
+	return &TokenVault{
+		aead:    aead,
+		records: make(map[string][]byte),
+	}, nil
+}
+
+// Delete removes the tokens stored for subject
+func (v *TokenVault) Delete(subject string) {
+	v.mu.Lock()
+	delete(v.records, subject)
+	v.mu.Unlock()
+}
+
+// put encrypts and stores a record, binding the ciphertext to subject
+func (v *TokenVault) put(subject string, record *vaultRecord) error {
+	plaintext, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode upstream token: %w", err)
+	}
+	nonce := make([]byte, v.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed := v.aead.Seal(nonce, nonce, plaintext, []byte(subject))
+
+	v.mu.Lock()
+	v.records[subject] = sealed
+	v.mu.Unlock()
+	return nil
+}
+
+// get decrypts the record stored for subject
+func (v *TokenVault) get(subject string) (*vaultRecord, error) {
+	v.mu.Lock()
+	sealed, ok := v.records[subject]
+	v.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("no upstream token stored for this user")
+	}
+
+	nonceSize := v.aead.NonceSize()
+	if len(sealed) < nonceSize {
+		return nil, fmt.Errorf("corrupt upstream token record")
+	}
+	plaintext, err := v.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], []byte(subject))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt upstream token: %w", err)
+	}
+
+	var record vaultRecord
+	if err := json.Unmarshal(plaintext, &record); err != nil {
+		return nil, fmt.Errorf("failed to decode upstream token: %w", err)
+	}
+	return &record, nil
+}
+
+// fresh reports whether the stored access token can be used without a refresh
+func (r *vaultRecord) fresh() bool {
+	return r.Expiry.IsZero() || time.Now().Add(exchangeExpirySkew).Before(r.Expiry)
+}
+
+// token converts a record to an oauth2.Token with the ID token as extra "id_token"
+func (r *vaultRecord) token() *oauth2.Token {
+	token := &oauth2.Token{
+		AccessToken:  r.AccessToken,
+		TokenType:    r.TokenType,
+		RefreshToken: r.RefreshToken,
+		Expiry:       r.Expiry,
+	}
+	if r.IDToken == "" {
+		return token
+	}
+	return token.WithExtra(map[string]interface{}{"id_token": r.IDToken})
+}
+
+// store saves tokens returned by the token endpoint of the provider called
+// upstream under subject
+func (v *TokenVault) store(subject, upstream string, token *oauth2.Token) error {
+	record := &vaultRecord{
+		AccessToken:  token.AccessToken,
+		TokenType:    token.TokenType,
+		RefreshToken: token.RefreshToken,
+		Expiry:       token.Expiry,
+		Upstream:     upstream,
+	}
+	if idToken, ok := token.Extra("id_token").(string); ok {
+		record.IDToken = idToken
+	}
+	return v.put(subject, record)
+}
+
+// token returns the stored token for subject, which must have been issued by
+// the provider called upstream, refreshing it with the client configuration
+// upstreamConfig returns when it has expired. A refresh token rejected by the
+// provider removes the record.
+func (v *TokenVault) token(ctx context.Context, subject, upstream string, upstreamConfig func(upstream string) *oauth2.Config) (*oauth2.Token, error) {
+	record, err := v.get(subject)
+	if err != nil {
+		return nil, err
+	}
+	if record.Upstream != upstream {
+		return nil, fmt.Errorf("no upstream token stored for this user")
+	}
+	if record.fresh() {
+		return record.token(), nil
+	}
+
+	v.refreshMu.Lock()
+	defer v.refreshMu.Unlock()
+
+	// Another caller may have refreshed while we waited
+	record, err = v.get(subject)
+	if err != nil {
+		return nil, err
+	}
+	if record.fresh() {
+		return record.token(), nil
+	}
+	if record.RefreshToken == "" {
+		return nil, fmt.Errorf("upstream token expired and no refresh token is available")
+	}
+
+	refreshed, err := upstreamConfig(record.Upstream).TokenSource(ctx, &oauth2.Token{RefreshToken: record.RefreshToken}).Token()
+	if err != nil {
+		var retrieveErr *oauth2.RetrieveError
+		if errors.As(err, &retrieveErr) {
+			v.Delete(subject)
+		}
+		return nil, fmt.Errorf("failed to refresh upstream token: %w", err)
+	}
+
+	record.AccessToken = refreshed.AccessToken
+	record.TokenType = refreshed.TokenType
+	record.Expiry = refreshed.Expiry
+	if refreshed.RefreshToken != "" {
+		record.RefreshToken = refreshed.RefreshToken
+	}
+	if idToken, ok := refreshed.Extra("id_token").(string); ok && idToken != "" {
+		record.IDToken = idToken
+	}
+	if err := v.put(subject, record); err != nil {
+		return nil, err
+	}
+	return record.token(), nil
+}
+
+// GetUpstreamToken returns the upstream identity provider token of the
+// authenticated user, refreshing it if it has expired. The returned token's
+// Extra("id_token") holds the ID token, if the provider issued one.
+//
+// Requires Config.TokenVault; tokens are stored when the user completes the
+// proxy's /oauth/token exchange.
+//
+// Example:
+//
+//	token, err := oauthServer.GetUpstreamToken(ctx)
+//	if err != nil {
+//	    return nil, err
+//	}
+//	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+func (s *Server) GetUpstreamToken(ctx context.Context) (*oauth2.Token, error) {
+	if s.config == nil || s.config.TokenVault == nil {
+		return nil, fmt.Errorf("token vault is not configured")
+	}
+	user, ok := GetUserFromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("upstream token requires an authenticated user in context")
+	}
+	upstream := s.handler.upstreamForIssuer(user.Issuer)
+	if upstream == nil {
+		return nil, fmt.Errorf("no upstream provider for issuer %s", user.Issuer)
+	}
+	return s.config.TokenVault.token(ctx, vaultSubject(user), upstream.name, s.handler.upstreamConfig)
+}
+
+// GetUpstreamToken returns the authenticated user's upstream identity provider
+// token using the Server that authenticated the request. Tool handlers can
+// call it directly; see Server.GetUpstreamToken.
+func GetUpstreamToken(ctx context.Context) (*oauth2.Token, error) {
+	server, ok := ctx.Value(serverContextKey).(*Server)
+	if !ok {
+		return nil, fmt.Errorf("upstream token requires an OAuth-authenticated context")
+	}
+	return server.GetUpstreamToken(ctx)
+}
+
+// vaultSubject returns the vault key for an authenticated user: the issuer
+// and the raw "sub" claim, so that custom claim mappings do not change the key
+// and users of different issuers with the same "sub" do not share it
+func vaultSubject(user *User) string {
+	if sub, ok := user.Claims["sub"].(string); ok && sub != "" {
+		return subjectKey(user.Issuer, sub)
+	}
+	return subjectKey(user.Issuer, user.Subject)
+}
+
+// subjectKey identifies a user by issuer and subject. It keys the token vault,
+// the browser sessions of the consent page and the consent store.
+func subjectKey(issuer, subject string) string {
+	return issuer + "|" + subject
+}
+
+// keySubject returns the subject of a key created by subjectKey
+func keySubject(key string) string {
+	_, subject, _ := strings.Cut(key, "|")
+	return subject
+}
+
+// upstreamSubjectKey returns the key of the user upstream issued token to, or
+// "" if the token has no subject
+func upstreamSubjectKey(upstream *upstreamIdP, token *oauth2.Token) string {
+	subject := upstreamSubject(token)
+	if subject == "" {
+		return ""
+	}
+	return subjectKey(upstream.issuer, subject)
+}
+
+// upstreamSubject returns the "sub" claim of the tokens returned by the
+// upstream token endpoint. The access token is preferred because it is what
+// clients present to this server; the ID token is used when the access token
+// is opaque. Signatures are not verified: the response was received directly
+// from the provider over TLS (OIDC Core 3.1.3.7).
+func upstreamSubject(token *oauth2.Token) string {
+	candidates := []string{token.AccessToken}
+	if idToken, ok := token.Extra("id_token").(string); ok {
+		candidates = append(candidates, idToken)
+	}
+	for _, candidate := range candidates {
+		claims := jwt.MapClaims{}
+		if _, _, err := jwt.NewParser().ParseUnverified(candidate, claims); err != nil {
+			continue
+		}
+		if sub, ok := claims["sub"].(string); ok && sub != "" {
+			return sub
+		}
+	}
+	return ""
+}
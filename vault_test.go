@@ -0,0 +1,222 @@
+package oauth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/oauth2"
+)
+
+// newVaultTestServer creates a proxy server with a token vault whose upstream provider is served by handler
+func newVaultTestServer(t *testing.T, handler http.HandlerFunc) (*Server, *TokenVault) {
+	t.Helper()
+
+	vault, err := NewTokenVault(bytes.Repeat([]byte("k"), 32))
+	if err != nil {
+		t.Fatalf("Failed to create vault: %v", err)
+	}
+	server := newTestServer(t, handler, func(cfg *Config) {
+		cfg.RedirectURIs = "https://client.example.com/callback,https://client.example.com/alt"
+		cfg.TokenVault = vault
+	})
+	return server, vault
+}
+
+// TestTokenVault_Encryption tests that records are encrypted and bound to their subject
+func TestTokenVault_Encryption(t *testing.T) {
+	if _, err := NewTokenVault([]byte("short")); err == nil {
+		t.Error("Expected invalid key length to be rejected")
+	}
+
+	vault, err := NewTokenVault(bytes.Repeat([]byte("k"), 32))
+	if err != nil {
+		t.Fatalf("Failed to create vault: %v", err)
+	}
+	if err := vault.put("alice", &vaultRecord{AccessToken: "secret-access-token", RefreshToken: "secret-refresh-token"}); err != nil {
+		t.Fatalf("put failed: %v", err)
+	}
+
+	sealed := vault.records["alice"]
+	if bytes.Contains(sealed, []byte("secret-access-token")) || bytes.Contains(sealed, []byte("secret-refresh-token")) {
+		t.Error("Expected tokens to be encrypted at rest")
+	}
+
+	record, err := vault.get("alice")
+	if err != nil || record.AccessToken != "secret-access-token" {
+		t.Fatalf("Expected round trip, got %+v, %v", record, err)
+	}
+
+	// A record moved to another subject does not decrypt
+	vault.records["mallory"] = sealed
+	if _, err := vault.get("mallory"); err == nil {
+		t.Error("Expected record bound to another subject to be rejected")
+	}
+
+	vault.Delete("alice")
+	if _, err := vault.get("alice"); err == nil {
+		t.Error("Expected deleted record to be gone")
+	}
+}
+
+// TestGetUpstreamToken tests storing tokens at /oauth/token and refreshing them on expiry
+func TestGetUpstreamToken(t *testing.T) {
+	accessToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "user-1"}).SignedString([]byte("upstream-key"))
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+
+	var refreshes int32
+	rejectRefresh := false
+	server, vault := newVaultTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		w.Header().Set("Content-Type", "application/json")
+		switch r.PostForm.Get("grant_type") {
+		case "authorization_code":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"access_token":  accessToken,
+				"token_type":    "Bearer",
+				"expires_in":    3600,
+				"refresh_token": "refresh-1",
+				"id_token":      "id-token-1",
+			})
+		case "refresh_token":
+			atomic.AddInt32(&refreshes, 1)
+			if rejectRefresh || r.PostForm.Get("refresh_token") != "refresh-1" {
+				w.WriteHeader(http.StatusBadRequest)
+				_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"access_token": "refreshed-access-token",
+				"token_type":   "Bearer",
+				"expires_in":   3600,
+			})
+		}
+	})
+
+	form := url.Values{"grant_type": {"authorization_code"}, "code": {"auth-code"}, "code_verifier": {testCodeVerifier}}
+	req := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	server.handler.HandleToken(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Token exchange failed: %d %s", rec.Code, rec.Body.String())
+	}
+
+	subject := subjectKey(server.config.Issuer, "user-1")
+	ctx := WithUser(context.Background(), &User{Subject: "mapped-id", Issuer: server.config.Issuer, Claims: map[string]interface{}{"sub": "user-1"}})
+	ctx = WithServer(ctx, server)
+
+	token, err := GetUpstreamToken(ctx)
+	if err != nil {
+		t.Fatalf("GetUpstreamToken failed: %v", err)
+	}
+	if token.AccessToken != accessToken || token.Extra("id_token") != "id-token-1" {
+		t.Errorf("Unexpected upstream token: %+v", token)
+	}
+
+	// Expire the stored token; the next call refreshes it and keeps the refresh and ID tokens
+	record, _ := vault.get(subject)
+	record.Expiry = time.Now().Add(-time.Minute)
+	_ = vault.put(subject, record)
+
+	token, err = GetUpstreamToken(ctx)
+	if err != nil {
+		t.Fatalf("GetUpstreamToken refresh failed: %v", err)
+	}
+	if token.AccessToken != "refreshed-access-token" || token.RefreshToken != "refresh-1" || token.Extra("id_token") != "id-token-1" {
+		t.Errorf("Unexpected refreshed token: %+v", token)
+	}
+	if refreshes != 1 {
+		t.Errorf("Expected one refresh, got %d", refreshes)
+	}
+
+	// A rejected refresh token removes the record
+	record, _ = vault.get(subject)
+	record.Expiry = time.Now().Add(-time.Minute)
+	_ = vault.put(subject, record)
+	rejectRefresh = true
+	if _, err := GetUpstreamToken(ctx); err == nil {
+		t.Error("Expected refresh failure")
+	}
+	if _, err := vault.get(subject); err == nil {
+		t.Error("Expected record to be removed after invalid_grant")
+	}
+}
+
+// TestGetUpstreamToken_Errors tests failure cases
+func TestGetUpstreamToken_Errors(t *testing.T) {
+	if _, err := GetUpstreamToken(context.Background()); err == nil {
+		t.Error("Expected error without an authenticated context")
+	}
+
+	server, _ := newVaultTestServer(t, func(w http.ResponseWriter, r *http.Request) {})
+	if _, err := server.GetUpstreamToken(context.Background()); err == nil {
+		t.Error("Expected error without a user")
+	}
+	ctx := WithUser(context.Background(), &User{Subject: "unknown"})
+	if _, err := server.GetUpstreamToken(ctx); err == nil {
+		t.Error("Expected error for a user without stored tokens")
+	}
+
+	vault, _ := NewTokenVault(bytes.Repeat([]byte("k"), 32))
+	cfg := &Config{Mode: "native", Provider: "hmac", Audience: "api://test", JWTSecret: []byte("secret"), TokenVault: vault}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected TokenVault to require proxy mode")
+	}
+}
+
+// TestGetUpstreamToken_SameSubject tests that users of different upstream providers with the same "sub" do not share tokens
+func TestGetUpstreamToken_SameSubject(t *testing.T) {
+	var tokens int32
+	google := newTestIdP(t, "google-token", &tokens)
+
+	vault, err := NewTokenVault(bytes.Repeat([]byte("k"), 32))
+	if err != nil {
+		t.Fatalf("Failed to create vault: %v", err)
+	}
+	server := newTestServer(t, http.NotFound, func(cfg *Config) {
+		cfg.Provider = "okta"
+		cfg.UpstreamProviders = []UpstreamProvider{{Name: "google", Issuer: google.URL, ClientID: "google-client"}}
+		cfg.TokenVault = vault
+	})
+	oktaIssuer := server.config.Issuer
+
+	// Both providers issue tokens to "user-1"
+	for _, upstream := range server.handler.upstreams {
+		accessToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "user-1"}).SignedString([]byte("upstream-key"))
+		if err != nil {
+			t.Fatalf("Failed to sign token: %v", err)
+		}
+		token := (&oauth2.Token{AccessToken: accessToken, TokenType: "Bearer"}).WithExtra(map[string]interface{}{"id_token": upstream.name + "-id-token"})
+		server.handler.writeTokenResponse(httptest.NewRecorder(), upstream, token)
+	}
+
+	for issuer, idToken := range map[string]string{oktaIssuer: "okta-id-token", google.URL: "google-id-token"} {
+		ctx := WithUser(context.Background(), &User{Subject: "user-1", Issuer: issuer, Claims: map[string]interface{}{"sub": "user-1"}})
+		token, err := server.GetUpstreamToken(ctx)
+		if err != nil {
+			t.Fatalf("GetUpstreamToken for %s failed: %v", issuer, err)
+		}
+		if token.Extra("id_token") != idToken {
+			t.Errorf("Expected %s for the user of %s, got %v", idToken, issuer, token.Extra("id_token"))
+		}
+	}
+
+	// A record of another provider is not returned
+	record, _ := vault.get(subjectKey(google.URL, "user-1"))
+	_ = vault.put(subjectKey(oktaIssuer, "user-1"), record)
+	ctx := WithUser(context.Background(), &User{Subject: "user-1", Issuer: oktaIssuer})
+	if _, err := server.GetUpstreamToken(ctx); err == nil {
+		t.Error("Expected a record of another provider to be rejected")
+	}
+}